@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/globallstudent/todo-project-go/internal/config"
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+	"github.com/globallstudent/todo-project-go/internal/passhash"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/server"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+const createAdminUsage = `usage: api create-admin <username>
+
+Creates an admin account, or promotes and re-enables an existing one. The
+password is taken from ADMIN_PASSWORD, or else read from the first line of
+standard input.`
+
+// runCreateAdmin implements the "create-admin" subcommand.
+func runCreateAdmin(cfg *config.Config, args []string) error {
+	if len(args) != 1 || cfg.StorageBackend == "memory" {
+		return errors.New(createAdminUsage)
+	}
+
+	password, ok := os.LookupEnv("ADMIN_PASSWORD")
+	if !ok {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		return err
+	}
+	passwords, err := server.PasswordHasher(cfg)
+	if err != nil {
+		return err
+	}
+	policy, err := server.PasswordPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := newAuthService(cfg, store, keys, passwords, policy).CreateAdmin(context.Background(), args[0], password)
+	if err != nil {
+		return err
+	}
+	log.Printf("User %q (id %d) is an admin", user.Username, user.ID)
+	return nil
+}
+
+// bootstrapAdmin creates the admin named by BOOTSTRAP_ADMIN_USERNAME and
+// BOOTSTRAP_ADMIN_PASSWORD when no enabled admin exists yet. It fails if a
+// user already has that username.
+func bootstrapAdmin(cfg *config.Config, store *repositories.Store, keys *keyset.Keyset,
+	passwords *passhash.Hasher, policy *passpolicy.Policy) error {
+	if cfg.BootstrapAdminUsername == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+	authService := newAuthService(cfg, store, keys, passwords, policy)
+	ok, err := authService.HasActiveAdmin(ctx)
+	if err != nil || ok {
+		return err
+	}
+	user, err := authService.BootstrapAdmin(ctx, cfg.BootstrapAdminUsername, cfg.BootstrapAdminPassword)
+	if errors.Is(err, services.ErrUsernameTaken) {
+		return fmt.Errorf("user %q already exists; promote it with create-admin or choose another username",
+			cfg.BootstrapAdminUsername)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Bootstrapped admin %q (id %d)", user.Username, user.ID)
+	return nil
+}
+
+func newAuthService(cfg *config.Config, store *repositories.Store, keys *keyset.Keyset,
+	passwords *passhash.Hasher, policy *passpolicy.Policy) *services.AuthService {
+	revocationService := services.NewRevocationService(store.TokenRevocations, cfg.AccessTokenTTL)
+	throttleService := services.NewLoginThrottleService(store.LoginThrottles, server.LoginLimits(cfg))
+	mfaService := services.NewMFAService(store.MFA, throttleService, cfg.MFAIssuer)
+	return services.NewAuthService(store.Users, store.Projects, store.RefreshTokens, revocationService,
+		mfaService, throttleService, keys, passwords, policy, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/globallstudent/todo-project-go/internal/config"
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+)
+
+const keysUsage = `usage: api keys <command>
+
+Manages the JWT signing keys in JWT_KEYS_DIR.
+
+commands:
+  list             list the keys and which one is active
+  generate [ALG]   add a key (RS256 or EdDSA, default EdDSA); the first key
+                   of the directory becomes active, later ones only verify
+  activate KID     make a key sign new tokens
+  rotate [ALG]     generate a key and make it active at once
+  retire KID       delete a key; tokens it signed stop verifying
+
+Keys take effect when the API starts. With several instances, generate the
+key and restart them all before activating it, so that every instance can
+verify its tokens; retire the old key once the tokens it signed have
+expired (ACCESS_TOKEN_TTL).`
+
+// runKeys implements the "keys" subcommand.
+func runKeys(cfg *config.Config, args []string) error {
+	if len(args) == 0 || cfg.JWTKeysDir == "" {
+		return errors.New(keysUsage)
+	}
+	dir := cfg.JWTKeysDir
+	alg := keyset.EdDSA
+	if len(args) > 1 {
+		alg = args[1]
+	}
+
+	switch args[0] {
+	case "list":
+		keys, active, err := keyset.List(dir)
+		if err != nil {
+			return err
+		}
+		printKeys(keys, active)
+		return nil
+	case "generate", "rotate":
+		key, err := keyset.Generate(dir, alg)
+		if err != nil {
+			return err
+		}
+		log.Printf("Generated %s key %s", key.Algorithm, key.ID)
+		if args[0] == "generate" {
+			return nil
+		}
+		return activateKey(dir, key.ID)
+	case "activate":
+		if len(args) != 2 {
+			return errors.New(keysUsage)
+		}
+		return activateKey(dir, args[1])
+	case "retire":
+		if len(args) != 2 {
+			return errors.New(keysUsage)
+		}
+		if err := keyset.Retire(dir, args[1]); err != nil {
+			return err
+		}
+		log.Printf("Retired key %s", args[1])
+		return nil
+	default:
+		return errors.New(keysUsage)
+	}
+}
+
+func activateKey(dir, kid string) error {
+	if err := keyset.Activate(dir, kid); err != nil {
+		return err
+	}
+	log.Printf("Key %s is active", kid)
+	return nil
+}
+
+func printKeys(keys []*keyset.Key, active string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALGORITHM\tSTATE\tCREATED AT")
+	for _, k := range keys {
+		state := "verify"
+		if k.ID == active {
+			state = "active"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.ID, k.Algorithm, state, k.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	w.Flush()
+}
+
+// loadKeys returns the keyset tokens are signed with: the keys in
+// JWT_KEYS_DIR, or else HS256 with JWT_SECRET.
+func loadKeys(cfg *config.Config) (*keyset.Keyset, error) {
+	if cfg.JWTKeysDir == "" {
+		log.Println("JWT_KEYS_DIR is not set, signing tokens with HS256 and JWT_SECRET")
+		return keyset.NewHMAC(cfg.JWTSecret), nil
+	}
+	keys, err := keyset.Load(cfg.JWTKeysDir)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Signing tokens with %s key %s", keys.Algorithm(), keys.ActiveID())
+	return keys, nil
+}
@@ -1,20 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
-	// "net/http"
+	"os"
 
-	"github.com/gin-gonic/gin"
 	"github.com/globallstudent/todo-project-go/internal/config"
-	"github.com/globallstudent/todo-project-go/internal/database"
-	"github.com/globallstudent/todo-project-go/internal/handlers"
-	"github.com/globallstudent/todo-project-go/internal/middleware"
 	"github.com/globallstudent/todo-project-go/internal/repositories"
-	"github.com/globallstudent/todo-project-go/internal/services"
+	"github.com/globallstudent/todo-project-go/internal/server"
 	// Swagger docs
 	_ "github.com/globallstudent/todo-project-go/docs" // Import generated docs
-	"github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 // @title Todo API
@@ -41,46 +37,83 @@ import (
 func main() {
 	cfg := config.LoadConfig()
 
-	db, err := database.NewDB(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := runKeys(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Managing keys failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		if err := runCreateAdmin(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Creating admin failed: %v", err)
+		}
+		return
 	}
-	defer db.Close()
-
-	userRepo := repositories.NewUserRepository(db)
-	todoRepo := repositories.NewTodoRepository(db)
-
-	authService := services.NewAuthService(userRepo, cfg.JWTSecret)
-	todoService := services.NewTodoService(todoRepo)
-
-	authHandler := handlers.NewAuthHandler(authService)
-	todoHandler := handlers.NewTodoHandler(todoService)
-
-	r := gin.Default()
-
-	r.POST("/register", authHandler.Register)
-	r.POST("/login", authHandler.Login)
 
-	protected := r.Group("/todos")
-	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-	{
-		protected.POST("", todoHandler.CreateTodo)
-		protected.GET("/:id", todoHandler.GetTodo)
-		protected.GET("", todoHandler.GetTodos)
-		protected.PUT("/:id", todoHandler.UpdateTodo)
-		protected.DELETE("/:id", todoHandler.DeleteTodo)
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	passwords, err := server.PasswordHasher(cfg)
+	if err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+	policy, err := server.PasswordPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	mail, err := server.Mailer(cfg)
+	if err != nil {
+		log.Fatalf("Invalid mail settings: %v", err)
+	}
+	rp, oidcSettings, err := server.OIDC(cfg)
+	if err != nil {
+		log.Fatalf("Invalid single sign-on settings: %v", err)
 	}
 
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
 
-	admin := r.Group("/admin/todos")
-	admin.Use(middleware.AuthMiddleware(cfg.JWTSecret), middleware.AdminMiddleware())
-	{
-		admin.GET("", todoHandler.GetTodos)
+	if err := bootstrapAdmin(cfg, store, keys, passwords, policy); err != nil {
+		log.Fatalf("Failed to bootstrap admin: %v", err)
 	}
 
+	r := server.NewRouter(cfg, store, keys, passwords, policy, mail, rp, oidcSettings)
+
 	log.Printf("Server starting on port %s", cfg.Port)
 	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// openStore builds the repository store selected by cfg.StorageBackend.
+func openStore(cfg *config.Config) (*repositories.Store, error) {
+	switch cfg.StorageBackend {
+	case "memory":
+		log.Println("Using in-memory storage; data will not survive a restart")
+		return repositories.NewMemoryStore(), nil
+	case "database", "":
+		store, newMigrator, err := openDatabase(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.AutoMigrate {
+			if err := migrateUp(context.Background(), newMigrator); err != nil {
+				store.Close()
+				return nil, fmt.Errorf("auto-migrate: %w", err)
+			}
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/globallstudent/todo-project-go/internal/config"
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/migrate"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/migrations"
+)
+
+const migrateUsage = `usage: api migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    revert the last N migrations (default 1)
+  status      list migrations and their state
+  goto V      migrate up or down to version V (0 reverts everything)
+  force V     mark version V as the clean current version without running SQL`
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	ctx := context.Background()
+	store, newMigrator, err := openDatabase(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	m, closeMigrator, err := newMigrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		return m.Down(ctx, n)
+	case "goto", "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "force" {
+			return m.Force(ctx, version)
+		}
+		return m.Goto(ctx, version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// migratorFunc returns a migrator for an open database along with a function
+// releasing the connection it pinned.
+type migratorFunc func(ctx context.Context) (*migrate.Migrator, func(), error)
+
+// openDatabase connects to the database named by databaseURL, picking the
+// engine from its scheme, and returns its store and migrator factory.
+func openDatabase(databaseURL string) (*repositories.Store, migratorFunc, error) {
+	if database.IsSQLiteURL(databaseURL) {
+		db, err := database.NewSQLiteDB(databaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open sqlite database: %w", err)
+		}
+		newMigrator := func(ctx context.Context) (*migrate.Migrator, func(), error) {
+			driver, err := migrate.NewSQLiteDriver(ctx, db.DB)
+			if err != nil {
+				return nil, nil, err
+			}
+			return withDriver(driver, driver.Close, migrations.SQLite)
+		}
+		return repositories.NewSQLiteStore(db), newMigrator, nil
+	}
+
+	db, err := database.NewDB(databaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to database: %w", err)
+	}
+	newMigrator := func(ctx context.Context) (*migrate.Migrator, func(), error) {
+		driver, err := migrate.NewPostgresDriver(ctx, db.Pool)
+		if err != nil {
+			return nil, nil, err
+		}
+		return withDriver(driver, driver.Close, migrations.Postgres)
+	}
+	return repositories.NewPostgresStore(db), newMigrator, nil
+}
+
+func withDriver(driver migrate.Driver, closeDriver func(), fsys fs.FS) (*migrate.Migrator, func(), error) {
+	m, err := migrate.New(driver, fsys)
+	if err != nil {
+		closeDriver()
+		return nil, nil, err
+	}
+	return m, closeDriver, nil
+}
+
+// migrateUp applies every pending migration.
+func migrateUp(ctx context.Context, newMigrator migratorFunc) error {
+	m, closeMigrator, err := newMigrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeMigrator()
+	return m.Up(ctx)
+}
+
+func printStatus(statuses []migrate.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Dirty:
+			state = "dirty"
+		case s.Applied && s.Modified:
+			state = "modified"
+		case s.Applied:
+			state = "applied"
+		}
+		appliedAt := ""
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
@@ -0,0 +1,61 @@
+// Command mockidp is a stand-in OpenID Connect provider for trying out and
+// testing single sign-on locally. It logs every authorization request in as
+// one user without asking, and signs ID tokens with an RSA key generated at
+// startup. Never expose it: anyone reaching it can log in.
+//
+// Run it next to the API and point the API at it:
+//
+//	go run ./cmd/mockidp -addr :9000
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=todo-api OIDC_CLIENT_SECRET=secret go run ./cmd/api
+//
+// The user logged in is set with flags or changed while running by PUTting
+// a JSON object of ID token claims to /user.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/globallstudent/todo-project-go/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, as the API reaches it")
+	clientID := flag.String("client-id", "todo-api", "client ID of the API")
+	clientSecret := flag.String("client-secret", "secret", "client secret of the API, empty for a public client")
+	subject := flag.String("sub", "mock-user-1", "subject of the user")
+	username := flag.String("username", "mockuser", "preferred_username of the user")
+	name := flag.String("name", "Mock User", "name of the user")
+	email := flag.String("email", "mockuser@example.com", "email address of the user")
+	emailVerified := flag.Bool("email-verified", true, "whether the email address is verified")
+	groups := flag.String("groups", "", "comma-separated groups of the user")
+	flag.Parse()
+
+	p, err := oidctest.New(*issuer, *clientID, *clientSecret, map[string]any{
+		"sub":                *subject,
+		"preferred_username": *username,
+		"name":               *name,
+		"email":              *email,
+		"email_verified":     *emailVerified,
+		"groups":             splitList(*groups),
+	})
+	if err != nil {
+		log.Fatalf("Failed to start provider: %v", err)
+	}
+
+	log.Printf("Mock OpenID Connect provider %s listening on %s", p.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, p.Handler()))
+}
+
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -10,7 +13,103 @@ import (
 type Config struct {
 	DatabaseURL string
 	JWTSecret   string
-	Port        string
+	// JWTKeysDir is a directory of signing keys managed with "api keys".
+	// When set, tokens are signed with its active key instead of JWTSecret.
+	JWTKeysDir string
+	Port       string
+	// StorageBackend selects where data is kept: "database" (default) or
+	// "memory" for a throwaway in-process store.
+	StorageBackend string
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate bool
+	// AccessTokenTTL is how long an access token (JWT) stays valid.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long a refresh token stays valid. Every refresh
+	// issues a new one with a fresh lifetime.
+	RefreshTokenTTL time.Duration
+	// BootstrapAdminUsername and BootstrapAdminPassword name an admin account
+	// to create on startup while no enabled admin exists. An existing user
+	// of that name is never promoted.
+	BootstrapAdminUsername string
+	BootstrapAdminPassword string
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string
+	// LoginMaxFailures and LoginMaxIPFailures are how many failed logins of
+	// a username or from a client IP lock it out. The first lockout lasts
+	// LoginLockout and doubles with each further failure, up to
+	// LoginMaxLockout.
+	LoginMaxFailures   int
+	LoginMaxIPFailures int
+	LoginLockout       time.Duration
+	LoginMaxLockout    time.Duration
+	// TrustedProxies lists the addresses or CIDRs of reverse proxies whose
+	// X-Forwarded-For header tells the client IP. Without any, the IP is
+	// taken from the connection.
+	TrustedProxies []string
+	// PasswordHash is the algorithm new password hashes are made with,
+	// "argon2id" or "bcrypt". Hashes made with the other algorithm or
+	// other parameters are upgraded when their users log in.
+	PasswordHash string
+	// Argon2Memory (in KiB), Argon2Time and Argon2Parallelism are the
+	// argon2id cost parameters.
+	Argon2Memory      int
+	Argon2Time        int
+	Argon2Parallelism int
+	// BcryptCost is the bcrypt cost parameter.
+	BcryptCost int
+	// PasswordMinLength and PasswordMaxLength bound new passwords, in
+	// characters and bytes. PasswordMinClasses is how many character
+	// classes they must mix, and PasswordRejectUsername rejects passwords
+	// containing the username.
+	PasswordMinLength      int
+	PasswordMaxLength      int
+	PasswordMinClasses     int
+	PasswordRejectUsername bool
+	// PasswordBreachedCorpus is a file of SHA-1 hashes of breached
+	// passwords, which new passwords must not be.
+	PasswordBreachedCorpus string
+	// Mailer selects how emails are sent: "file" writes them to .eml files
+	// in MailDir, "smtp" sends them through SMTPHost and "log", meant for
+	// development only, writes them to the log. Unset, no emails are sent
+	// and password resets and email verification are unavailable.
+	// MailFrom is their sender.
+	Mailer       string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// AppURL is the base URL of the links in emails.
+	AppURL string
+	// PasswordResetTTL and EmailVerificationTTL are how long password reset
+	// and email verification links work.
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// PasswordResetMinEmailAge is how long an email address has to have
+	// been verified before password reset links are mailed to it.
+	PasswordResetMinEmailAge time.Duration
+	// RequireVerifiedEmail limits creating personal access tokens to users
+	// with a verified email address.
+	RequireVerifiedEmail bool
+	// OIDCIssuer enables logging in with an OpenID Connect provider, as
+	// the client OIDCClientID with OIDCClientSecret. The provider sends
+	// users back to OIDCRedirectURL.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	// OIDCGroupsClaim is the ID token claim listing the user's groups, and
+	// OIDCRoleMapping maps them to roles as group=role items, first match
+	// wins.
+	OIDCGroupsClaim string
+	OIDCRoleMapping []string
+	// OIDCAutoProvision creates users for new identities, and
+	// OIDCLinkByEmail links them to users with the same verified email
+	// address.
+	OIDCAutoProvision bool
+	OIDCLinkByEmail   bool
 }
 
 func LoadConfig() *Config {
@@ -23,7 +122,61 @@ func LoadConfig() *Config {
 	return &Config{
 		DatabaseURL: getEnv("DATABASE_URL", ""),
 		JWTSecret:   getEnv("JWT_SECRET", ""),
+		JWTKeysDir:  getEnv("JWT_KEYS_DIR", ""),
 		Port:        getEnv("PORT", "8080"),
+
+		StorageBackend: getEnv("STORAGE_BACKEND", "database"),
+		AutoMigrate:    getEnvBool("AUTO_MIGRATE", false),
+
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		BootstrapAdminUsername: getEnv("BOOTSTRAP_ADMIN_USERNAME", ""),
+		BootstrapAdminPassword: getEnv("BOOTSTRAP_ADMIN_PASSWORD", ""),
+
+		MFAIssuer: getEnv("MFA_ISSUER", "Todo API"),
+
+		LoginMaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxIPFailures: getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+		LoginLockout:       getEnvDuration("LOGIN_LOCKOUT", time.Minute),
+		LoginMaxLockout:    getEnvDuration("LOGIN_MAX_LOCKOUT", time.Hour),
+		TrustedProxies:     getEnvList("TRUSTED_PROXIES"),
+
+		PasswordHash:      getEnv("PASSWORD_HASH", "argon2id"),
+		Argon2Memory:      getEnvInt("ARGON2_MEMORY", 19*1024),
+		Argon2Time:        getEnvInt("ARGON2_TIME", 2),
+		Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 1),
+		BcryptCost:        getEnvInt("BCRYPT_COST", 10),
+
+		PasswordMinLength:      getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:      getEnvInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordMinClasses:     getEnvInt("PASSWORD_MIN_CLASSES", 1),
+		PasswordRejectUsername: getEnvBool("PASSWORD_REJECT_USERNAME", true),
+		PasswordBreachedCorpus: getEnv("PASSWORD_BREACHED_CORPUS", ""),
+
+		Mailer:       getEnv("MAILER", ""),
+		MailFrom:     getEnv("MAIL_FROM", "Todo API <noreply@localhost>"),
+		MailDir:      getEnv("MAIL_DIR", "mail"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		AppURL:                   getEnv("APP_URL", "http://localhost:8080"),
+		PasswordResetTTL:         getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		EmailVerificationTTL:     getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		PasswordResetMinEmailAge: getEnvDuration("PASSWORD_RESET_MIN_EMAIL_AGE", 24*time.Hour),
+		RequireVerifiedEmail:     getEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+
+		OIDCIssuer:        getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:        getEnvList("OIDC_SCOPES"),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:   getEnvList("OIDC_ROLE_MAPPING"),
+		OIDCAutoProvision: getEnvBool("OIDC_AUTO_PROVISION", true),
+		OIDCLinkByEmail:   getEnvBool("OIDC_LINK_BY_EMAIL", false),
 	}
 }
 
@@ -34,3 +187,53 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s: %q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid number for %s: %q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvList splits a comma-separated variable, dropping empty items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid duration for %s: %q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// SQLiteScheme is the DATABASE_URL prefix selecting the SQLite backend, e.g.
+// sqlite://todo.db, sqlite:///var/lib/todo/todo.db or sqlite://:memory:.
+const SQLiteScheme = "sqlite://"
+
+type SQLiteDB struct {
+	DB *sql.DB
+}
+
+// IsSQLiteURL reports whether databaseURL selects the SQLite backend.
+func IsSQLiteURL(databaseURL string) bool {
+	return strings.HasPrefix(databaseURL, SQLiteScheme)
+}
+
+func NewSQLiteDB(databaseURL string) (*SQLiteDB, error) {
+	path := strings.TrimPrefix(databaseURL, SQLiteScheme)
+	if path == "" {
+		return nil, errors.New("sqlite database path is empty")
+	}
+	path, params, _ := strings.Cut(path, "?")
+
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	if params != "" {
+		dsn += "&" + params
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time; funnelling everything through
+	// one connection avoids SQLITE_BUSY and keeps :memory: databases shared.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Printf("Opened SQLite database %s", path)
+	return &SQLiteDB{DB: db}, nil
+}
+
+func (db *SQLiteDB) Close() {
+	db.DB.Close()
+	log.Println("SQLite database closed")
+}
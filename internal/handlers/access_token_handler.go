@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+type AccessTokenHandler struct {
+	accessTokenService *services.AccessTokenService
+}
+
+func NewAccessTokenHandler(accessTokenService *services.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{accessTokenService: accessTokenService}
+}
+
+type accessTokenInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAccessToken creates a personal access token
+// @Summary Create a personal access token
+// @Description Creates a named token for scripts and integrations, sent as a Bearer token like a login token. Scopes limit it to areas of the API: todos, tags, projects and admin, each with a read scope for GET requests and a write scope for everything. The token is only shown in this response. Personal access tokens cannot create other tokens.
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param token body object{name=string,scopes=[]string,expires_at=string} true "Token name, scopes and optional RFC 3339 expiry"
+// @Success 201 {object} models.PersonalAccessToken
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /tokens [post]
+func (h *AccessTokenHandler) CreateAccessToken(c *gin.Context) {
+	var input accessTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	token, err := h.accessTokenService.CreateToken(c.Request.Context(), userID.(int), input.Name, input.Scopes, input.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTokenName) || errors.Is(err, services.ErrInvalidScope) ||
+			errors.Is(err, services.ErrInvalidExpiry) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// GetAccessTokens lists the user's personal access tokens
+// @Summary List personal access tokens
+// @Description Lists the authenticated user's tokens, newest first, with their scopes, expiry and when they were last used. The tokens themselves are not shown.
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.PersonalAccessToken
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /tokens [get]
+func (h *AccessTokenHandler) GetAccessTokens(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	tokens, err := h.accessTokenService.ListTokens(c.Request.Context(), userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeAccessToken revokes a personal access token
+// @Summary Revoke a personal access token
+// @Description Deletes one of the authenticated user's tokens; requests using it fail from then on.
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Token ID"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /tokens/{id} [delete]
+func (h *AccessTokenHandler) RevokeAccessToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.accessTokenService.RevokeToken(c.Request.Context(), userID.(int), id); err != nil {
+		if errors.Is(err, services.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+}
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+	policy       *services.PolicyService
+}
+
+func NewAdminHandler(adminService *services.AdminService, policy *services.PolicyService) *AdminHandler {
+	return &AdminHandler{adminService: adminService, policy: policy}
+}
+
+type roleInput struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListUsers lists and searches user accounts
+// @Summary List users (users:read)
+// @Description Lists user accounts ordered by username, optionally searched by username and filtered by role or disabled state.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Case-insensitive text matched against the username"
+// @Param role query string false "Role"
+// @Param disabled query bool false "Disabled state"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {array} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/users [get]
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	filter := models.UserFilter{Query: c.Query("q"), Role: c.Query("role")}
+	if v := c.Query("disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid disabled"})
+			return
+		}
+		filter.Disabled = &disabled
+	}
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+				return
+			}
+			*dst = n
+		}
+	}
+
+	users, err := h.adminService.ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUser retrieves a user account
+// @Summary Get a user (users:read)
+// @Description Retrieves a user account by its ID.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /admin/users/{id} [get]
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, ok := h.user(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// PromoteUser makes a user an admin
+// @Summary Promote a user to admin (admin only)
+// @Description Gives a user the admin role, which holds every permission.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /admin/users/{id}/promote [post]
+func (h *AdminHandler) PromoteUser(c *gin.Context) {
+	h.changeUser(c, func(ctx context.Context, user *models.User) error {
+		return h.adminService.SetRole(ctx, principal(c), user, models.RoleAdmin)
+	})
+}
+
+// DemoteUser makes an admin a regular user
+// @Summary Demote an admin to user (admin only)
+// @Description Takes the admin role away from a user. The last enabled admin cannot be demoted.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /admin/users/{id}/demote [post]
+func (h *AdminHandler) DemoteUser(c *gin.Context) {
+	h.changeUser(c, func(ctx context.Context, user *models.User) error {
+		return h.adminService.SetRole(ctx, principal(c), user, models.RoleUser)
+	})
+}
+
+// DisableUser disables a user account
+// @Summary Disable a user (users:manage)
+// @Description Disables an account and ends all of its sessions. Disabled users cannot log in or use existing tokens. Only admins can disable admins, and the last enabled admin cannot be disabled.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /admin/users/{id}/disable [post]
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.changeUser(c, func(ctx context.Context, user *models.User) error {
+		return h.adminService.SetDisabled(ctx, principal(c), user, true)
+	})
+}
+
+// EnableUser re-enables a disabled user account
+// @Summary Enable a user (users:manage)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /admin/users/{id}/enable [post]
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.changeUser(c, func(ctx context.Context, user *models.User) error {
+		return h.adminService.SetDisabled(ctx, principal(c), user, false)
+	})
+}
+
+// DeleteUser deletes a user account
+// @Summary Delete a user (users:manage)
+// @Description Deletes an account together with its todos, projects and tags. Only admins can delete admins, and the last enabled admin cannot be deleted.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /admin/users/{id} [delete]
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	user, ok := h.user(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.DeleteUser(c.Request.Context(), principal(c), user); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
+
+// ResetUserMFA turns off two-factor authentication for a user
+// @Summary Reset two-factor authentication of a user (users:manage)
+// @Description Removes a user's authenticator and recovery codes, for users who lost both. They can then log in with their password alone and enroll again. Only admins can reset admins.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /admin/users/{id}/mfa [delete]
+func (h *AdminHandler) ResetUserMFA(c *gin.Context) {
+	user, ok := h.user(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.ResetMFA(c.Request.Context(), principal(c), user); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication reset"})
+}
+
+// UnlockUser lifts a login lockout of a user
+// @Summary Unlock a user locked out by failed logins (users:manage)
+// @Description Lifts the lockout of a username caused by failed logins and forgets its failures. Lockouts of the client IPs involved stay in place.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /admin/users/{id}/unlock [post]
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	user, ok := h.user(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.UnlockUser(c.Request.Context(), user); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+}
+
+// ListLockouts lists login lockouts
+// @Summary List login lockouts (users:read)
+// @Description Lists the times a username or client IP was locked out by failed logins, newest first.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param kind query string false "username or ip"
+// @Param subject query string false "Username or IP address"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Number of events to skip"
+// @Success 200 {array} models.LockoutEvent
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/lockouts [get]
+func (h *AdminHandler) ListLockouts(c *gin.Context) {
+	filter := models.LockoutFilter{Kind: c.Query("kind"), Subject: c.Query("subject")}
+	if filter.Kind != "" && filter.Kind != models.ThrottleUsername && filter.Kind != models.ThrottleIP {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid kind"})
+		return
+	}
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+				return
+			}
+			*dst = n
+		}
+	}
+
+	events, err := h.adminService.ListLockouts(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// SetUserRole assigns a role to a user
+// @Summary Set the role of a user (roles:manage)
+// @Description Assigns any existing role to a user. Only admins can change the role of an admin or make someone an admin, and the last enabled admin keeps the admin role.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param role body object{role=string} true "Role name"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /admin/users/{id}/role [put]
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.changeUser(c, func(ctx context.Context, user *models.User) error {
+		return h.adminService.SetRole(ctx, principal(c), user, input.Role)
+	})
+}
+
+// ListPermissions lists the permissions roles can be granted
+// @Summary List permissions (roles:manage)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/permissions [get]
+func (h *AdminHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Permissions)
+}
+
+// ListRoles lists the roles and their permissions
+// @Summary List roles (roles:manage)
+// @Description Lists every role with the permissions it grants. The admin role always holds every permission.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/roles [get]
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	roles, err := h.policy.ListRoles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetRole retrieves a role
+// @Summary Get a role (roles:manage)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} models.Role
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /admin/roles/{name} [get]
+func (h *AdminHandler) GetRole(c *gin.Context) {
+	role, err := h.policy.GetRole(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// SaveRole creates a role or replaces its permissions
+// @Summary Create or update a role (roles:manage)
+// @Description Creates the role or replaces its description and permissions. Changes apply to the next request of every holder. The admin role cannot be changed.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Param role body object{description=string,permissions=[]string} true "Role data"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /admin/roles/{name} [put]
+func (h *AdminHandler) SaveRole(c *gin.Context) {
+	var input roleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := &models.Role{Name: c.Param("name"), Description: input.Description, Permissions: input.Permissions}
+	if err := h.policy.SaveRole(c.Request.Context(), role); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a role
+// @Summary Delete a role (roles:manage)
+// @Description Deletes a role nobody holds. The admin and user roles cannot be deleted.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} object{message=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /admin/roles/{name} [delete]
+func (h *AdminHandler) DeleteRole(c *gin.Context) {
+	if err := h.policy.DeleteRole(c.Request.Context(), c.Param("name")); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+}
+
+func (h *AdminHandler) changeUser(c *gin.Context, change func(context.Context, *models.User) error) {
+	user, ok := h.user(c)
+	if !ok {
+		return
+	}
+
+	if err := change(c.Request.Context(), user); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// user loads the user named by the id parameter, writing the error response
+// if there is none.
+func (h *AdminHandler) user(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	user, err := h.adminService.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+func writeAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrLastAdmin), errors.Is(err, services.ErrRoleInUse),
+		errors.Is(err, services.ErrAdminRole), errors.Is(err, services.ErrBuiltinRole):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRoleName), errors.Is(err, services.ErrUnknownPermission),
+		errors.Is(err, services.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
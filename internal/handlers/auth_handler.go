@@ -1,10 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
 	"github.com/globallstudent/todo-project-go/internal/services"
+	"github.com/globallstudent/todo-project-go/internal/utils"
 )
 
 type AuthHandler struct {
@@ -17,20 +25,19 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Creates a new user account with the specified username, password, and role.
+// @Description Creates a new user account with the specified username and password. Accounts are always created with the user role; admins are bootstrapped from the command line or promoted by another admin. Passwords failing the password policy are rejected with the list of requirements they fail.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param user body object{username=string,password=string,role=string} true "User registration data"
+// @Param user body object{username=string,password=string} true "User registration data"
 // @Success 201 {object} object{user=object{id=int,username=string,role=string,created_at=string}}
-// @Failure 400 {object} object{error=string}
+// @Failure 400 {object} object{error=string,violations=[]passpolicy.Violation}
 // @Router /register [post]
 
 func (h *AuthHandler) Register(c *gin.Context) {
 	var input struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
-		Role     string `json:"role" binding:"required,oneof=user admin"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -38,8 +45,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Register(c.Request.Context(), input.Username, input.Password, input.Role)
+	user, err := h.authService.Register(c.Request.Context(), input.Username, input.Password)
 	if err != nil {
+		if writePasswordRejected(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -49,14 +59,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 // Login handles user login
 // @Summary Login a user
-// @Description Authenticates a user and returns a JWT token.
+// @Description Authenticates a user and returns a short-lived JWT access token together with a refresh token for POST /token/refresh. expires_in is the access token lifetime in seconds. Accounts with two-factor authentication get an MFA challenge instead (models.MFAChallenge, with mfa_required set), completed at POST /login/mfa. Repeated failed logins lock out the username or client IP for exponentially longer; locked out logins get 429 with a Retry-After header.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param credentials body object{username=string,password=string} true "User login credentials"
-// @Success 200 {object} object{token=string}
+// @Success 200 {object} models.TokenPair
 // @Failure 400 {object} object{error=string}
 // @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
 // @Router /login [post]
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -69,12 +82,176 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// No account has a longer username, and it would not fit the
+	// throttling counters.
+	if utf8.RuneCountInString(input.Username) > services.MaxUsernameLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("username must be at most %d characters", services.MaxUsernameLength),
+		})
+		return
+	}
 
-	token, err := h.authService.Login(c.Request.Context(), input.Username, input.Password)
+	tokens, challenge, err := h.authService.Login(c.Request.Context(), input.Username, input.Password, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if writeLoginLocked(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Login of %q failed: %v", input.Username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed, please try again later"})
+		}
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LoginMFA completes a login with a second factor
+// @Summary Complete a login with a second factor
+// @Description Exchanges the mfa_token from POST /login and a code from the user's authenticator app, or one of their recovery codes, for the tokens a login returns. Each code works once. After 5 wrong codes the mfa_token stops working and the login has to start over. Wrong codes count as failed logins of the user.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body object{mfa_token=string,code=string} true "MFA token and code"
+// @Success 200 {object} models.TokenPair
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Router /login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.authService.LoginMFA(c.Request.Context(), input.MFAToken, input.Code, c.ClientIP())
+	if err != nil {
+		if writeLoginLocked(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUserDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidMFAToken), errors.Is(err, services.ErrInvalidMFACode),
+			errors.Is(err, services.ErrMFANotEnabled):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh exchanges a refresh token for new tokens
+// @Summary Refresh an access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can only be used once; presenting a used one revokes every token issued from the same login.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body object{refresh_token=string} true "Refresh token"
+// @Success 200 {object} models.TokenPair
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Router /token/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) ||
+			errors.Is(err, services.ErrUserDisabled) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the current session
+// @Summary Log out
+// @Description Revokes the access token used for the request and the refresh tokens of the login it came from.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{message=string}
+// @Failure 401 {object} object{error=string}
+// @Router /logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, _ := c.Get("claims")
+	if err := h.authService.Logout(c.Request.Context(), claims.(*utils.Claims)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// LogoutAll ends every session of the user
+// @Summary Log out everywhere
+// @Description Revokes every access and refresh token issued to the authenticated user so far, on all devices.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{message=string}
+// @Failure 401 {object} object{error=string}
+// @Router /logout/all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	if err := h.authService.LogoutAll(c.Request.Context(), userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
+}
+
+// writeLoginLocked answers a locked out login with 429 and a Retry-After
+// header in whole seconds, reporting whether err was such a lockout.
+func writeLoginLocked(c *gin.Context, err error) bool {
+	var locked *services.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	return true
+}
+
+// writePasswordRejected answers 400 with the failed requirements if err
+// rejects a password for failing the password policy, and reports whether it
+// did.
+func writePasswordRejected(c *gin.Context, err error) bool {
+	var rejected *passpolicy.Error
+	if !errors.As(err, &rejected) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": passpolicy.ErrRejected.Error(), "violations": rejected.Violations})
+	return true
 }
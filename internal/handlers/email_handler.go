@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+type EmailHandler struct {
+	emailService *services.EmailService
+}
+
+func NewEmailHandler(emailService *services.EmailService) *EmailHandler {
+	return &EmailHandler{emailService: emailService}
+}
+
+// ForgotPassword mails a password reset link
+// @Summary Request a password reset link
+// @Description Mails a single-use link for choosing a new password to the account with the email address, if it has verified that address. The answer is the same whether or not such an account exists.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param email body object{email=string} true "Email address of the account"
+// @Success 202 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 503 {object} object{error=string}
+// @Router /password/forgot [post]
+func (h *EmailHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.emailService.ForgotPassword(c.Request.Context(), input.Email); err != nil {
+		writeEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an account has this verified email address, a reset link is on its way"})
+}
+
+// ResetPassword sets a new password with a reset token
+// @Summary Reset a forgotten password
+// @Description Sets a new password with the token from a password reset link and logs out every session of the account. A token works once: it stops working when the password changes.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param reset body object{token=string,new_password=string} true "Reset token and new password"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string,violations=[]passpolicy.Violation}
+// @Failure 403 {object} object{error=string}
+// @Failure 503 {object} object{error=string}
+// @Router /password/reset [post]
+func (h *EmailHandler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.emailService.ResetPassword(c.Request.Context(), input.Token, input.NewPassword); err != nil {
+		writeEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset, all sessions logged out"})
+}
+
+// SendVerification mails an email verification link
+// @Summary Verify your email address
+// @Description Mails a link verifying the email address of the authenticated user. Changing the address sends one as well.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} object{message=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 503 {object} object{error=string}
+// @Router /me/email/verification [post]
+func (h *EmailHandler) SendVerification(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.emailService.SendVerification(c.Request.Context(), userID.(int)); err != nil {
+		writeEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification link sent"})
+}
+
+// VerifyEmail verifies an email address with a verification token
+// @Summary Confirm an email address
+// @Description Marks the email address a verification link was sent to as verified. The token is taken from the token query parameter, as in the link itself, or from the JSON body.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token"
+// @Param verification body object{token=string} false "Verification token"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Router /email/verify [get]
+// @Router /email/verify [post]
+func (h *EmailHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" && c.Request.Method == http.MethodPost {
+		var input struct {
+			Token string `json:"token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		token = input.Token
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token required"})
+		return
+	}
+
+	if err := h.emailService.VerifyEmail(c.Request.Context(), token); err != nil {
+		writeEmailError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email address verified"})
+}
+
+func writeEmailError(c *gin.Context, err error) {
+	if writePasswordRejected(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidResetToken), errors.Is(err, services.ErrInvalidVerificationToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoEmail), errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMailCooldown):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMailDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+)
+
+type JWKSHandler struct {
+	keys *keyset.Keyset
+}
+
+func NewJWKSHandler(keys *keyset.Keyset) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS publishes the token verification keys
+// @Summary Get the token verification keys
+// @Description Returns the public keys that verify access tokens as a JSON Web Key Set. Tokens name their key in the kid header. The set is empty while tokens are signed with a shared HS256 secret.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} keyset.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
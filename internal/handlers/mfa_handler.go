@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+type MFAHandler struct {
+	mfaService *services.MFAService
+}
+
+func NewMFAHandler(mfaService *services.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+type mfaCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetMFAStatus describes the user's second factors
+// @Summary Get two-factor authentication status
+// @Description Reports whether the authenticated user has two-factor authentication enabled and how many unused recovery codes they have left.
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.MFAStatus
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /mfa [get]
+func (h *MFAHandler) GetMFAStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	status, err := h.mfaService.Status(c.Request.Context(), userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP starts enrolling an authenticator app
+// @Summary Start enrolling an authenticator app
+// @Description Generates a TOTP secret and its otpauth:// URI, to be shown as a QR code or typed into an authenticator app. Logins are unaffected until the enrollment is confirmed at POST /mfa/totp/confirm. Starting again replaces an unconfirmed secret.
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} models.TOTPSetup
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /mfa/totp [post]
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	setup, err := h.mfaService.EnrollTOTP(c.Request.Context(), userID.(int), c.GetString("username"))
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, setup)
+}
+
+// ConfirmTOTP enables two-factor authentication
+// @Summary Confirm an authenticator app
+// @Description Enables two-factor authentication once a code from the newly enrolled app checks out, and returns recovery codes that each stand in for a code once. They are only shown in this response.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body object{code=string} true "Code from the authenticator app"
+// @Success 200 {object} object{recovery_codes=[]string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /mfa/totp/confirm [post]
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var input mfaCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	codes, err := h.mfaService.ConfirmTOTP(c.Request.Context(), userID.(int), input.Code)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP turns two-factor authentication off
+// @Summary Disable two-factor authentication
+// @Description Removes the authenticator app and recovery codes after checking a code from the app or a recovery code.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body object{code=string} true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Router /mfa/totp/disable [post]
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var input mfaCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.mfaService.DisableTOTP(c.Request.Context(), userID.(int), c.GetString("username"), input.Code,
+		c.ClientIP()); err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replaces all recovery codes after checking a code from the authenticator app or a recovery code. The new codes are only shown in this response.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body object{code=string} true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} object{recovery_codes=[]string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Router /mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var input mfaCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID.(int),
+		c.GetString("username"), input.Code, c.ClientIP())
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func writeMFAError(c *gin.Context, err error) {
+	if writeLoginLocked(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrNoTOTPEnrollment):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+// The flow cookie carries the flow token of a login from its start to the
+// provider's callback.
+const (
+	oidcFlowCookie     = "oidc_flow"
+	oidcFlowCookiePath = "/oidc"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	// secureCookie marks the flow cookie Secure, for APIs served over
+	// HTTPS.
+	secureCookie bool
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService, secureCookie bool) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService, secureCookie: secureCookie}
+}
+
+// Login starts a single sign-on login
+// @Summary Log in with single sign-on
+// @Description Redirects the browser to the OpenID Connect provider to log in. A cookie ties the login to the browser until the provider sends it back to GET /oidc/callback. With reauth=true the provider is asked to authenticate the user again; users without a password log in like this to confirm changes to their account.
+// @Tags auth
+// @Param reauth query bool false "Ask the provider to authenticate the user again"
+// @Success 302
+// @Failure 400 {object} object{error=string}
+// @Failure 502 {object} object{error=string}
+// @Router /oidc/login [get]
+func (h *OIDCHandler) Login(c *gin.Context) {
+	var reauth bool
+	if v := c.Query("reauth"); v != "" {
+		var err error
+		if reauth, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid reauth %q", v)})
+			return
+		}
+	}
+
+	login, err := h.oidcService.Begin(c.Request.Context(), reauth)
+	if err != nil {
+		writeOIDCError(c, err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, login.FlowToken, int(login.ExpiresIn.Seconds()), oidcFlowCookiePath, "",
+		h.secureCookie, true)
+	c.Redirect(http.StatusFound, login.URL)
+}
+
+// Callback completes a single sign-on login
+// @Summary Complete a single sign-on login
+// @Description Where the OpenID Connect provider sends the browser back to. Exchanges the code for an ID token, finds the account linked to the identity, linking or creating one the first time, and starts a session like POST /login does. Accounts with two-factor authentication get an MFA challenge instead (models.MFAChallenge, with mfa_required set), completed at POST /login/mfa.
+// @Tags auth
+// @Produce json
+// @Param code query string false "Authorization code"
+// @Param state query string true "State of the login"
+// @Param error query string false "Error from the provider"
+// @Success 200 {object} models.TokenPair
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 502 {object} object{error=string}
+// @Router /oidc/callback [get]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	flowToken, _ := c.Cookie(oidcFlowCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, "", -1, oidcFlowCookiePath, "", h.secureCookie, true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		message := "single sign-on refused: " + providerErr
+		if description := c.Query("error_description"); description != "" {
+			message += ": " + description
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+		return
+	}
+
+	tokens, challenge, err := h.oidcService.Complete(c.Request.Context(), flowToken, c.Query("state"), c.Query("code"))
+	if err != nil {
+		writeOIDCError(c, err)
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func writeOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidOIDCLogin):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoLinkedAccount), errors.Is(err, services.ErrUserDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOIDCProvider):
+		c.JSON(http.StatusBadGateway, gin.H{"error": services.ErrOIDCProvider.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+// principal returns the caller authenticated by AuthMiddleware.
+func principal(c *gin.Context) *models.Principal {
+	return &models.Principal{UserID: c.GetInt("user_id"), Role: c.GetString("role")}
+}
+
+// writeForbidden writes the response for a failed authorization check.
+func writeForbidden(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
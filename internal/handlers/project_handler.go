@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+type ProjectHandler struct {
+	projectService *services.ProjectService
+	todoService    *services.TodoService
+	policy         *services.PolicyService
+}
+
+func NewProjectHandler(projectService *services.ProjectService, todoService *services.TodoService,
+	policy *services.PolicyService) *ProjectHandler {
+	return &ProjectHandler{projectService: projectService, todoService: todoService, policy: policy}
+}
+
+type projectInput struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	Archived    bool   `json:"archived"`
+}
+
+// CreateProject creates a new project
+// @Summary Create a project
+// @Description Creates a project for the authenticated user.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param project body object{name=string,description=string,color=string,archived=bool} true "Project data"
+// @Success 201 {object} models.Project
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Router /projects [post]
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	var input projectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	project := &models.Project{
+		UserID:      userID.(int),
+		Name:        input.Name,
+		Description: input.Description,
+		Color:       input.Color,
+		Archived:    input.Archived,
+	}
+
+	if err := h.projectService.CreateProject(c.Request.Context(), project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+// GetProjects lists the user's projects
+// @Summary List projects
+// @Description Lists the authenticated user's projects, Inbox first. Archived projects are only listed with archived=true.
+// @Tags projects
+// @Produce json
+// @Security BearerAuth
+// @Param archived query bool false "Include archived projects"
+// @Success 200 {array} models.Project
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Router /projects [get]
+func (h *ProjectHandler) GetProjects(c *gin.Context) {
+	includeArchived := false
+	if v := c.Query("archived"); v != "" {
+		var err error
+		if includeArchived, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid archived"})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	projects, err := h.projectService.GetProjectsByUserID(c.Request.Context(), userID.(int), includeArchived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetProject retrieves a project by ID
+// @Summary Get a project by ID
+// @Description Retrieves a project by its ID. Users can only access their own projects unless their role grants projects:read:any.
+// @Tags projects
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} models.Project
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /projects/{id} [get]
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	project, ok := h.ownedProject(c, models.PermProjectsReadAny)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// GetProjectTodos lists the todos of a project
+// @Summary List todos of a project
+// @Description Lists the todos of a project, archived or not, accepting the same filters as /todos. Users can only list their own projects unless their role grants projects:read:any.
+// @Tags projects
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Param completed query bool false "Completion state"
+// @Param created_after query string false "RFC 3339 lower bound (inclusive) on created_at"
+// @Param created_before query string false "RFC 3339 upper bound (exclusive) on created_at"
+// @Param q query string false "Case-insensitive text matched against title and description"
+// @Param tags query string false "Comma separated tag IDs"
+// @Param tag_mode query string false "Whether todos need any or all of the tags" Enums(any, all)
+// @Param sort query string false "Sort field" Enums(created_at, title, completed, due_at, priority)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /projects/{id}/todos [get]
+func (h *ProjectHandler) GetProjectTodos(c *gin.Context) {
+	project, ok := h.ownedProject(c, models.PermProjectsReadAny)
+	if !ok {
+		return
+	}
+
+	opts, err := parseTodoListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Filter.UserID = nil
+	opts.Filter.ProjectID = &project.ID
+
+	listTodos(c, h.todoService, opts)
+}
+
+// UpdateProject updates a project
+// @Summary Update a project
+// @Description Renames, describes, recolors or archives a project. Archiving hides the project's todos from default listings without deleting them. The Inbox cannot be archived. Users can only update their own projects unless their role grants projects:write:any.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Param project body object{name=string,description=string,color=string,archived=bool} true "Project data"
+// @Success 200 {object} models.Project
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /projects/{id} [put]
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	project, ok := h.ownedProject(c, models.PermProjectsWriteAny)
+	if !ok {
+		return
+	}
+
+	var input projectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	project.Name = input.Name
+	project.Description = input.Description
+	project.Color = input.Color
+	project.Archived = input.Archived
+
+	if err := h.projectService.UpdateProject(c.Request.Context(), project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject deletes a project
+// @Summary Delete a project
+// @Description Deletes a project and moves its todos to the owner's Inbox. The Inbox cannot be deleted. Users can only delete their own projects unless their role grants projects:write:any.
+// @Tags projects
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /projects/{id} [delete]
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	project, ok := h.ownedProject(c, models.PermProjectsWriteAny)
+	if !ok {
+		return
+	}
+
+	if err := h.projectService.DeleteProject(c.Request.Context(), project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "project deleted"})
+}
+
+// ownedProject loads the project named by the id parameter and checks that
+// the caller may act on it with permission, writing the error response if
+// not.
+func (h *ProjectHandler) ownedProject(c *gin.Context, permission string) (*models.Project, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	project, err := h.projectService.GetProjectByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return nil, false
+	}
+
+	if err := h.policy.Authorize(c.Request.Context(), principal(c), project.UserID, permission); err != nil {
+		writeForbidden(c, err)
+		return nil, false
+	}
+
+	return project, true
+}
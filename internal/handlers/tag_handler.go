@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+type TagHandler struct {
+	tagService *services.TagService
+	policy     *services.PolicyService
+}
+
+func NewTagHandler(tagService *services.TagService, policy *services.PolicyService) *TagHandler {
+	return &TagHandler{tagService: tagService, policy: policy}
+}
+
+type tagInput struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
+
+// CreateTag creates a new tag
+// @Summary Create a tag
+// @Description Creates a tag for the authenticated user. Names are unique per user, ignoring case.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param tag body object{name=string,color=string} true "Tag data"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Router /tags [post]
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	var input tagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tag := &models.Tag{UserID: userID.(int), Name: input.Name, Color: input.Color}
+
+	if err := h.tagService.CreateTag(c.Request.Context(), tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// GetTags lists the user's tags
+// @Summary List tags
+// @Description Lists the authenticated user's tags with the number of todos using each.
+// @Tags tags
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Tag
+// @Failure 401 {object} object{error=string}
+// @Router /tags [get]
+func (h *TagHandler) GetTags(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	tags, err := h.tagService.GetTagsByUserID(c.Request.Context(), userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// UpdateTag updates a tag
+// @Summary Update a tag
+// @Description Renames or recolors a tag. Users can only update their own tags unless their role grants tags:write:any.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Param tag body object{name=string,color=string} true "Tag data"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /tags/{id} [put]
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	tag, ok := h.ownedTag(c)
+	if !ok {
+		return
+	}
+
+	var input tagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tag.Name = input.Name
+	tag.Color = input.Color
+
+	if err := h.tagService.UpdateTag(c.Request.Context(), tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag deletes a tag
+// @Summary Delete a tag
+// @Description Deletes a tag and detaches it from every todo. Users can only delete their own tags unless their role grants tags:write:any.
+// @Tags tags
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /tags/{id} [delete]
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	tag, ok := h.ownedTag(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tagService.DeleteTag(c.Request.Context(), tag.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag deleted"})
+}
+
+// ownedTag loads the tag named by the id parameter and checks that the
+// caller may modify it, writing the error response if not.
+func (h *TagHandler) ownedTag(c *gin.Context) (*models.Tag, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	tag, err := h.tagService.GetTagByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return nil, false
+	}
+
+	if err := h.policy.Authorize(c.Request.Context(), principal(c), tag.UserID, models.PermTagsWriteAny); err != nil {
+		writeForbidden(c, err)
+		return nil, false
+	}
+
+	return tag, true
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/search"
 	"github.com/globallstudent/todo-project-go/internal/services"
 )
 
@@ -19,7 +25,7 @@ func NewTodoHandler(todoService *services.TodoService) *TodoHandler {
 
 // CreateTodo creates a new todo
 // @Summary Create a new todo
-// @Description Creates a new todo item for the authenticated user.
+// @Description Creates a new todo item for the authenticated user, in the given project or else in the user's Inbox. Setting parent_id makes it a subtask, filed in its parent's project by default. Setting recurrence to an RRULE (FREQ, INTERVAL, BYDAY, BYMONTHDAY, COUNT, UNTIL) starts a series from the todo's due date.
 // @Tags todos
 // @Accept json
 // @Produce json
@@ -49,31 +55,222 @@ func (h *TodoHandler) CreateTodo(c *gin.Context) {
 }
 
 func (h *TodoHandler) GetTodo(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+	todo, ok := h.todo(c, models.PermTodosReadAny)
+	if !ok {
+		return
+	}
+
+	if err := h.todoService.LoadDependencies(c.Request.Context(), todo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
+
+// GetTodo retrieves a todo by ID
+// @Summary Get a todo by ID
+// @Description Retrieves a todo item by its ID, with the todos blocking it (blocked_by) and those it blocks (blocking). Users can only access their own todos unless their role grants todos:read:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /todos/{id} [get]
+
+// GetTodos lists the todos of the authenticated user
+// @Summary List todos
+// @Description Lists the authenticated user's todos one page at a time. Pass the returned next_cursor as cursor to fetch the following page. Todos of archived projects are left out unless include_archived or project_id is given.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param completed query bool false "Completion state"
+// @Param created_after query string false "RFC 3339 lower bound (inclusive) on created_at"
+// @Param created_before query string false "RFC 3339 upper bound (exclusive) on created_at"
+// @Param q query string false "Case-insensitive text matched against title and description"
+// @Param tags query string false "Comma separated tag IDs"
+// @Param tag_mode query string false "Whether todos need any or all of the tags" Enums(any, all)
+// @Param project_id query int false "Project ID"
+// @Param include_archived query bool false "Include todos of archived projects"
+// @Param parent_id query int false "Only the direct subtasks of this todo"
+// @Param view query string false "Planning view, computed in the user's timezone" Enums(today, upcoming, overdue, someday)
+// @Param tz query string false "IANA timezone overriding the user's timezone for view"
+// @Param sort query string false "Sort field" Enums(created_at, title, completed, due_at, priority)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Router /todos [get]
+func (h *TodoHandler) GetTodos(c *gin.Context) {
+	opts, err := parseTodoListOptions(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	ownerID := userID.(int)
+	opts.Filter.UserID = &ownerID
+
+	if view := c.Query("view"); view != "" {
+		opts.Filter.View, err = h.todoService.PlanningView(c.Request.Context(), ownerID, view, c.Query("tz"))
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidView) || errors.Is(err, services.ErrInvalidTZ) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	listTodos(c, h.todoService, opts)
+}
+
+// GetTodoTree retrieves a todo with its subtasks
+// @Summary Get a todo with its subtasks
+// @Description Retrieves a todo and its subtasks at any depth, nested under children. Todos with subtasks carry progress, the percentage of completed leaf subtasks. Users can only access their own todos unless their role grants todos:read:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} models.TodoNode
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /todos/{id}/tree [get]
+func (h *TodoHandler) GetTodoTree(c *gin.Context) {
+	todo, ok := h.todo(c, models.PermTodosReadAny)
+	if !ok {
 		return
 	}
 
-	todo, err := h.todoService.GetTodoByID(c.Request.Context(), id)
+	tree, err := h.todoService.GetTodoTree(c.Request.Context(), todo.ID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
-	if role != "admin" && todo.UserID != userID.(int) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	c.JSON(http.StatusOK, tree)
+}
+
+// AddBlocker marks a todo as blocked by another
+// @Summary Add a blocker to a todo
+// @Description Records that the todo cannot be completed before the blocking todo, which must belong to the same user. Dependencies that would form a cycle are rejected. Users can only change their own todos unless their role grants todos:write:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param blocker_id path int true "Blocking todo ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /todos/{id}/blockers/{blocker_id} [post]
+func (h *TodoHandler) AddBlocker(c *gin.Context) {
+	h.changeBlocker(c, h.todoService.AddBlocker)
+}
+
+// RemoveBlocker removes a blocker from a todo
+// @Summary Remove a blocker from a todo
+// @Description Removes a dependency on a blocking todo. Users can only change their own todos unless their role grants todos:write:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param blocker_id path int true "Blocking todo ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /todos/{id}/blockers/{blocker_id} [delete]
+func (h *TodoHandler) RemoveBlocker(c *gin.Context) {
+	h.changeBlocker(c, h.todoService.RemoveBlocker)
+}
+
+func (h *TodoHandler) changeBlocker(c *gin.Context, change func(context.Context, *models.Todo, int) error) {
+	blockerID, err := strconv.Atoi(c.Param("blocker_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blocker id"})
+		return
+	}
+
+	todo, ok := h.todo(c, models.PermTodosWriteAny)
+	if !ok {
+		return
+	}
+
+	if err := change(c.Request.Context(), todo, blockerID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrBlockerNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDependencyCycle):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, todo)
 }
 
-// GetTodo retrieves a todo by ID
-// @Summary Get a todo by ID
-// @Description Retrieves a todo item by its ID. Users can only access their own todos unless they are admins.
+// GetOccurrences previews the upcoming occurrences of a recurring todo
+// @Summary Preview occurrences of a recurring todo
+// @Description Lists the next occurrences of a recurring todo's series after the todo itself, computed in the owner's timezone. The series ends early when COUNT or UNTIL is reached. Users can only access their own todos unless their role grants todos:read:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param count query int false "Number of occurrences (default 10, max 100)"
+// @Success 200 {array} models.TodoOccurrence
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /todos/{id}/occurrences [get]
+func (h *TodoHandler) GetOccurrences(c *gin.Context) {
+	count := 10
+	if v := c.Query("count"); v != "" {
+		var err error
+		if count, err = strconv.Atoi(v); err != nil || count < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid count"})
+			return
+		}
+	}
+
+	todo, ok := h.todo(c, models.PermTodosReadAny)
+	if !ok {
+		return
+	}
+
+	occurrences, err := h.todoService.PreviewOccurrences(c.Request.Context(), todo, count)
+	if err != nil {
+		if errors.Is(err, services.ErrNotRecurring) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+// SkipOccurrence skips the current occurrence of a recurring todo
+// @Summary Skip an occurrence of a recurring todo
+// @Description Moves a recurring todo's dates on to the next occurrence of its series without completing it. Users can only change their own todos unless their role grants todos:write:any.
 // @Tags todos
 // @Produce json
 // @Security BearerAuth
@@ -83,42 +280,389 @@ func (h *TodoHandler) GetTodo(c *gin.Context) {
 // @Failure 401 {object} object{error=string}
 // @Failure 403 {object} object{error=string}
 // @Failure 404 {object} object{error=string}
-// @Router /todos/{id} [get]
+// @Failure 409 {object} object{error=string}
+// @Router /todos/{id}/recurrence/skip [post]
+func (h *TodoHandler) SkipOccurrence(c *gin.Context) {
+	h.changeSeries(c, h.todoService.SkipOccurrence)
+}
 
-func (h *TodoHandler) GetTodos(c *gin.Context) {
-	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
+// EndSeries stops a recurring todo from recurring
+// @Summary End a recurring series
+// @Description Removes the recurrence rule of a todo, so completing it no longer creates the next occurrence. Users can only change their own todos unless their role grants todos:write:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /todos/{id}/recurrence/end [post]
+func (h *TodoHandler) EndSeries(c *gin.Context) {
+	h.changeSeries(c, h.todoService.EndSeries)
+}
+
+func (h *TodoHandler) changeSeries(c *gin.Context, change func(context.Context, *models.Todo) error) {
+	todo, ok := h.todo(c, models.PermTodosWriteAny)
+	if !ok {
+		return
+	}
+
+	if err := change(c.Request.Context(), todo); err != nil {
+		if errors.Is(err, services.ErrNotRecurring) || errors.Is(err, services.ErrSeriesEnded) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
+
+// AttachTag attaches a tag to a todo
+// @Summary Attach a tag to a todo
+// @Description Labels a todo with one of its owner's tags. Users can only tag their own todos unless their role grants todos:write:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param tag_id path int true "Tag ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /todos/{id}/tags/{tag_id} [post]
+func (h *TodoHandler) AttachTag(c *gin.Context) {
+	h.changeTag(c, h.todoService.AttachTag)
+}
+
+// DetachTag removes a tag from a todo
+// @Summary Detach a tag from a todo
+// @Description Removes a tag from a todo. Users can only untag their own todos unless their role grants todos:write:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param tag_id path int true "Tag ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Router /todos/{id}/tags/{tag_id} [delete]
+func (h *TodoHandler) DetachTag(c *gin.Context) {
+	h.changeTag(c, h.todoService.DetachTag)
+}
+
+func (h *TodoHandler) changeTag(c *gin.Context, change func(context.Context, *models.Todo, int) error) {
+	tagID, err := strconv.Atoi(c.Param("tag_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tag id"})
+		return
+	}
+
+	todo, ok := h.todo(c, models.PermTodosWriteAny)
+	if !ok {
+		return
+	}
+
+	if err := change(c.Request.Context(), todo, tagID); err != nil {
+		if errors.Is(err, services.ErrTagNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
+
+// SearchTodos runs a full text search over todos
+// @Summary Search todos
+// @Description Full text search over todo titles and descriptions, best match first. Words must all match; "quoted phrases" match adjacent words and a trailing * matches a prefix. Users search their own todos unless their role grants todos:read:any.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search query"
+// @Param completed query bool false "Completion state"
+// @Param user_id query int false "Owner user ID (needs todos:read:any)"
+// @Param project_id query int false "Project ID"
+// @Param include_archived query bool false "Include todos of archived projects"
+// @Param parent_id query int false "Only the direct subtasks of this todo"
+// @Param limit query int false "Maximum number of results (default 50, max 200)"
+// @Success 200 {array} models.TodoSearchResult
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Router /todos/search [get]
+func (h *TodoHandler) SearchTodos(c *gin.Context) {
+	filter, err := parseTodoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// q is the search query here, not a substring filter.
+	filter.Query = ""
+
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit %q", v)})
+			return
+		}
+	}
+
+	results, err := h.todoService.SearchTodos(c.Request.Context(), principal(c), c.Query("q"), filter, limit)
+	if err != nil {
+		if errors.Is(err, search.ErrEmptyQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// ListAllTodos lists todos across all users
+// @Summary List todos of all users
+// @Description Lists todos across every user, optionally filtered by owner, completion, creation date range and free text. Needs todos:read:any.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query int false "Owner user ID"
+// @Param completed query bool false "Completion state"
+// @Param created_after query string false "RFC 3339 lower bound (inclusive) on created_at"
+// @Param created_before query string false "RFC 3339 upper bound (exclusive) on created_at"
+// @Param q query string false "Case-insensitive text matched against title and description"
+// @Param tags query string false "Comma separated tag IDs"
+// @Param tag_mode query string false "Whether todos need any or all of the tags" Enums(any, all)
+// @Param project_id query int false "Project ID"
+// @Param include_archived query bool false "Include todos of archived projects"
+// @Param parent_id query int false "Only the direct subtasks of this todo"
+// @Param sort query string false "Sort field" Enums(created_at, title, completed, due_at, priority)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/todos [get]
+func (h *TodoHandler) ListAllTodos(c *gin.Context) {
+	opts, err := parseTodoListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listTodos(c, h.todoService, opts)
+}
+
+// GetUserTodos lists the todos of one user
+// @Summary List todos of a user
+// @Description Lists the todos owned by the given user, accepting the same filters as /admin/todos. Needs todos:read:any.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param completed query bool false "Completion state"
+// @Param created_after query string false "RFC 3339 lower bound (inclusive) on created_at"
+// @Param created_before query string false "RFC 3339 upper bound (exclusive) on created_at"
+// @Param q query string false "Case-insensitive text matched against title and description"
+// @Param tags query string false "Comma separated tag IDs"
+// @Param tag_mode query string false "Whether todos need any or all of the tags" Enums(any, all)
+// @Param project_id query int false "Project ID"
+// @Param include_archived query bool false "Include todos of archived projects"
+// @Param parent_id query int false "Only the direct subtasks of this todo"
+// @Param sort query string false "Sort field" Enums(created_at, title, completed, due_at, priority)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/users/{id}/todos [get]
+func (h *TodoHandler) GetUserTodos(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
 
-	var todos []*models.Todo
-	var err error
+	opts, err := parseTodoListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.Filter.UserID = &userID
 
-	if role == "admin" {
-		todos, err = h.todoService.GetTodosByUserID(c.Request.Context(), 0) // 0 = all users
-	} else {
-		todos, err = h.todoService.GetTodosByUserID(c.Request.Context(), userID.(int))
+	listTodos(c, h.todoService, opts)
+}
+
+// GetTodoStats returns per-user todo counts
+// @Summary Todo counts per user
+// @Description Returns the total, completed and open todo counts of every user. Needs todos:read:any.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.UserTodoStats
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /admin/todos/stats [get]
+func (h *TodoHandler) GetTodoStats(c *gin.Context) {
+	stats, err := h.todoService.TodoStatsByUser(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
+	c.JSON(http.StatusOK, stats)
+}
+
+// listTodos writes the page of todos selected by opts and the cursor query
+// parameter.
+func listTodos(c *gin.Context, todoService *services.TodoService, opts models.TodoListOptions) {
+	page, err := todoService.ListTodos(c.Request.Context(), opts, c.Query("cursor"))
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) || errors.Is(err, services.ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, todos)
+	c.JSON(http.StatusOK, page)
+}
+
+// parseTodoListOptions reads the filters, sort order and page size of a todo
+// listing from the query string.
+func parseTodoListOptions(c *gin.Context) (models.TodoListOptions, error) {
+	var opts models.TodoListOptions
+
+	filter, err := parseTodoFilter(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.Filter = filter
+	opts.Sort = c.Query("sort")
+
+	switch order := c.DefaultQuery("order", "asc"); order {
+	case "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("invalid limit %q", v)
+		}
+		opts.Limit = limit
+	}
+
+	return opts, nil
+}
+
+// parseTodoFilter reads the todo listing filters from the query string.
+func parseTodoFilter(c *gin.Context) (models.TodoFilter, error) {
+	var filter models.TodoFilter
+
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id %q", v)
+		}
+		filter.UserID = &id
+	}
+	if v := c.Query("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid completed %q", v)
+		}
+		filter.Completed = &completed
+	}
+	if v := c.Query("created_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_after %q, expected RFC 3339", v)
+		}
+		filter.CreatedAfter = &t
+	}
+	if v := c.Query("created_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_before %q, expected RFC 3339", v)
+		}
+		filter.CreatedBefore = &t
+	}
+	filter.Query = c.Query("q")
+
+	if v := c.Query("project_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid project_id %q", v)
+		}
+		filter.ProjectID = &id
+	}
+	if v := c.Query("parent_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid parent_id %q", v)
+		}
+		filter.ParentID = &id
+	}
+	if v := c.Query("include_archived"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid include_archived %q", v)
+		}
+		filter.IncludeArchived = include
+	}
+
+	if v := c.Query("tags"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return filter, fmt.Errorf("invalid tags %q, expected comma separated tag ids", v)
+			}
+			filter.TagIDs = append(filter.TagIDs, id)
+		}
+	}
+	switch mode := c.DefaultQuery("tag_mode", "any"); mode {
+	case "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return filter, fmt.Errorf("invalid tag_mode %q, expected any or all", mode)
+	}
+
+	return filter, nil
 }
 
 // UpdateTodo updates a todo
 // @Summary Update a todo
-// @Description Updates an existing todo item. Users can only update their own todos unless they are admins.
+// @Description Updates an existing todo item. Setting project_id moves the todo to another of its owner's projects. Setting parent_id moves it under another todo, or to the top level when 0; moves that would create a cycle are rejected. A todo with open blockers cannot be completed unless force=true is passed by a role granting todos:complete:force. Setting recurrence to an RRULE such as FREQ=WEEKLY;BYDAY=MO,TH makes the todo recur from its due date; completing it creates the next occurrence. Users can only update their own todos unless their role grants todos:write:any.
 // @Tags todos
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Todo ID"
+// @Param force query bool false "Complete the todo despite open blockers (needs todos:complete:force)"
 // @Param todo body models.Todo true "Todo data"
 // @Success 200 {object} models.Todo
 // @Failure 400 {object} object{error=string}
 // @Failure 401 {object} object{error=string}
 // @Failure 403 {object} object{error=string}
 // @Failure 404 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
 // @Router /todos/{id} [put]
 
 func (h *TodoHandler) UpdateTodo(c *gin.Context) {
@@ -135,20 +679,26 @@ func (h *TodoHandler) UpdateTodo(c *gin.Context) {
 	}
 
 	input.ID = id
-	todo, err := h.todoService.GetTodoByID(c.Request.Context(), id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+	if _, ok := h.todo(c, models.PermTodosWriteAny); !ok {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
-	if role != "admin" && todo.UserID != userID.(int) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return
+	var force bool
+	if v := c.Query("force"); v != "" {
+		if force, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid force %q", v)})
+			return
+		}
 	}
-
-	if err := h.todoService.UpdateTodo(c.Request.Context(), &input); err != nil {
+	if err := h.todoService.UpdateTodo(c.Request.Context(), principal(c), &input, force); err != nil {
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrTodoBlocked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -158,7 +708,7 @@ func (h *TodoHandler) UpdateTodo(c *gin.Context) {
 
 // DeleteTodo deletes a todo by ID
 // @Summary Delete a todo by ID
-// @Description Deletes a todo item by its ID. Users can only delete their own todos unless they are admins.
+// @Description Deletes a todo item by its ID, together with its subtasks. Users can only delete their own todos unless their role grants todos:write:any.
 // @Tags todos
 // @Produce json
 // @Security BearerAuth
@@ -171,29 +721,37 @@ func (h *TodoHandler) UpdateTodo(c *gin.Context) {
 // @Router /todos/{id} [delete]
 
 func (h *TodoHandler) DeleteTodo(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+	todo, ok := h.todo(c, models.PermTodosWriteAny)
+	if !ok {
 		return
 	}
 
-	todo, err := h.todoService.GetTodoByID(c.Request.Context(), id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+	if err := h.todoService.DeleteTodo(c.Request.Context(), todo.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
-	if role != "admin" && todo.UserID != userID.(int) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return
+	c.JSON(http.StatusOK, gin.H{"message": "todo deleted"})
+}
+
+// todo loads the todo named by the id parameter if the caller may act on it
+// with permission, writing the error response if not.
+func (h *TodoHandler) todo(c *gin.Context, permission string) (*models.Todo, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
 	}
 
-	if err := h.todoService.DeleteTodo(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	todo, err := h.todoService.GetTodo(c.Request.Context(), principal(c), id, permission)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+		return nil, false
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "todo deleted"})
+	return todo, true
 }
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/services"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+type UserHandler struct {
+	userService *services.UserService
+}
+
+func NewUserHandler(userService *services.UserService) *UserHandler {
+	return &UserHandler{userService: userService}
+}
+
+// GetProfile returns the user's own account
+// @Summary Get your profile
+// @Description Returns the authenticated user's account and profile.
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.User
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Router /me [get]
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	user, err := h.userService.GetProfile(c.Request.Context(), userID.(int))
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile changes the user's profile
+// @Summary Update your profile
+// @Description Changes the display name, email address, IANA timezone or locale (a language tag such as en or pt-BR) of the authenticated user. Fields left out are unchanged; an empty email removes the address. Changing the email address needs the current_password, and wrong ones count as failed logins; the previous address is told about the change. Users without a password confirm with a session from GET /oidc/login?reauth=true started in the last five minutes instead.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param profile body models.ProfileUpdate true "Profile fields to change"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Router /me [patch]
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	var input models.ProfileUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, _ := c.Get("claims")
+	user, err := h.userService.UpdateProfile(c.Request.Context(), claims.(*utils.Claims), input, c.ClientIP())
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword changes the user's password
+// @Summary Change your password
+// @Description Replaces the password of the authenticated user after checking the current one, and logs out every other session. The session making the request stays logged in. Wrong current passwords count as failed logins. Users without a password, created by single sign-on, set one without current_password from a session started at GET /oidc/login?reauth=true in the last five minutes.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param passwords body object{current_password=string,new_password=string} true "Current and new password"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string,violations=[]passpolicy.Violation}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Router /me/password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, _ := c.Get("claims")
+	err := h.userService.ChangePassword(c.Request.Context(), claims.(*utils.Claims), input.CurrentPassword,
+		input.NewPassword, c.ClientIP())
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed, other sessions logged out"})
+}
+
+// ChangeUsername renames the user
+// @Summary Change your username
+// @Description Renames the authenticated user. Usernames are unique and at most 50 characters.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param username body object{username=string} true "New username"
+// @Success 200 {object} models.User
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Router /me/username [put]
+func (h *UserHandler) ChangeUsername(c *gin.Context) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	user, err := h.userService.ChangeUsername(c.Request.Context(), userID.(int), input.Username)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteAccount deletes the user's account
+// @Summary Delete your account
+// @Description Deletes the authenticated user after checking their password, together with their todos, tags, projects and tokens. Users without a password confirm with a session started at GET /oidc/login?reauth=true in the last five minutes instead. The last active admin cannot delete their account.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param password body object{password=string} true "Current password"
+// @Success 204
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Router /me [delete]
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, _ := c.Get("claims")
+	if err := h.userService.DeleteAccount(c.Request.Context(), claims.(*utils.Claims), input.Password,
+		c.ClientIP()); err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func writeUserError(c *gin.Context, err error) {
+	if writeLoginLocked(c, err) || writePasswordRejected(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidProfile), errors.Is(err, services.ErrInvalidTZ),
+		errors.Is(err, services.ErrPasswordRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWrongPassword), errors.Is(err, services.ErrReauthRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUsernameTaken), errors.Is(err, services.ErrEmailTaken),
+		errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,222 @@
+// Package keyset holds the keys that sign and verify the API's JWTs.
+//
+// A keyset is a directory of PKCS #8 PEM private keys named <kid>.pem, RSA
+// keys signing with RS256 and Ed25519 keys with EdDSA, and a file named
+// "active" holding the kid of the key that signs new tokens. Every key in
+// the directory verifies tokens, so a key stays usable for verification
+// after another one takes over signing until it is retired. Verifiers get
+// the public keys as a JWK Set.
+//
+// Without a key directory, a keyset falls back to HS256 with a shared
+// secret.
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms.
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+	HS256 = "HS256"
+)
+
+// activeFile names the file holding the kid of the signing key.
+const activeFile = "active"
+
+var (
+	// ErrUnknownKey is returned for tokens signed with a key not in the
+	// keyset and for kids missing from a key directory.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrNoKeys is returned when loading a key directory without keys or
+	// without an active one.
+	ErrNoKeys = errors.New("no active signing key")
+)
+
+// Key is a signing key of a keyset.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// Public returns the public half of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// Keyset signs tokens with its active key and verifies them with any of
+// its keys.
+type Keyset struct {
+	keys   map[string]*Key
+	active *Key
+	// secret is the HS256 key of a keyset without a key directory.
+	secret []byte
+}
+
+// NewHMAC returns a keyset that signs and verifies with HS256 and secret.
+// Anyone able to verify its tokens can also mint them.
+func NewHMAC(secret string) *Keyset {
+	return &Keyset{secret: []byte(secret)}
+}
+
+// Load reads the keyset in dir.
+func Load(dir string) (*Keyset, error) {
+	keys, err := readKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := activeKID(dir)
+	if err != nil {
+		return nil, err
+	}
+	ks := &Keyset{keys: make(map[string]*Key)}
+	for _, k := range keys {
+		ks.keys[k.ID] = k
+	}
+	if ks.active = ks.keys[kid]; ks.active == nil {
+		return nil, fmt.Errorf("%w: active key %q is not in %s", ErrNoKeys, kid, dir)
+	}
+	return ks, nil
+}
+
+// Algorithm returns the algorithm new tokens are signed with.
+func (ks *Keyset) Algorithm() string {
+	if ks.active == nil {
+		return HS256
+	}
+	return ks.active.Algorithm
+}
+
+// ActiveID returns the kid of the signing key, or "" for an HS256 keyset.
+func (ks *Keyset) ActiveID() string {
+	if ks.active == nil {
+		return ""
+	}
+	return ks.active.ID
+}
+
+// Sign signs claims with the active key, naming it in the kid header.
+func (ks *Keyset) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(ks.active.Algorithm), claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.Private)
+}
+
+// Parse verifies tokenString with the key its kid header names and parses
+// it into claims.
+func (ks *Keyset) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	if ks.active == nil {
+		opts = append(opts, jwt.WithValidMethods([]string{HS256}))
+		return jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (any, error) {
+			return ks.secret, nil
+		}, opts...)
+	}
+	opts = append(opts, jwt.WithValidMethods([]string{RS256, EdDSA}))
+	return jwt.ParseWithClaims(tokenString, claims, ks.verificationKey, opts...)
+}
+
+func (ks *Keyset) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := ks.keys[kid]
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	// A token must use the algorithm of its key, or RS256 tokens could be
+	// checked against an Ed25519 key and the other way round.
+	if token.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.Public(), nil
+}
+
+// Keys returns the keys of the keyset ordered by kid. An HS256 keyset has
+// none, as its secret must not be published.
+func (ks *Keyset) Keys() []*Key {
+	keys := make([]*Key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b *Key) int { return strings.Compare(a.ID, b.ID) })
+	return keys
+}
+
+// readKeys parses every <kid>.pem file in dir, ordered by kid.
+func readKeys(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []*Key
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func readKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PKCS #8 PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem"), CreatedAt: info.ModTime()}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must have at least 2048 bits")
+		}
+		key.Algorithm, key.Private = RS256, k
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Private = EdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// activeKID returns the kid of the signing key of dir.
+func activeKID(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, activeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w in %s", ErrNoKeys, dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,168 @@
+package keyset
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			// RFC 7638 section 3.1.
+			name: "RSA",
+			jwk: JWK{
+				KeyType:   "RSA",
+				KeyID:     "2011-04-29",
+				Algorithm: "RS256",
+				N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+					"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajr" +
+					"n1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E: "AQAB",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037 appendix A.3.
+			name: "Ed25519",
+			jwk:  JWK{KeyType: "OKP", Curve: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := thumbprint(tt.jwk); got != tt.want {
+				t.Errorf("thumbprint() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Generate(dir, EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != thumbprint(first.JWK()) {
+		t.Errorf("kid %s is not the key's thumbprint", first.ID)
+	}
+	second, err := Generate(dir, RS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, active, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || active != first.ID {
+		t.Fatalf("List() = %d keys, active %q, want 2 keys, active %q", len(keys), active, first.ID)
+	}
+
+	ks, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken := sign(t, ks)
+	if err := Activate(dir, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ks, err = Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if ks.Algorithm() != RS256 || ks.ActiveID() != second.ID {
+		t.Errorf("active key is %s %s, want %s %s", ks.Algorithm(), ks.ActiveID(), RS256, second.ID)
+	}
+	if _, err := ks.Parse(oldToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of the previous key no longer verifies: %v", err)
+	}
+	if _, err := ks.Parse(sign(t, ks), &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of the active key does not verify: %v", err)
+	}
+	if got := len(ks.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS has %d keys, want 2", got)
+	}
+
+	if err := Retire(dir, second.ID); !errors.Is(err, ErrActiveKey) {
+		t.Errorf("retiring the active key: %v, want ErrActiveKey", err)
+	}
+	if err := Retire(dir, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ks, err = Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(oldToken, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of a retired key: %v, want ErrUnknownKey", err)
+	}
+	if err := Activate(dir, first.ID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("activating a retired key: %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestLoadWithoutKeys(t *testing.T) {
+	if _, err := Load(t.TempDir()); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Load() of an empty directory: %v, want ErrNoKeys", err)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	ks := NewHMAC("secret")
+	token := sign(t, ks)
+	if _, err := ks.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token does not verify: %v", err)
+	}
+	if _, err := NewHMAC("other").Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("token verified with another secret")
+	}
+	if got := len(ks.JWKS().Keys); got != 0 {
+		t.Errorf("JWKS of an HMAC keyset has %d keys, want none", got)
+	}
+}
+
+// TestAlgorithmMismatch checks that a token cannot name a key of another
+// algorithm than it was signed with.
+func TestAlgorithmMismatch(t *testing.T) {
+	dir := t.TempDir()
+	ed, err := Generate(dir, EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaDir := t.TempDir()
+	if _, err := Generate(rsaDir, RS256); err != nil {
+		t.Fatal(err)
+	}
+	rsaKeys, err := Load(rsaDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{Subject: "1"})
+	token.Header["kid"] = ed.ID
+	signed, err := token.SignedString(rsaKeys.active.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(signed, &jwt.RegisteredClaims{}); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("RS256 token naming an Ed25519 key: %v, want ErrTokenSignatureInvalid", err)
+	}
+}
+
+func sign(t *testing.T, ks *Keyset) string {
+	t.Helper()
+	token, err := ks.Sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
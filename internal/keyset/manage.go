@@ -0,0 +1,141 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rsaBits is the size of generated RSA keys.
+const rsaBits = 3072
+
+// ErrActiveKey is returned when retiring the key that signs new tokens.
+var ErrActiveKey = errors.New("the active key cannot be retired")
+
+// Generate creates a key for alg (RS256 or EdDSA) in dir, creating dir if
+// needed. The kid is the key's RFC 7638 thumbprint. The first key of a
+// directory becomes active; later ones only verify until activated.
+func Generate(dir, alg string) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaBits)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use %s or %s", alg, RS256, EdDSA)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{Algorithm: alg, Private: private}
+	key.ID = thumbprint(key.JWK())
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, key.ID+".pem")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if key, err = readKey(path); err != nil {
+		return nil, err
+	}
+
+	if _, err := activeKID(dir); errors.Is(err, ErrNoKeys) {
+		return key, Activate(dir, key.ID)
+	}
+	return key, nil
+}
+
+// Activate makes the key kid of dir sign new tokens from the next start of
+// the API.
+func Activate(dir, kid string) error {
+	if _, err := readKey(filepath.Join(dir, kid+".pem")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w %q", ErrUnknownKey, kid)
+		}
+		return err
+	}
+	// Write and rename, so that a starting API never reads a partial file.
+	tmp := filepath.Join(dir, activeFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(kid+"\n"), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, activeFile))
+}
+
+// Retire deletes the key kid of dir. Tokens it signed stop verifying.
+func Retire(dir, kid string) error {
+	active, err := activeKID(dir)
+	if err != nil && !errors.Is(err, ErrNoKeys) {
+		return err
+	}
+	if kid == active {
+		return ErrActiveKey
+	}
+	err = os.Remove(filepath.Join(dir, kid+".pem"))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return err
+}
+
+// List returns the keys of dir ordered by kid, and the kid of the active
+// one.
+func List(dir string) ([]*Key, string, error) {
+	keys, err := readKeys(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	active, err := activeKID(dir)
+	if err != nil && !errors.Is(err, ErrNoKeys) {
+		return nil, "", err
+	}
+	return keys, active, nil
+}
+
+// thumbprint returns the RFC 7638 thumbprint of jwk: the SHA-256 of its
+// required members in lexicographic order.
+func thumbprint(jwk JWK) string {
+	var members any
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// sendTimeout bounds a delivery when the context has no deadline.
+const sendTimeout = 30 * time.Second
+
+// SMTP sends messages through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it. Servers expecting TLS from the first
+// byte (port 465) are not supported.
+type SMTP struct {
+	host     string
+	addr     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTP returns a mailer sending through host:port as from, logging in
+// with username and password unless username is empty.
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	return &SMTP{
+		host:     host,
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *SMTP) Send(ctx context.Context, msg *Message) error {
+	data, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to
+		// localhost.
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -1,14 +1,22 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/services"
 	"github.com/globallstudent/todo-project-go/internal/utils"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// AuthMiddleware accepts requests carrying a valid, unrevoked access token or
+// personal access token of an enabled account and stores its claims or the
+// personal access token in the context. The username and role are taken
+// from the account, so role changes apply immediately.
+func AuthMiddleware(authService *services.AuthService, accessTokens *services.AccessTokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,26 +32,107 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.ValidateJWT(parts[1], jwtSecret)
+		var (
+			user        *models.User
+			claims      *utils.Claims
+			accessToken *models.PersonalAccessToken
+			err         error
+		)
+		if strings.HasPrefix(parts[1], services.AccessTokenPrefix) {
+			user, accessToken, err = accessTokens.Authenticate(c.Request.Context(), parts[1])
+		} else {
+			user, claims, err = authService.Authenticate(c.Request.Context(), parts[1])
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			switch {
+			case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrTokenRevoked),
+				errors.Is(err, services.ErrUserDisabled), errors.Is(err, services.ErrAccessTokenExpired):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+
+		if accessToken != nil {
+			c.Set("access_token", accessToken)
+		} else {
+			c.Set("claims", claims)
+		}
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("email_verified", user.EmailVerifiedAt != nil)
+		c.Next()
+	}
+}
+
+// RequirePermission restricts access to callers whose role holds
+// permission. It must run after AuthMiddleware.
+func RequirePermission(policy *services.PolicyService, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := &models.Principal{UserID: c.GetInt("user_id"), Role: c.GetString("role")}
+		if err := policy.Require(c.Request.Context(), actor, permission); err != nil {
+			if errors.Is(err, services.ErrForbidden) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope limits personal access tokens to the area their scopes
+// cover: area:read allows GET and HEAD requests, area:write allows every
+// request. Requests authenticated with a login session pass.
+func RequireScope(area string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("access_token")
+		if !ok {
+			c.Next()
+			return
+		}
+		token := v.(*models.PersonalAccessToken)
+
+		scope := area + ":write"
+		allowed := slices.Contains(token.Scopes, scope)
+		if !allowed && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+			scope = area + ":read"
+			allowed = slices.Contains(token.Scopes, scope)
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "token lacks the " + scope + " scope"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+// RequireSession rejects requests authenticated with a personal access
+// token, for endpoints that manage the session or the tokens themselves.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("access_token"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "personal access tokens cannot be used here"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
-// AdminMiddleware restricts access to admin users
-func AdminMiddleware() gin.HandlerFunc {
+// RequireVerifiedEmail rejects users without a verified email address when
+// enabled, and lets every request pass otherwise. It must run after
+// AuthMiddleware.
+func RequireVerifiedEmail(enabled bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		if enabled && !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrEmailNotVerified.Error()})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// serve runs a request through handlers, authenticated with a personal
+// access token with scopes unless scopes is nil, and returns the status.
+func serve(t *testing.T, method string, scopes []string, handlers ...gin.HandlerFunc) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authenticate := func(c *gin.Context) {
+		if scopes != nil {
+			c.Set("access_token", &models.PersonalAccessToken{Scopes: scopes})
+		}
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.Handle(method, "/", append(append([]gin.HandlerFunc{authenticate}, handlers...), ok)...)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, "/", nil))
+	return w.Code
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		scopes []string
+		want   int
+	}{
+		{"session", http.MethodPost, nil, http.StatusOK},
+		{"read scope reads", http.MethodGet, []string{"todos:read"}, http.StatusOK},
+		{"read scope heads", http.MethodHead, []string{"todos:read"}, http.StatusOK},
+		{"read scope cannot write", http.MethodPost, []string{"todos:read"}, http.StatusForbidden},
+		{"read scope cannot delete", http.MethodDelete, []string{"todos:read"}, http.StatusForbidden},
+		{"write scope writes", http.MethodPut, []string{"todos:write"}, http.StatusOK},
+		{"write scope reads", http.MethodGet, []string{"todos:write"}, http.StatusOK},
+		{"scope of another area", http.MethodGet, []string{"tags:write", "projects:read"}, http.StatusForbidden},
+		{"no scopes", http.MethodGet, []string{}, http.StatusForbidden},
+		{"area prefix is not a scope", http.MethodGet, []string{"todos"}, http.StatusForbidden},
+		{"one of several scopes", http.MethodPatch, []string{"tags:read", "todos:write"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, tt.method, tt.scopes, RequireScope("todos")); got != tt.want {
+				t.Errorf("%s with scopes %v: status %d, want %d", tt.method, tt.scopes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	if got := serve(t, http.MethodPost, nil, RequireSession()); got != http.StatusOK {
+		t.Errorf("session: status %d, want %d", got, http.StatusOK)
+	}
+	if got := serve(t, http.MethodPost, []string{"todos:write", "admin:write"}, RequireSession()); got != http.StatusForbidden {
+		t.Errorf("personal access token: status %d, want %d", got, http.StatusForbidden)
+	}
+}
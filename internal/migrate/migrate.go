@@ -0,0 +1,334 @@
+// Package migrate applies the embedded SQL migrations and tracks which
+// versions a database has seen.
+//
+// Every applied migration is recorded in a version table together with the
+// checksum of its up script. A migration is marked dirty before it runs and
+// cleaned once it succeeds, so an interrupted run is detected and further
+// migrations are refused until the schema is repaired and Force is used.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrDirty is returned when a previous migration did not finish.
+	ErrDirty = errors.New("database is in a dirty migration state")
+	// ErrChecksumMismatch is returned when an applied migration no longer
+	// matches the embedded script.
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownVersion is returned for versions without an embedded script.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+var fileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Record is a row of the version table.
+type Record struct {
+	Version   int
+	Name      string
+	Checksum  string
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// Status describes a known migration and whether it has been applied.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	Dirty     bool       `json:"dirty"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"`
+}
+
+// Driver is the database specific part of the migrator.
+type Driver interface {
+	// Lock blocks until this process holds the migration lock.
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+	// Init creates the version table if it does not exist.
+	Init(ctx context.Context) error
+	// Records returns the version table ordered by version.
+	Records(ctx context.Context) ([]Record, error)
+	// Apply runs script for m. When up is true the version is recorded,
+	// otherwise it is removed. The record must be marked dirty before the
+	// script runs and only cleaned once it succeeded.
+	Apply(ctx context.Context, m Migration, script string, up bool) error
+	// Reset replaces the version table with records.
+	Reset(ctx context.Context, records []Record) error
+}
+
+// Migrator applies migrations through a Driver.
+type Migrator struct {
+	driver     Driver
+	migrations []Migration
+}
+
+// New loads the migrations in fsys and returns a Migrator for driver.
+func New(driver Driver, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{driver: driver, migrations: migrations}, nil
+}
+
+// Load reads the NNNN_name.up.sql / NNNN_name.down.sql pairs from the root
+// of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		match := fileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the highest known version, or 0 if there are none.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.Goto(ctx, m.Latest())
+}
+
+// Down reverts the n most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.locked(ctx, func(records []Record) error {
+		if n > len(records) {
+			n = len(records)
+		}
+		target := 0
+		if idx := len(records) - n - 1; idx >= 0 {
+			target = records[idx].Version
+		}
+		return m.migrateTo(ctx, records, target)
+	})
+}
+
+// Goto migrates up or down until version is the latest applied migration.
+// Version 0 reverts everything.
+func (m *Migrator) Goto(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.locked(ctx, func(records []Record) error {
+		return m.migrateTo(ctx, records, version)
+	})
+}
+
+// Force rewrites the version table so that exactly the migrations up to and
+// including version are recorded as applied and clean, without running any
+// SQL. It is the way out of a dirty state after a manual repair.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	if err := m.driver.Lock(ctx); err != nil {
+		return err
+	}
+	defer m.driver.Unlock(context.WithoutCancel(ctx))
+
+	if err := m.driver.Init(ctx); err != nil {
+		return err
+	}
+
+	var records []Record
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		records = append(records, Record{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum})
+	}
+	return m.driver.Reset(ctx, records)
+}
+
+// Status lists every known migration along with its applied state. Versions
+// recorded in the database without an embedded script are included too.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.driver.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.driver.Unlock(context.WithoutCancel(ctx))
+
+	if err := m.driver.Init(ctx); err != nil {
+		return nil, err
+	}
+	records, err := m.driver.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]Record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			at := r.AppliedAt
+			s.Applied = true
+			s.Dirty = r.Dirty
+			s.AppliedAt = &at
+			s.Modified = r.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, r := range applied {
+		at := r.AppliedAt
+		statuses = append(statuses, Status{Version: r.Version, Name: r.Name, Applied: true, Dirty: r.Dirty, AppliedAt: &at})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// locked takes the migration lock, verifies the recorded history and runs fn.
+func (m *Migrator) locked(ctx context.Context, fn func(records []Record) error) error {
+	if err := m.driver.Lock(ctx); err != nil {
+		return err
+	}
+	defer m.driver.Unlock(context.WithoutCancel(ctx))
+
+	if err := m.driver.Init(ctx); err != nil {
+		return err
+	}
+	records, err := m.driver.Records(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(records); err != nil {
+		return err
+	}
+	return fn(records)
+}
+
+// verify refuses to continue when the history is dirty or diverges from the
+// embedded migrations.
+func (m *Migrator) verify(records []Record) error {
+	for _, r := range records {
+		if r.Dirty {
+			return fmt.Errorf("%w: version %d, repair the schema and run force", ErrDirty, r.Version)
+		}
+		mig := m.find(r.Version)
+		if mig == nil {
+			return fmt.Errorf("%w: %d is applied but not embedded", ErrUnknownVersion, r.Version)
+		}
+		if mig.Checksum != r.Checksum {
+			return fmt.Errorf("%w: version %d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, records []Record, target int) error {
+	applied := make(map[int]bool, len(records))
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+
+	// Revert everything above the target, newest first.
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Version <= target {
+			continue
+		}
+		mig := m.find(records[i].Version)
+		if mig.Down == "" {
+			return fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+		log.Printf("Reverting migration %d_%s", mig.Version, mig.Name)
+		if err := m.driver.Apply(ctx, *mig, mig.Down, false); err != nil {
+			return fmt.Errorf("revert %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+
+	// Apply everything pending up to the target, oldest first.
+	for _, mig := range m.migrations {
+		if mig.Version > target {
+			break
+		}
+		if applied[mig.Version] {
+			continue
+		}
+		log.Printf("Applying migration %d_%s", mig.Version, mig.Name)
+		if err := m.driver.Apply(ctx, mig, mig.Up, true); err != nil {
+			return fmt.Errorf("apply %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/migrations"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+var testMigrations = fstest.MapFS{
+	"0001_notes.up.sql":     {Data: []byte(`CREATE TABLE notes (id INTEGER PRIMARY KEY)`)},
+	"0001_notes.down.sql":   {Data: []byte(`DROP TABLE notes`)},
+	"0002_labels.up.sql":    {Data: []byte(`CREATE TABLE labels (id INTEGER PRIMARY KEY)`)},
+	"0002_labels.down.sql":  {Data: []byte(`DROP TABLE labels`)},
+	"0003_authors.up.sql":   {Data: []byte(`CREATE TABLE authors (id INTEGER PRIMARY KEY)`)},
+	"0003_authors.down.sql": {Data: []byte(`DROP TABLE authors`)},
+	"README.md":             {Data: []byte(`not a migration`)},
+}
+
+// newTestDriver returns a SQLiteDriver on a fresh database file.
+func newTestDriver(t *testing.T) *SQLiteDriver {
+	t.Helper()
+	db, err := database.NewSQLiteDB(database.SQLiteScheme + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	driver, err := NewSQLiteDriver(context.Background(), db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(driver.Close)
+	return driver
+}
+
+func newTestMigrator(t *testing.T, driver Driver, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	m, err := New(driver, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// tables returns the tables of the test migrations that exist.
+func tables(t *testing.T, d *SQLiteDriver) []string {
+	t.Helper()
+	rows, err := d.conn.QueryContext(context.Background(),
+		`SELECT name FROM sqlite_master WHERE type = 'table' AND name IN ('notes', 'labels', 'authors') ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func applied(t *testing.T, m *Migrator) []int {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []int
+		wantErr bool
+	}{
+		{name: "ordered by version", fsys: testMigrations, want: []int{1, 2, 3}},
+		{
+			name: "down script is optional",
+			fsys: fstest.MapFS{"0001_notes.up.sql": {Data: []byte(`SELECT 1`)}},
+			want: []int{1},
+		},
+		{
+			name:    "no up script",
+			fsys:    fstest.MapFS{"0001_notes.down.sql": {Data: []byte(`SELECT 1`)}},
+			wantErr: true,
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"0001_notes.up.sql":    {Data: []byte(`SELECT 1`)},
+				"0001_labels.up.sql":   {Data: []byte(`SELECT 1`)},
+				"0001_labels.down.sql": {Data: []byte(`SELECT 1`)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int
+			for _, m := range got {
+				versions = append(versions, m.Version)
+				if m.Checksum == "" {
+					t.Errorf("migration %d has no checksum", m.Version)
+				}
+			}
+			if !slices.Equal(versions, tt.want) {
+				t.Errorf("Load() versions = %v, want %v", versions, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpDownGoto(t *testing.T) {
+	ctx := context.Background()
+	driver := newTestDriver(t)
+	m := newTestMigrator(t, driver, testMigrations)
+
+	steps := []struct {
+		name       string
+		run        func() error
+		wantTables []string
+	}{
+		{"up", func() error { return m.Up(ctx) }, []string{"authors", "labels", "notes"}},
+		{"up again", func() error { return m.Up(ctx) }, []string{"authors", "labels", "notes"}},
+		{"down one", func() error { return m.Down(ctx, 1) }, []string{"labels", "notes"}},
+		{"goto 1", func() error { return m.Goto(ctx, 1) }, []string{"notes"}},
+		{"goto 3", func() error { return m.Goto(ctx, 3) }, []string{"authors", "labels", "notes"}},
+		{"down more than applied", func() error { return m.Down(ctx, 10) }, nil},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := tables(t, driver); !slices.Equal(got, step.wantTables) {
+			t.Fatalf("%s: tables = %v, want %v", step.name, got, step.wantTables)
+		}
+	}
+
+	if err := m.Goto(ctx, 4); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Goto(4) = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	driver := newTestDriver(t)
+	if err := newTestMigrator(t, driver, testMigrations).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := maps.Clone(testMigrations)
+	edited["0002_labels.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE labels (id INTEGER PRIMARY KEY, name TEXT)`)}
+	m := newTestMigrator(t, driver, edited)
+	if err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Up() after editing an applied migration = %v, want ErrChecksumMismatch", err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Modified != (s.Version == 2) {
+			t.Errorf("Status() version %d Modified = %v", s.Version, s.Modified)
+		}
+	}
+
+	// Dropping an applied migration from the binary is refused as well.
+	removed := maps.Clone(testMigrations)
+	delete(removed, "0003_authors.up.sql")
+	delete(removed, "0003_authors.down.sql")
+	if err := newTestMigrator(t, driver, removed).Up(ctx); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Up() with an applied migration missing = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestDirtyAndForce(t *testing.T) {
+	ctx := context.Background()
+	driver := newTestDriver(t)
+	broken := maps.Clone(testMigrations)
+	broken["0002_labels.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE labels (id INTEGER PRIMARY KEY); SELECT * FROM missing`)}
+	m := newTestMigrator(t, driver, broken)
+
+	if err := m.Up(ctx); err == nil {
+		t.Fatal("Up() with a failing migration succeeded")
+	}
+	if got := tables(t, driver); !slices.Equal(got, []string{"notes"}) {
+		t.Errorf("tables after the failed migration = %v, want it rolled back", got)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[1].Applied || !statuses[1].Dirty {
+		t.Errorf("Status() of the failed migration = %+v, want it applied and dirty", statuses[1])
+	}
+	if err := m.Up(ctx); !errors.Is(err, ErrDirty) {
+		t.Fatalf("Up() while dirty = %v, want ErrDirty", err)
+	}
+
+	m = newTestMigrator(t, driver, testMigrations)
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := applied(t, m); !slices.Equal(got, []int{1}) {
+		t.Fatalf("applied after Force(1) = %v, want [1]", got)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() after Force = %v", err)
+	}
+	if got := tables(t, driver); !slices.Equal(got, []string{"authors", "labels", "notes"}) {
+		t.Errorf("tables after Up = %v", got)
+	}
+	if err := m.Force(ctx, 7); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Force(7) = %v, want ErrUnknownVersion", err)
+	}
+}
+
+// TestSQLiteMigrations applies and reverts the shipped SQLite schema, so
+// every down script has to undo its up script.
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(newTestDriver(t), migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		name string
+		run  func() error
+	}{
+		{"up", func() error { return m.Up(ctx) }},
+		{"down", func() error { return m.Goto(ctx, 0) }},
+		{"up again", func() error { return m.Up(ctx) }},
+	} {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+	if got := applied(t, m); len(got) == 0 || got[len(got)-1] != m.Latest() {
+		t.Errorf("applied = %v, want every migration up to %d", got, m.Latest())
+	}
+}
+
+// TestMigrationsMatch checks that both backends ship the same migrations.
+func TestMigrationsMatch(t *testing.T) {
+	pg, err := Load(migrations.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lite, err := Load(migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pg) != len(lite) {
+		t.Fatalf("%d Postgres migrations, %d SQLite migrations", len(pg), len(lite))
+	}
+	for i := range pg {
+		if pg[i].Version != lite[i].Version || pg[i].Name != lite[i].Name {
+			t.Errorf("Postgres migration %d_%s, SQLite migration %d_%s", pg[i].Version, pg[i].Name, lite[i].Version, lite[i].Name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey identifies the advisory lock that serialises migration runs across
+// every instance pointed at the same database.
+const lockKey int64 = 0x746f646f6d6967 // "todomig"
+
+// PostgresDriver stores the version table in Postgres and serialises runs
+// with a session level advisory lock. It pins a single pooled connection
+// because advisory locks belong to the session that took them.
+type PostgresDriver struct {
+	conn *pgxpool.Conn
+}
+
+// NewPostgresDriver acquires a connection from pool for the migrator. Call
+// Close to hand it back.
+func NewPostgresDriver(ctx context.Context, pool *pgxpool.Pool) (*PostgresDriver, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresDriver{conn: conn}, nil
+}
+
+func (d *PostgresDriver) Close() {
+	d.conn.Release()
+}
+
+func (d *PostgresDriver) Lock(ctx context.Context) error {
+	_, err := d.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey)
+	return err
+}
+
+func (d *PostgresDriver) Unlock(ctx context.Context) error {
+	_, err := d.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockKey)
+	return err
+}
+
+func (d *PostgresDriver) Init(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	_, err := d.conn.Exec(ctx, query)
+	return err
+}
+
+func (d *PostgresDriver) Records(ctx context.Context) ([]Record, error) {
+	query := `
+		SELECT version, name, checksum, dirty, applied_at
+		FROM schema_migrations
+		ORDER BY version
+	`
+	rows, err := d.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.Version, &r.Name, &r.Checksum, &r.Dirty, &r.AppliedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (d *PostgresDriver) Apply(ctx context.Context, m Migration, script string, up bool) error {
+	// Mark the version dirty outside the migration transaction so that the
+	// marker survives if the script fails or the process dies halfway.
+	if up {
+		query := `
+			INSERT INTO schema_migrations (version, name, checksum, dirty)
+			VALUES ($1, $2, $3, TRUE)
+		`
+		if _, err := d.conn.Exec(ctx, query, m.Version, m.Name, m.Checksum); err != nil {
+			return err
+		}
+	} else {
+		query := `UPDATE schema_migrations SET dirty = TRUE WHERE version = $1`
+		if _, err := d.conn.Exec(ctx, query, m.Version); err != nil {
+			return err
+		}
+	}
+
+	return pgx.BeginFunc(ctx, d.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, script); err != nil {
+			return err
+		}
+		if up {
+			query := `
+				UPDATE schema_migrations
+				SET dirty = FALSE, applied_at = CURRENT_TIMESTAMP
+				WHERE version = $1
+			`
+			_, err := tx.Exec(ctx, query, m.Version)
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+		return err
+	})
+}
+
+func (d *PostgresDriver) Reset(ctx context.Context, records []Record) error {
+	return pgx.BeginFunc(ctx, d.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		query := `
+			INSERT INTO schema_migrations (version, name, checksum)
+			VALUES ($1, $2, $3)
+		`
+		for _, r := range records {
+			if _, err := tx.Exec(ctx, query, r.Version, r.Name, r.Checksum); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteLockStale is how long a lock row may exist before it is considered
+// abandoned by a crashed process.
+const sqliteLockStale = 10 * time.Minute
+
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// SQLiteDriver stores the version table in SQLite. SQLite has no advisory
+// locks, so runs are serialised through a single row lock table instead.
+type SQLiteDriver struct {
+	conn *sql.Conn
+}
+
+// NewSQLiteDriver pins a connection from db for the migrator. Call Close to
+// hand it back.
+func NewSQLiteDriver(ctx context.Context, db *sql.DB) (*SQLiteDriver, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteDriver{conn: conn}, nil
+}
+
+func (d *SQLiteDriver) Close() {
+	d.conn.Close()
+}
+
+func (d *SQLiteDriver) Lock(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			locked_at TEXT NOT NULL
+		)
+	`
+	if _, err := d.conn.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	for {
+		now := time.Now().UTC()
+		stale := now.Add(-sqliteLockStale).Format(sqliteTimeLayout)
+		if _, err := d.conn.ExecContext(ctx, `DELETE FROM schema_migrations_lock WHERE locked_at < ?`, stale); err != nil {
+			return err
+		}
+
+		res, err := d.conn.ExecContext(ctx,
+			`INSERT OR IGNORE INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)`,
+			now.Format(sqliteTimeLayout))
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for migration lock: %w", ctx.Err())
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+func (d *SQLiteDriver) Unlock(ctx context.Context) error {
+	_, err := d.conn.ExecContext(ctx, `DELETE FROM schema_migrations_lock WHERE id = 1`)
+	return err
+}
+
+func (d *SQLiteDriver) Init(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TEXT NOT NULL
+		)
+	`
+	_, err := d.conn.ExecContext(ctx, query)
+	return err
+}
+
+func (d *SQLiteDriver) Records(ctx context.Context) ([]Record, error) {
+	query := `
+		SELECT version, name, checksum, dirty, applied_at
+		FROM schema_migrations
+		ORDER BY version
+	`
+	rows, err := d.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		var appliedAt string
+		if err := rows.Scan(&r.Version, &r.Name, &r.Checksum, &r.Dirty, &appliedAt); err != nil {
+			return nil, err
+		}
+		if r.AppliedAt, err = time.Parse(sqliteTimeLayout, appliedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (d *SQLiteDriver) Apply(ctx context.Context, m Migration, script string, up bool) error {
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	if up {
+		query := `
+			INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at)
+			VALUES (?, ?, ?, TRUE, ?)
+		`
+		if _, err := d.conn.ExecContext(ctx, query, m.Version, m.Name, m.Checksum, now); err != nil {
+			return err
+		}
+	} else {
+		query := `UPDATE schema_migrations SET dirty = TRUE WHERE version = ?`
+		if _, err := d.conn.ExecContext(ctx, query, m.Version); err != nil {
+			return err
+		}
+	}
+
+	return d.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+		if up {
+			query := `UPDATE schema_migrations SET dirty = FALSE, applied_at = ? WHERE version = ?`
+			_, err := tx.ExecContext(ctx, query, now, m.Version)
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+		return err
+	})
+}
+
+func (d *SQLiteDriver) Reset(ctx context.Context, records []Record) error {
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	return d.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		query := `
+			INSERT INTO schema_migrations (version, name, checksum, applied_at)
+			VALUES (?, ?, ?, ?)
+		`
+		for _, r := range records {
+			if _, err := tx.ExecContext(ctx, query, r.Version, r.Name, r.Checksum, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d *SQLiteDriver) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
@@ -1,20 +1,468 @@
 package models
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	Password    string `json:"-"`
+	Role        string `json:"role"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	// EmailVerifiedAt is when the owner of Email confirmed it, or nil if
+	// they have not.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Timezone        string     `json:"timezone"`
+	Locale          string     `json:"locale"`
+	Disabled        bool       `json:"disabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// ProfileUpdate changes the profile fields of a user's own account. Nil
+// fields are left unchanged; an empty Email removes the address. Changing
+// the email address needs the CurrentPassword.
+type ProfileUpdate struct {
+	DisplayName     *string `json:"display_name"`
+	Email           *string `json:"email"`
+	Timezone        *string `json:"timezone"`
+	Locale          *string `json:"locale"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// User roles.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions that can be granted to roles. Everyone may act on their own
+// todos, tags and projects; permissions extend that to other users' data and
+// to administration. The admin role holds every permission.
+const (
+	PermTodosReadAny       = "todos:read:any"
+	PermTodosWriteAny      = "todos:write:any"
+	PermTodosForceComplete = "todos:complete:force"
+	PermProjectsReadAny    = "projects:read:any"
+	PermProjectsWriteAny   = "projects:write:any"
+	PermTagsWriteAny       = "tags:write:any"
+	PermUsersRead          = "users:read"
+	PermUsersManage        = "users:manage"
+	PermRolesManage        = "roles:manage"
+)
+
+// Permissions lists every permission that can be granted.
+var Permissions = []string{
+	PermTodosReadAny,
+	PermTodosWriteAny,
+	PermTodosForceComplete,
+	PermProjectsReadAny,
+	PermProjectsWriteAny,
+	PermTagsWriteAny,
+	PermUsersRead,
+	PermUsersManage,
+	PermRolesManage,
+}
+
+// Role is a named set of permissions assigned to users.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Principal is the authenticated caller a request acts on behalf of.
+type Principal struct {
+	UserID int
+	Role   string
+}
+
+// UserFilter narrows a user listing. Empty fields are ignored.
+type UserFilter struct {
+	// Query matches usernames containing it, ignoring case.
+	Query    string
+	Role     string
+	Disabled *bool
+	// Limit caps the number of users returned; Offset only applies with it.
+	Limit  int
+	Offset int
+}
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the token
+// is kept. Tokens issued by rotating one another share a FamilyID, so a
+// whole login can be revoked at once.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TokenRevocation invalidates access tokens before they expire. It names a
+// single token by its JTI, every token issued from the login SessionID or,
+// with neither, every token of the user issued at or before IssuedBefore.
+// ExpiresAt is when the revoked tokens have expired anyway and the
+// revocation can be forgotten.
+type TokenRevocation struct {
+	UserID       int
+	JTI          string
+	SessionID    string
+	IssuedBefore *time.Time
+	ExpiresAt    time.Time
+}
+
+// TokenPair is what a successful login or refresh hands to the client.
+// ExpiresIn is the lifetime of the access token in seconds.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// UserIdentity links a user to their account at an OpenID Connect
+// provider, identified by the provider's issuer and the account's subject.
+type UserIdentity struct {
+	Issuer    string
+	Subject   string
+	UserID    int
+	CreatedAt time.Time
+}
+
+// TOTPEnrollment is a user's authenticator app secret. It only guards
+// logins once Enabled, after the user has proved their app works. LastStep
+// is the time step of the last accepted code, which cannot be used again.
+type TOTPEnrollment struct {
+	UserID    int
+	Secret    string
+	Enabled   bool
+	LastStep  int64
+	CreatedAt time.Time
+	EnabledAt *time.Time
+}
+
+// TOTPSetup is handed to a user enrolling an authenticator app. URI is the
+// otpauth:// provisioning URI to show as a QR code.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAStatus describes a user's second factors.
+type MFAStatus struct {
+	TOTPEnabled       bool `json:"totp_enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// MFAChallenge is what a login with a correct password gets when the account
+// has two-factor authentication enabled. MFAToken is exchanged together with
+// a code at POST /login/mfa within ExpiresIn seconds.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// MFATokenAttempts tracks an MFA token, by its JTI, until it expires:
+// how many codes were tried with it and when it completed a login.
+type MFATokenAttempts struct {
+	JTI       string
+	Attempts  int
+	UsedAt    *time.Time
+	ExpiresAt time.Time
+}
+
+// Kinds of login throttles: failed logins are counted per username and per
+// client IP address.
+const (
+	ThrottleUsername = "username"
+	ThrottleIP       = "ip"
+)
+
+// LoginThrottle counts the recent failed logins of a username or client IP.
+// Once there are too many, logins are refused until LockedUntil.
+type LoginThrottle struct {
+	Kind          string
+	Subject       string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// LockoutEvent records a username or client IP being locked out of logging
+// in. IP is the address of the failed login that caused it.
+type LockoutEvent struct {
+	ID          int       `json:"id"`
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	IP          string    `json:"ip"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LockoutFilter narrows a lockout event listing. Empty fields are ignored.
+type LockoutFilter struct {
+	Kind    string
+	Subject string
+	// Limit caps the number of events returned; Offset only applies with it.
+	Limit  int
+	Offset int
+}
+
+// PersonalAccessToken lets scripts call the API as a user without their
+// password, limited to Scopes. Only the SHA-256 hash of the token is kept;
+// Token is only set in the response that creates it.
+type PersonalAccessToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Scopes of personal access tokens. Each area has a read scope for GET
+// requests and a write scope, which includes read, for the rest.
+const (
+	ScopeTodosRead     = "todos:read"
+	ScopeTodosWrite    = "todos:write"
+	ScopeTagsRead      = "tags:read"
+	ScopeTagsWrite     = "tags:write"
+	ScopeProjectsRead  = "projects:read"
+	ScopeProjectsWrite = "projects:write"
+	ScopeAdminRead     = "admin:read"
+	ScopeAdminWrite    = "admin:write"
+)
+
+// Scopes lists every scope a personal access token can have.
+var Scopes = []string{
+	ScopeTodosRead,
+	ScopeTodosWrite,
+	ScopeTagsRead,
+	ScopeTagsWrite,
+	ScopeProjectsRead,
+	ScopeProjectsWrite,
+	ScopeAdminRead,
+	ScopeAdminWrite,
 }
 
 type Todo struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Completed   bool       `json:"completed"`
+	Priority    int        `json:"priority"`
+	StartAt     *time.Time `json:"start_at,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ProjectID   int        `json:"project_id"`
+	ParentID    *int       `json:"parent_id,omitempty"`
+	RollUp      string     `json:"rollup,omitempty"`
+	Recurrence  string     `json:"recurrence,omitempty"`
+	SeriesID    *int       `json:"series_id,omitempty"`
+	Occurrence  int        `json:"occurrence,omitempty"`
+	UserID      int        `json:"user_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Tags        []*Tag     `json:"tags,omitempty"`
+	BlockedBy   []*TodoRef `json:"blocked_by,omitempty"`
+	Blocking    []*TodoRef `json:"blocking,omitempty"`
+}
+
+// TodoRef identifies another todo in a dependency list.
+type TodoRef struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+// Roll-up rules a parent todo can apply to its subtasks through
+// Todo.RollUp. The zero value leaves completion entirely manual.
+const (
+	RollUpNone = ""
+	// RollUpAutoComplete completes the parent once all of its subtasks are
+	// completed, and reopens it when one of them is reopened.
+	RollUpAutoComplete = "auto_complete"
+	// RollUpCascade completes every subtask, at any depth, when the parent
+	// is completed.
+	RollUpCascade = "cascade"
+)
+
+// TodoOccurrence is a future instance of a recurring todo, as previewed
+// before it exists. Occurrence counts from 1 at the first todo of the series.
+type TodoOccurrence struct {
+	Occurrence int        `json:"occurrence"`
+	StartAt    *time.Time `json:"start_at,omitempty"`
+	DueAt      time.Time  `json:"due_at"`
+}
+
+// TodoNode is a todo with its subtasks. Progress is the percentage of
+// completed leaf subtasks and is only set on todos that have subtasks.
+type TodoNode struct {
+	*Todo
+	Progress *int        `json:"progress,omitempty"`
+	Children []*TodoNode `json:"children,omitempty"`
+}
+
+// Todo priorities, from lowest to highest.
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// Tag is a user defined label that can be attached to any of the user's
+// todos.
+type Tag struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Name       string    `json:"name"`
+	Color      string    `json:"color,omitempty"`
+	UsageCount int       `json:"usage_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Project groups todos into a list. Every user has exactly one Inbox
+// project, which receives todos created without a project and cannot be
+// archived or deleted.
+type Project struct {
 	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description,omitempty"`
-	Completed   bool      `json:"completed"`
 	UserID      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Color       string    `json:"color,omitempty"`
+	Archived    bool      `json:"archived"`
+	Inbox       bool      `json:"inbox"`
 	CreatedAt   time.Time `json:"created_at"`
 }
+
+// InboxProjectName is the name given to a user's Inbox project.
+const InboxProjectName = "Inbox"
+
+// TodoFilter narrows a todo listing. Nil and empty fields are ignored.
+type TodoFilter struct {
+	UserID        *int
+	Completed     *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Query         string
+	View          *TodoView
+	// TagIDs keeps todos carrying any of the tags, or all of them when
+	// AllTags is set.
+	TagIDs  []int
+	AllTags bool
+	// ProjectID keeps the todos of one project, archived or not. Without
+	// it, todos of archived projects are left out unless IncludeArchived
+	// is set.
+	ProjectID       *int
+	IncludeArchived bool
+	// ParentID keeps the direct subtasks of a todo.
+	ParentID *int
+}
+
+// Planning views selectable through TodoFilter.View.
+const (
+	// TodoViewToday lists open todos due or starting today.
+	TodoViewToday = "today"
+	// TodoViewUpcoming lists open todos due or starting after today.
+	TodoViewUpcoming = "upcoming"
+	// TodoViewOverdue lists open todos whose due date has passed.
+	TodoViewOverdue = "overdue"
+	// TodoViewSomeday lists open todos with neither a start nor a due date.
+	TodoViewSomeday = "someday"
+)
+
+// TodoView restricts a listing to a planning view. The day boundaries are
+// those of the current day in the user's timezone.
+type TodoView struct {
+	Name     string
+	Now      time.Time
+	DayStart time.Time
+	DayEnd   time.Time
+}
+
+// UserTodoStats aggregates the todos owned by a single user.
+type UserTodoStats struct {
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+}
+
+// Sort fields accepted by TodoListOptions.Sort.
+const (
+	TodoSortCreatedAt = "created_at"
+	TodoSortTitle     = "title"
+	TodoSortCompleted = "completed"
+	TodoSortDueAt     = "due_at"
+	TodoSortPriority  = "priority"
+)
+
+// NoDueDate stands in for a missing due date when ordering by due_at, so
+// todos without one sort after every dated todo.
+var NoDueDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// TodoCursor marks the row after which a keyset paginated listing resumes.
+// Value is the sort key of that row in its SortValue form.
+type TodoCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int    `json:"i"`
+}
+
+// TodoListOptions selects, orders and pages a todo listing. Rows are ordered
+// by Sort and then by ID so that the order is total. A zero Limit returns
+// every matching row.
+type TodoListOptions struct {
+	Filter TodoFilter
+	Sort   string
+	Desc   bool
+	Limit  int
+	After  *TodoCursor
+}
+
+// TodoPage is one page of a todo listing.
+type TodoPage struct {
+	Todos      []*Todo `json:"todos"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// SortValue returns the value of the sort field as stored in a TodoCursor.
+func (t *Todo) SortValue(field string) string {
+	switch field {
+	case TodoSortTitle:
+		return t.Title
+	case TodoSortCompleted:
+		return strconv.FormatBool(t.Completed)
+	case TodoSortPriority:
+		return strconv.Itoa(t.Priority)
+	case TodoSortDueAt:
+		if t.DueAt == nil {
+			return NoDueDate.Format(time.RFC3339Nano)
+		}
+		return t.DueAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return t.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// TodoSearchResult is a todo matched by a full text search. Higher ranks are
+// better matches. TitleHighlight and Snippet mark the matched words.
+type TodoSearchResult struct {
+	Todo           *Todo   `json:"todo"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet,omitempty"`
+}
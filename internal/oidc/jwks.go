@@ -0,0 +1,206 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval is how long to wait before fetching the JWK Set again
+// for a kid it lacks, so that made-up kids cannot make us hammer the
+// provider.
+const jwksRefreshInterval = time.Minute
+
+// errUnknownKey is returned for ID tokens signed with a key not in the
+// provider's JWK Set.
+var errUnknownKey = errors.New("unknown signing key")
+
+// jwk is a JSON Web Key as published in a JWK Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey is a signing key of the provider.
+type publicKey struct {
+	kid string
+	// alg is the algorithm the key is restricted to, if any.
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet caches the provider's JWK Set, fetching it again when a token
+// names a key it lacks, as happens after the provider rotates its keys.
+type keySet struct {
+	client *http.Client
+	url    string
+
+	mu        sync.Mutex
+	keys      []publicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(client *http.Client, url string) *keySet {
+	return &keySet{client: client, url: url}
+}
+
+// key returns the key verifying a token with kid and alg. Tokens without a
+// kid are verified with the only suitable key, if there is just one.
+func (ks *keySet) key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if key := ks.find(kid, alg); key != nil {
+		return key, nil
+	}
+	if !ks.fetchedAt.IsZero() && time.Since(ks.fetchedAt) < jwksRefreshInterval {
+		return nil, errUnknownKey
+	}
+	if err := ks.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if key := ks.find(kid, alg); key != nil {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+func (ks *keySet) find(kid, alg string) crypto.PublicKey {
+	var candidates []crypto.PublicKey
+	for _, k := range ks.keys {
+		if (kid == "" || k.kid == kid) && (k.alg == "" || k.alg == alg) && suits(k.key, alg) {
+			candidates = append(candidates, k.key)
+		}
+	}
+	if len(candidates) != 1 {
+		return nil
+	}
+	return candidates[0]
+}
+
+func (ks *keySet) fetch(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	ks.fetchedAt = time.Now()
+	if err := getJSON(ctx, ks.client, ks.url, &set); err != nil {
+		return fmt.Errorf("fetching JWK Set: %w", err)
+	}
+
+	ks.keys = ks.keys[:0]
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			log.Printf("Skipping key %q of %s: %v", k.Kid, ks.url, err)
+			continue
+		}
+		ks.keys = append(ks.keys, publicKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return nil
+}
+
+// suits reports whether key can verify tokens signed with alg.
+func suits(key crypto.PublicKey, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return alg == "ES"+map[string]string{"P-256": "256", "P-384": "384", "P-521": "512"}[k.Curve.Params().Name]
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA key shorter than 2048 bits")
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
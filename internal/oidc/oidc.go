@@ -0,0 +1,343 @@
+// Package oidc is an OpenID Connect relying party: it discovers a
+// provider, sends users there with the authorization code flow protected by
+// PKCE, exchanges the code for tokens and validates the ID token that names
+// the user.
+//
+// Only what logging users in needs is implemented. The provider's access
+// token is not used, and ID tokens must be signed with a public key from
+// its JWK Set; HMAC and unsigned tokens are rejected.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// maxResponseSize bounds the responses read from a provider.
+	maxResponseSize = 1 << 20
+	// clockSkew is how far the provider's clock may be off when checking
+	// the times in ID tokens.
+	clockSkew = time.Minute
+	// requestTimeout bounds requests to the provider made with the default
+	// HTTP client.
+	requestTimeout = 10 * time.Second
+)
+
+// signingAlgorithms are the ID token algorithms accepted.
+var signingAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var (
+	// ErrInvalidIDToken is returned for ID tokens that fail validation.
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrNoIDToken is returned when the token endpoint does not return an
+	// ID token.
+	ErrNoIDToken = errors.New("token response has no id_token")
+)
+
+// Config configures a relying party.
+type Config struct {
+	// Issuer is the provider's issuer URL, where its discovery document is
+	// found under /.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to with the code.
+	RedirectURL string
+	// Scopes requested, "openid" included.
+	Scopes []string
+	// HTTPClient talks to the provider. If nil, a client with a timeout of
+	// requestTimeout is used.
+	HTTPClient *http.Client
+}
+
+// Provider is the part of a provider's discovery document the relying party
+// uses.
+type Provider struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	CodeChallengeMethods     []string `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+	IDTokenSigningAlgValues  []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// IDToken is a validated ID token.
+type IDToken struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	// AuthTime is when the user authenticated at the provider, or zero if
+	// the provider did not say.
+	AuthTime time.Time
+	// Claims holds every claim of the token.
+	Claims map[string]any
+}
+
+// Strings returns the claim as a list of strings, accepting a single string
+// as well. It is meant for claims such as groups.
+func (t *IDToken) Strings(claim string) []string {
+	switch v := t.Claims[claim].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// RelyingParty logs users in with a provider. The provider is discovered
+// on first use, and discovery is retried until it succeeds, so the provider
+// does not need to be up when the relying party is created.
+type RelyingParty struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	provider *Provider
+	keys     *keySet
+}
+
+func New(config Config) *RelyingParty {
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &RelyingParty{config: config, client: client}
+}
+
+// Issuer returns the configured issuer URL.
+func (rp *RelyingParty) Issuer() string {
+	return rp.config.Issuer
+}
+
+// Provider returns the provider's discovery document, fetching it the first
+// time.
+func (rp *RelyingParty) Provider(ctx context.Context) (*Provider, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.provider != nil {
+		return rp.provider, nil
+	}
+
+	p := &Provider{}
+	wellKnown := strings.TrimSuffix(rp.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, rp.client, wellKnown, p); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", rp.config.Issuer, err)
+	}
+	if p.Issuer != rp.config.Issuer {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %q", rp.config.Issuer, p.Issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document of %s lacks an endpoint", rp.config.Issuer)
+	}
+	if len(p.CodeChallengeMethods) > 0 && !slices.Contains(p.CodeChallengeMethods, "S256") {
+		return nil, fmt.Errorf("%s does not support PKCE with S256", rp.config.Issuer)
+	}
+	rp.provider = p
+	rp.keys = newKeySet(rp.client, p.JWKSURI)
+	return p, nil
+}
+
+// AuthCodeURL returns the provider URL to send the user to. state comes
+// back with the code, nonce comes back in the ID token and verifier is the
+// PKCE code verifier to exchange the code with, from NewVerifier. With
+// reauth, the provider is asked to authenticate the user again even if they
+// are logged in there.
+func (rp *RelyingParty) AuthCodeURL(ctx context.Context, state, nonce, verifier string, reauth bool) (string, error) {
+	p, err := rp.Provider(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(p.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", rp.config.ClientID)
+	q.Set("redirect_uri", rp.config.RedirectURL)
+	q.Set("scope", strings.Join(rp.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	if reauth {
+		q.Set("prompt", "login")
+		q.Set("max_age", "0")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange trades an authorization code for an ID token, which it
+// validates against nonce.
+func (rp *RelyingParty) Exchange(ctx context.Context, code, verifier, nonce string) (*IDToken, error) {
+	p, err := rp.Provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.config.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {rp.config.ClientID},
+	}
+	basicAuth := rp.config.ClientSecret != "" && (len(p.TokenEndpointAuthMethods) == 0 ||
+		slices.Contains(p.TokenEndpointAuthMethods, "client_secret_basic"))
+	if rp.config.ClientSecret != "" && !basicAuth {
+		form.Set("client_secret", rp.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(rp.config.ClientID), url.QueryEscape(rp.config.ClientSecret))
+	}
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("token endpoint answered %s: %w", resp.Status, err)
+	}
+	if body.Error != "" {
+		return nil, fmt.Errorf("token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint answered %s", resp.Status)
+	}
+	if body.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+	return rp.Verify(ctx, body.IDToken, nonce)
+}
+
+// Verify validates an ID token: its signature, issuer, audience, times and
+// nonce.
+func (rp *RelyingParty) Verify(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	p, err := rp.Provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	algorithms := signingAlgorithms
+	if len(p.IDTokenSigningAlgValues) > 0 {
+		algorithms = slices.DeleteFunc(slices.Clone(p.IDTokenSigningAlgValues), func(alg string) bool {
+			return !slices.Contains(signingAlgorithms, alg)
+		})
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return rp.keys.key(ctx, kid, token.Method.Alg())
+	},
+		jwt.WithValidMethods(algorithms),
+		jwt.WithIssuer(rp.config.Issuer),
+		jwt.WithAudience(rp.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	// A token for several audiences must name us as the party it was
+	// issued to.
+	audience, _ := claims.GetAudience()
+	if azp, ok := claims["azp"].(string); (len(audience) > 1 || ok) && azp != rp.config.ClientID {
+		return nil, fmt.Errorf("%w: issued to %q", ErrInvalidIDToken, azp)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	token := &IDToken{Issuer: rp.config.Issuer, Subject: subject, Claims: claims}
+	token.Email, _ = claims["email"].(string)
+	token.Name, _ = claims["name"].(string)
+	token.PreferredUsername, _ = claims["preferred_username"].(string)
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		token.AuthTime = time.Unix(int64(authTime), 0)
+	}
+	// Some providers send email_verified as a string.
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		token.EmailVerified = v
+	case string:
+		token.EmailVerified = v == "true"
+	}
+	return token, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// NewVerifier returns a random PKCE code verifier.
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// challenge returns the S256 code challenge of verifier.
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,230 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "todo-api"
+	testRedirectURL = "http://localhost:8080/oidc/callback"
+)
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *RelyingParty) {
+	t.Helper()
+	srv, err := oidctest.NewServer(testClientID, map[string]any{
+		"sub":                "alice-1",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     "true",
+		"groups":             []string{"staff", "admins"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	rp := New(Config{
+		Issuer:       srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email"},
+		HTTPClient:   srv.Client(),
+	})
+	return srv, rp
+}
+
+func TestLogin(t *testing.T) {
+	srv, rp := newTestProvider(t)
+	ctx := context.Background()
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := rp.AuthCodeURL(ctx, "the-state", "the-nonce", verifier, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := srv.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := back.Query().Get("state"); got != "the-state" {
+		t.Errorf("state = %q, want the-state", got)
+	}
+	code := back.Query().Get("code")
+	if code == "" {
+		t.Fatalf("no code in %s", back)
+	}
+
+	if _, err := rp.Exchange(ctx, code, "another-verifier", "the-nonce"); err == nil {
+		t.Error("Exchange with the wrong verifier succeeded")
+	}
+	back, err = srv.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := rp.Exchange(ctx, back.Query().Get("code"), verifier, "the-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Issuer != srv.URL || token.Subject != "alice-1" || token.PreferredUsername != "alice" ||
+		token.Email != "alice@example.com" || !token.EmailVerified {
+		t.Errorf("Exchange() = %+v", token)
+	}
+	if time.Since(token.AuthTime) > time.Minute {
+		t.Errorf("AuthTime = %v, want about now", token.AuthTime)
+	}
+	if groups := token.Strings("groups"); !slices.Equal(groups, []string{"staff", "admins"}) {
+		t.Errorf("Strings(groups) = %v", groups)
+	}
+	if _, err := rp.Exchange(ctx, back.Query().Get("code"), verifier, "the-nonce"); err == nil {
+		t.Error("Exchange of a used code succeeded")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	_, rp := newTestProvider(t)
+	for _, reauth := range []bool{false, true} {
+		raw, err := rp.AuthCodeURL(context.Background(), "state", "nonce", "verifier", reauth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL ||
+			q.Get("scope") != "openid email" || q.Get("code_challenge") != challenge("verifier") ||
+			q.Get("code_challenge_method") != "S256" {
+			t.Errorf("AuthCodeURL(reauth %v) = %s", reauth, raw)
+		}
+		if got := q.Get("prompt") == "login" && q.Get("max_age") == "0"; got != reauth {
+			t.Errorf("AuthCodeURL(reauth %v) asks to log in again: %v", reauth, got)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srv, rp := newTestProvider(t)
+	other, err := oidctest.New(srv.URL, testClientID, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   srv.URL,
+			"aud":   testClientID,
+			"sub":   "alice-1",
+			"nonce": "the-nonce",
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(claims map[string]any)
+		// signer signs the token instead of the provider.
+		signer  *oidctest.Provider
+		wantErr bool
+	}{
+		{name: "valid", change: func(map[string]any) {}},
+		{name: "nonce mismatch", change: func(c map[string]any) { c["nonce"] = "another-nonce" }, wantErr: true},
+		{name: "no nonce", change: func(c map[string]any) { delete(c, "nonce") }, wantErr: true},
+		{name: "wrong issuer", change: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, wantErr: true},
+		{name: "wrong audience", change: func(c map[string]any) { c["aud"] = "another-client" }, wantErr: true},
+		{name: "no audience", change: func(c map[string]any) { delete(c, "aud") }, wantErr: true},
+		{
+			name:   "several audiences issued to us",
+			change: func(c map[string]any) { c["aud"] = []string{testClientID, "another-client"}; c["azp"] = testClientID },
+		},
+		{
+			name:    "several audiences without azp",
+			change:  func(c map[string]any) { c["aud"] = []string{testClientID, "another-client"} },
+			wantErr: true,
+		},
+		{
+			name: "several audiences issued to another party",
+			change: func(c map[string]any) {
+				c["aud"] = []string{testClientID, "another-client"}
+				c["azp"] = "another-client"
+			},
+			wantErr: true,
+		},
+		{name: "azp of another party", change: func(c map[string]any) { c["azp"] = "another-client" }, wantErr: true},
+		{name: "expired", change: func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }, wantErr: true},
+		{name: "no expiry", change: func(c map[string]any) { delete(c, "exp") }, wantErr: true},
+		{name: "issued in the future", change: func(c map[string]any) { c["iat"] = now.Add(time.Hour).Unix() }, wantErr: true},
+		{name: "no subject", change: func(c map[string]any) { delete(c, "sub") }, wantErr: true},
+		{name: "signed by another key", change: func(map[string]any) {}, signer: other, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.change(claims)
+			signer := srv.Provider
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+			raw, err := signer.SignIDToken(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := rp.Verify(context.Background(), raw, "the-nonce")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Errorf("Verify() = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() = %v", err)
+			}
+			if token.Subject != "alice-1" {
+				t.Errorf("Subject = %q, want alice-1", token.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsHMAC(t *testing.T) {
+	srv, rp := newTestProvider(t)
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   srv.URL,
+		"aud":   testClientID,
+		"sub":   "alice-1",
+		"nonce": "the-nonce",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = oidctest.KeyID
+	// The client secret is known to the client, so it must not be able to
+	// sign ID tokens.
+	raw, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.Verify(context.Background(), raw, "the-nonce"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Verify() = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	srv, _ := newTestProvider(t)
+	rp := New(Config{Issuer: srv.URL + "/", ClientID: testClientID, HTTPClient: srv.Client()})
+	if _, err := rp.Provider(context.Background()); err == nil {
+		t.Error("Provider() accepted a discovery document for another issuer")
+	}
+}
@@ -0,0 +1,321 @@
+// Package oidctest is a stand-in OpenID Connect provider for trying out and
+// testing single sign-on. It logs every authorization request in as one
+// user without asking, and signs ID tokens with an RSA key generated when
+// it is created. Never expose it: anyone reaching it can log in.
+//
+// Tests start one with NewServer; cmd/mockidp serves one for running the
+// API against locally.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	codeTTL    = time.Minute
+	idTokenTTL = 5 * time.Minute
+	// KeyID is the kid of the provider's signing key.
+	KeyID = "mock"
+)
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      map[string]any
+	authTime    time.Time
+	expiresAt   time.Time
+}
+
+// Provider is the mock provider. Its fields must not change once it serves
+// requests.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	user   map[string]any
+	grants map[string]*grant
+}
+
+// New returns a provider for the client clientID, which authenticates with
+// clientSecret unless it is empty, logging everyone in as the user with the
+// given ID token claims. user must have a "sub".
+func New(issuer, clientID, clientSecret string, user map[string]any) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+	return &Provider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		user:         user,
+		grants:       make(map[string]*grant),
+	}, nil
+}
+
+// Handler serves the provider: discovery, its JWK Set, the authorization
+// and token endpoints, and PUT /user to change the user logged in.
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("PUT /user", p.setUser)
+	return mux
+}
+
+// SetUser replaces the ID token claims of the user logged in by later
+// authorization requests. Claims the provider sets itself, such as "aud",
+// "nonce" or "auth_time", are taken from user if it has them, so that tests
+// can have it issue invalid tokens.
+func (p *Provider) SetUser(user map[string]any) {
+	p.mu.Lock()
+	p.user = user
+	p.mu.Unlock()
+}
+
+// SignIDToken signs claims as they are with the provider's key.
+func (p *Provider) SignIDToken(claims map[string]any) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims(claims))
+	token.Header["kid"] = KeyID
+	return token.SignedString(p.key)
+}
+
+// Server is a Provider served by an httptest.Server, its issuer being the
+// server's URL.
+type Server struct {
+	*Provider
+	*httptest.Server
+}
+
+// NewServer starts a provider for the client clientID with the secret
+// "secret", logging everyone in as the user with the given ID token claims.
+// Close it when done.
+func NewServer(clientID string, user map[string]any) (*Server, error) {
+	p, err := New("", clientID, "secret", user)
+	if err != nil {
+		return nil, err
+	}
+	srv := httptest.NewServer(p.Handler())
+	p.Issuer = srv.URL
+	return &Server{Provider: p, Server: srv}, nil
+}
+
+// Authorize requests authURL as a browser sent to the provider would, and
+// returns the URL the provider redirects back to, carrying the code and
+// state or an error.
+func (s *Server) Authorize(authURL string) (*url.URL, error) {
+	client := *s.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("authorization endpoint answered %s", resp.Status)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return nil, errors.New("authorization endpoint did not redirect")
+	}
+	return location, nil
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": KeyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// authorize logs the current user in and sends them back with a code,
+// checking the request as a real provider would.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	back := redirectURI.Query()
+	back.Set("state", q.Get("state"))
+	switch {
+	case q.Get("response_type") != "code":
+		back.Set("error", "unsupported_response_type")
+	case !strings.Contains(" "+q.Get("scope")+" ", " openid "):
+		back.Set("error", "invalid_scope")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		back.Set("error", "invalid_request")
+		back.Set("error_description", "PKCE with S256 is required")
+	default:
+		code := randomString()
+		p.mu.Lock()
+		p.grants[code] = &grant{
+			clientID:    p.ClientID,
+			redirectURI: redirectURI.String(),
+			challenge:   q.Get("code_challenge"),
+			nonce:       q.Get("nonce"),
+			claims:      p.user,
+			authTime:    time.Now(),
+			expiresAt:   time.Now().Add(codeTTL),
+		}
+		p.mu.Unlock()
+		back.Set("code", code)
+	}
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token once the client and the PKCE code
+// verifier check out. Codes work once.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+	if !p.authenticateClient(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mockidp"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	g := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if g == nil || time.Now().After(g.expiresAt) || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "unknown, used or expired code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		tokenError(w, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss": p.Issuer,
+		"aud": g.clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(idTokenTTL).Unix(),
+		// Every authorization request logs the user in.
+		"auth_time": g.authTime.Unix(),
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	maps.Copy(claims, g.claims)
+	idToken, err := p.SignIDToken(claims)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+	log.Printf("Issued ID token for %v", claims["sub"])
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (p *Provider) authenticateClient(r *http.Request) bool {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	return id == p.ClientID && subtle.ConstantTimeCompare([]byte(secret), []byte(p.ClientSecret)) == 1
+}
+
+// setUser replaces the claims of the user logged in by later requests.
+func (p *Provider) setUser(w http.ResponseWriter, r *http.Request) {
+	var claims map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&claims); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		http.Error(w, "sub is required", http.StatusBadRequest)
+		return
+	}
+	p.SetUser(claims)
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,95 @@
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+var b64 = base64.RawStdEncoding
+
+// Argon2id hashes with argon2id (RFC 9106).
+type Argon2id struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+}
+
+// NewArgon2id returns argon2id using memory KiB of memory, time passes and
+// parallelism lanes.
+func NewArgon2id(memory, time uint32, parallelism uint8) (*Argon2id, error) {
+	if time < 1 || parallelism < 1 || memory < 8*uint32(parallelism) {
+		return nil, errors.New("argon2id needs time and parallelism of at least 1 and 8 KiB of memory per lane")
+	}
+	return &Argon2id{memory: memory, time: time, parallelism: parallelism}, nil
+}
+
+func (a *Argon2id) Name() string { return "argon2id" }
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.time, a.memory, a.parallelism, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.memory, a.time, a.parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (a *Argon2id) Verify(password, hash string) (bool, error) {
+	p, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	got := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(got, key) == 1, nil
+}
+
+func (a *Argon2id) Current(hash string) bool {
+	p, salt, key, err := parseArgon2id(hash)
+	return err == nil && *p == *a && len(salt) == argon2SaltLen && len(key) == argon2KeyLen
+}
+
+// parseArgon2id splits a PHC argon2id hash into its parameters, salt and
+// key.
+func parseArgon2id(hash string) (*Argon2id, []byte, []byte, error) {
+	invalid := fmt.Errorf("%w: malformed argon2id hash", ErrUnknownHash)
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, invalid
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, invalid
+	}
+	p := &Argon2id{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.parallelism); err != nil {
+		return nil, nil, nil, invalid
+	}
+	if p.time < 1 || p.parallelism < 1 {
+		return nil, nil, nil, invalid
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, invalid
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, invalid
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,105 @@
+// Package passhash hashes and verifies passwords.
+//
+// Hashes are self-describing strings in PHC format, such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, or the modular crypt format
+// bcrypt has always used ($2a$10$...). A Hasher creates hashes with one
+// algorithm but verifies hashes of every algorithm it knows, and tells when
+// a verified hash should be replaced because it uses another algorithm or
+// outdated parameters.
+package passhash
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrUnknownHash is returned for hashes no algorithm of a Hasher recognizes.
+var ErrUnknownHash = errors.New("unrecognized password hash")
+
+// Algorithm is a password hashing scheme with fixed parameters.
+type Algorithm interface {
+	// Name is the algorithm's PHC identifier, such as "argon2id".
+	Name() string
+	// Hash hashes password with a fresh random salt.
+	Hash(password string) (string, error)
+	// Recognizes reports whether hash was made by this algorithm, with
+	// whatever parameters.
+	Recognizes(hash string) bool
+	// Verify reports whether password matches hash, which the algorithm
+	// recognizes.
+	Verify(password, hash string) (bool, error)
+	// Current reports whether hash was made with this algorithm's
+	// parameters.
+	Current(hash string) bool
+}
+
+// Hasher hashes passwords with its preferred algorithm and verifies hashes
+// of any of its algorithms.
+type Hasher struct {
+	preferred Algorithm
+	known     []Algorithm
+}
+
+// NewHasher returns a Hasher creating hashes with preferred and also
+// verifying hashes of legacy algorithms.
+func NewHasher(preferred Algorithm, legacy ...Algorithm) *Hasher {
+	return &Hasher{preferred: preferred, known: append([]Algorithm{preferred}, legacy...)}
+}
+
+// Hash hashes password with the preferred algorithm.
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify reports whether password matches hash and, if it does, whether
+// hash should be replaced by a new Hash of the password because it was
+// made with another algorithm or other parameters than the preferred ones.
+func (h *Hasher) Verify(password, hash string) (ok, rehash bool, err error) {
+	for _, alg := range h.known {
+		if !alg.Recognizes(hash) {
+			continue
+		}
+		ok, err := alg.Verify(password, hash)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, alg != h.preferred || !alg.Current(hash), nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+// Params configures the algorithms of a Hasher.
+type Params struct {
+	// Argon2Memory is the memory argon2id uses, in KiB.
+	Argon2Memory      int
+	Argon2Time        int
+	Argon2Parallelism int
+	BcryptCost        int
+}
+
+// Configure returns a Hasher creating hashes with the algorithm named name,
+// "argon2id" or "bcrypt", and the parameters in p. It verifies hashes of
+// both algorithms, whatever their parameters.
+func Configure(name string, p Params) (*Hasher, error) {
+	var preferred Algorithm
+	var err error
+	switch name {
+	case "argon2id":
+		if p.Argon2Memory < 0 || p.Argon2Memory > math.MaxUint32 || p.Argon2Time < 0 || p.Argon2Time > math.MaxUint32 ||
+			p.Argon2Parallelism < 0 || p.Argon2Parallelism > math.MaxUint8 {
+			return nil, errors.New("argon2id parameters out of range")
+		}
+		preferred, err = NewArgon2id(uint32(p.Argon2Memory), uint32(p.Argon2Time), uint8(p.Argon2Parallelism))
+	case "bcrypt":
+		preferred, err = NewBcrypt(p.BcryptCost)
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Legacy algorithms only verify, which takes the parameters from the
+	// hash, so they need none of their own.
+	return NewHasher(preferred, &Argon2id{}, &Bcrypt{}), nil
+}
@@ -0,0 +1,175 @@
+package passhash
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const password = "correct horse battery staple"
+
+func TestParseArgon2id(t *testing.T) {
+	// Salt "saltsaltsaltsalt" and an arbitrary 4 byte key.
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "AQIDBA"
+	tests := []struct {
+		name    string
+		hash    string
+		want    Argon2id
+		wantErr bool
+	}{
+		{name: "valid", hash: "$argon2id$v=19$m=19456,t=2,p=1$" + salt + "$" + key, want: Argon2id{19456, 2, 1}},
+		{name: "other parameters", hash: "$argon2id$v=19$m=65536,t=3,p=4$" + salt + "$" + key, want: Argon2id{65536, 3, 4}},
+		{name: "argon2i", hash: "$argon2i$v=19$m=19456,t=2,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "old version", hash: "$argon2id$v=16$m=19456,t=2,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "missing version", hash: "$argon2id$m=19456,t=2,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "garbled parameters", hash: "$argon2id$v=19$t=2,m=19456,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "zero time", hash: "$argon2id$v=19$m=19456,t=0,p=1$" + salt + "$" + key, wantErr: true},
+		{name: "zero parallelism", hash: "$argon2id$v=19$m=19456,t=2,p=0$" + salt + "$" + key, wantErr: true},
+		{name: "padded salt", hash: "$argon2id$v=19$m=19456,t=2,p=1$" + salt + "==$" + key, wantErr: true},
+		{name: "empty key", hash: "$argon2id$v=19$m=19456,t=2,p=1$" + salt + "$", wantErr: true},
+		{name: "extra part", hash: "$argon2id$v=19$m=19456,t=2,p=1$" + salt + "$" + key + "$", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, gotSalt, gotKey, err := parseArgon2id(tt.hash)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownHash) {
+					t.Errorf("parseArgon2id() error = %v, want ErrUnknownHash", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgon2id(): %v", err)
+			}
+			if *p != tt.want || string(gotSalt) != "saltsaltsaltsalt" || len(gotKey) != 4 {
+				t.Errorf("parseArgon2id() = %+v, %q, %d byte key", *p, gotSalt, len(gotKey))
+			}
+		})
+	}
+}
+
+func TestHasherVerify(t *testing.T) {
+	argon, err := NewArgon2id(64, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakArgon, err := NewArgon2id(32, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost4, err := NewBcrypt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost5, err := NewBcrypt(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := func(alg Algorithm) string {
+		h, err := alg.Hash(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	// An argon2id hash with the preferred parameters but a short salt.
+	shortSalt := []byte("saltsalt")
+	shortSaltHash := fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version, b64.EncodeToString(shortSalt),
+		b64.EncodeToString(argon2.IDKey([]byte(password), shortSalt, 1, 64, 1, argon2KeyLen)))
+
+	argonFirst := NewHasher(argon, &Argon2id{}, &Bcrypt{})
+	bcryptFirst := NewHasher(cost5, &Argon2id{}, &Bcrypt{})
+	tests := []struct {
+		name       string
+		hasher     *Hasher
+		password   string
+		hash       string
+		wantOK     bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{"argon2id with preferred parameters", argonFirst, password, hash(argon), true, false, nil},
+		{"argon2id with other parameters", argonFirst, password, hash(weakArgon), true, true, nil},
+		{"argon2id with a short salt", argonFirst, password, shortSaltHash, true, true, nil},
+		{"bcrypt while argon2id is preferred", argonFirst, password, hash(cost4), true, true, nil},
+		{"wrong password for argon2id", argonFirst, "wrong", hash(argon), false, false, nil},
+		{"wrong password for bcrypt", argonFirst, "wrong", hash(cost4), false, false, nil},
+		{"bcrypt with preferred cost", bcryptFirst, password, hash(cost5), true, false, nil},
+		{"bcrypt with lower cost", bcryptFirst, password, hash(cost4), true, true, nil},
+		{"argon2id while bcrypt is preferred", bcryptFirst, password, hash(argon), true, true, nil},
+		{"unknown algorithm", argonFirst, password, "$1$salt$hash", false, false, ErrUnknownHash},
+		{"plain text", argonFirst, password, password, false, false, ErrUnknownHash},
+		{"malformed argon2id", argonFirst, password, "$argon2id$v=19$broken", false, false, ErrUnknownHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := tt.hasher.Verify(tt.password, tt.hash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf("Verify() = %v, %v, want %v, %v", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	argon, err := NewArgon2id(64, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, err := argon.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := argon.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 == h2 {
+		t.Error("two hashes of the same password are equal; salts are not random")
+	}
+	p, salt, key, err := parseArgon2id(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != *argon || len(salt) != argon2SaltLen || len(key) != argon2KeyLen {
+		t.Errorf("hash %s has parameters %+v, %d byte salt, %d byte key", h1, *p, len(salt), len(key))
+	}
+	if !argon.Current(h1) {
+		t.Errorf("Current(%s) = false for a fresh hash", h1)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		alg     string
+		params  Params
+		wantErr bool
+	}{
+		{"argon2id", "argon2id", Params{Argon2Memory: 64, Argon2Time: 1, Argon2Parallelism: 1}, false},
+		{"bcrypt", "bcrypt", Params{BcryptCost: 10}, false},
+		{"unknown algorithm", "scrypt", Params{}, true},
+		{"bcrypt cost too low", "bcrypt", Params{BcryptCost: 3}, true},
+		{"bcrypt cost too high", "bcrypt", Params{BcryptCost: 32}, true},
+		{"argon2id zero time", "argon2id", Params{Argon2Memory: 64, Argon2Time: 0, Argon2Parallelism: 1}, true},
+		{"argon2id too little memory per lane", "argon2id", Params{Argon2Memory: 15, Argon2Time: 1, Argon2Parallelism: 2}, true},
+		{"argon2id parallelism out of range", "argon2id", Params{Argon2Memory: 4096, Argon2Time: 1, Argon2Parallelism: 256}, true},
+		{"argon2id negative memory", "argon2id", Params{Argon2Memory: -1, Argon2Time: 1, Argon2Parallelism: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Configure(tt.alg, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Configure() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && h.preferred.Name() != tt.alg {
+				t.Errorf("preferred algorithm is %s, want %s", h.preferred.Name(), tt.alg)
+			}
+		})
+	}
+}
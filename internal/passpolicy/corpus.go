@@ -0,0 +1,94 @@
+package passpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Corpus is a set of breached passwords known only by their SHA-1 hashes.
+// It is queried the way the Pwned Passwords range API is: by the first five
+// hex digits of a hash, answering with the rest of every hash that shares
+// them, so that a password is never looked up by its full hash.
+type Corpus struct {
+	// hashes is sorted, so that the hashes of a range are adjacent.
+	hashes [][sha1.Size]byte
+}
+
+// LoadCorpus reads a corpus file in the format of the Pwned Passwords
+// downloads: a SHA-1 hash in hex per line, optionally followed by ":" and a
+// count, which is ignored. Empty lines and lines starting with "#" are
+// skipped.
+func LoadCorpus(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Corpus{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digits, _, _ := strings.Cut(line, ":")
+		var hash [sha1.Size]byte
+		if len(digits) != hex.EncodedLen(sha1.Size) {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, n)
+		}
+		if _, err := hex.Decode(hash[:], []byte(digits)); err != nil {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, n)
+		}
+		c.hashes = append(c.hashes, hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(c.hashes, func(a, b [sha1.Size]byte) int { return bytes.Compare(a[:], b[:]) })
+	c.hashes = slices.Compact(c.hashes)
+	return c, nil
+}
+
+// Len returns the number of passwords in the corpus.
+func (c *Corpus) Len() int {
+	return len(c.hashes)
+}
+
+// Range returns the last 35 hex digits, in upper case, of the hashes
+// starting with prefix, five hex digits.
+func (c *Corpus) Range(prefix string) []string {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != 5 {
+		return nil
+	}
+	// Five hex digits are the first two and a half bytes of a hash.
+	var start [sha1.Size]byte
+	if _, err := hex.Decode(start[:3], []byte(prefix+"0")); err != nil {
+		return nil
+	}
+	i, _ := slices.BinarySearchFunc(c.hashes, start, func(h, t [sha1.Size]byte) int { return bytes.Compare(h[:], t[:]) })
+
+	var suffixes []string
+	for ; i < len(c.hashes); i++ {
+		digits := strings.ToUpper(hex.EncodeToString(c.hashes[i][:]))
+		if !strings.HasPrefix(digits, prefix) {
+			break
+		}
+		suffixes = append(suffixes, digits[5:])
+	}
+	return suffixes
+}
+
+// Contains reports whether password is in the corpus.
+func (c *Corpus) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	digits := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return slices.Contains(c.Range(digits[:5]), digits[5:])
+}
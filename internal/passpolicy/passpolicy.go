@@ -0,0 +1,131 @@
+// Package passpolicy decides which passwords users may choose.
+package passpolicy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrRejected is returned for passwords that fail the policy. It is always
+// wrapped in an *Error listing the failed requirements.
+var ErrRejected = errors.New("password does not meet the requirements")
+
+// Violation codes.
+const (
+	TooShort         = "too_short"
+	TooLong          = "too_long"
+	TooFewClasses    = "too_few_classes"
+	ContainsUsername = "contains_username"
+	Breached         = "breached"
+)
+
+// Violation is a requirement a password fails.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error rejects a password, listing every requirement it fails.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return ErrRejected.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *Error) Unwrap() error { return ErrRejected }
+
+// Policy is what a password must satisfy.
+type Policy struct {
+	// MinLength is the least number of characters.
+	MinLength int
+	// MaxLength is the most bytes, as bcrypt ignores anything past 72.
+	MaxLength int
+	// MinClasses is how many of lowercase letters, uppercase letters,
+	// digits and other characters a password must mix.
+	MinClasses int
+	// RejectUsername rejects passwords containing the username, forwards or
+	// backwards.
+	RejectUsername bool
+	// Breached, if set, rejects passwords known from data breaches.
+	Breached *Corpus
+}
+
+// Check returns an *Error if password, chosen by username, fails the
+// policy.
+func (p *Policy) Check(password, username string) error {
+	var violations []Violation
+	add := func(code, format string, args ...any) {
+		violations = append(violations, Violation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		add(TooShort, "must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add(TooLong, "must be at most %d bytes long", p.MaxLength)
+	}
+	if p.MinClasses > 1 && classes(password) < p.MinClasses {
+		add(TooFewClasses, "must mix at least %d of lowercase letters, uppercase letters, digits and other characters",
+			p.MinClasses)
+	}
+	if p.RejectUsername && containsUsername(password, username) {
+		add(ContainsUsername, "must not contain the username")
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		add(Breached, "has appeared in a data breach and must not be used")
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// classes counts the character classes password draws from.
+func classes(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// containsUsername reports whether password contains username, forwards or
+// backwards and ignoring case. Usernames shorter than three characters only
+// match the whole password.
+func containsUsername(password, username string) bool {
+	password, username = strings.ToLower(password), strings.ToLower(username)
+	if username == "" {
+		return false
+	}
+	if utf8.RuneCountInString(username) < 3 {
+		return password == username
+	}
+	return strings.Contains(password, username) || strings.Contains(password, reverse(username))
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
@@ -0,0 +1,185 @@
+package passpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sha1Hex returns the SHA-1 hash of password in upper case hex, the way the
+// Pwned Passwords downloads list it.
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeCorpus(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "corpus.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCorpus(t *testing.T) {
+	password := sha1Hex("password")
+	path := writeCorpus(t,
+		"# the most common passwords",
+		password+":9545824",
+		"",
+		strings.ToLower(sha1Hex("123456"))+":37359195",
+		sha1Hex("qwerty"),
+		password+":1",
+	)
+	c, err := LoadCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 3 {
+		t.Errorf("Len() = %d, want 3 without comments, blank lines and duplicates", c.Len())
+	}
+}
+
+func TestLoadCorpusInvalid(t *testing.T) {
+	for _, line := range []string{
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8A",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FDZ:1",
+	} {
+		if _, err := LoadCorpus(writeCorpus(t, line)); err == nil || !strings.Contains(err.Error(), ":1: not a SHA-1 hash") {
+			t.Errorf("LoadCorpus with %q: %v, want an error naming line 1", line, err)
+		}
+	}
+	if _, err := LoadCorpus(filepath.Join(t.TempDir(), "missing.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadCorpus of a missing file: %v, want ErrNotExist", err)
+	}
+}
+
+func TestCorpusRange(t *testing.T) {
+	// Hashes sharing the prefix of "password", and neighbours on either side.
+	const prefix = "5BAA6"
+	zeros, fs := strings.Repeat("0", 35), strings.Repeat("F", 35)
+	path := writeCorpus(t,
+		prefix+"1E4C9B93F3F0682250B6CF8331B7EE68FD8",
+		prefix+fs,
+		prefix+zeros,
+		"5BAA5"+fs,
+		"5BAA7"+zeros+":3",
+		"00000"+zeros,
+		"FFFFF"+fs,
+	)
+	c, err := LoadCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{name: "sorted suffixes", prefix: prefix, want: []string{zeros, "1E4C9B93F3F0682250B6CF8331B7EE68FD8", fs}},
+		{name: "lower case prefix", prefix: "5baa6", want: []string{zeros, "1E4C9B93F3F0682250B6CF8331B7EE68FD8", fs}},
+		{name: "first hash", prefix: "00000", want: []string{zeros}},
+		{name: "last hash", prefix: "FFFFF", want: []string{fs}},
+		{name: "no hashes", prefix: "5BAA8", want: nil},
+		{name: "too short", prefix: "5BAA", want: nil},
+		{name: "too long", prefix: "5BAA61", want: nil},
+		{name: "not hex", prefix: "5BAAG", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Range(tt.prefix); !slices.Equal(got, tt.want) {
+				t.Errorf("Range(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorpusContains(t *testing.T) {
+	c, err := LoadCorpus(writeCorpus(t, sha1Hex("password"), sha1Hex("123456")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"Password", false},
+		{"password1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := c.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	corpus, err := LoadCorpus(writeCorpus(t, sha1Hex("Summer2024!")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &Policy{MinLength: 8, MaxLength: 72, MinClasses: 3, RejectUsername: true, Breached: corpus}
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     []string
+	}{
+		{"acceptable", "Tr0ub4dor&3", "alice", nil},
+		{"too short", "Ab1!", "alice", []string{TooShort}},
+		{"length counts characters", "Äöü1Äöü1", "alice", nil},
+		{"too long", "Aa1" + strings.Repeat("x", 70), "alice", []string{TooLong}},
+		{"too few classes", "lowercase123", "alice", []string{TooFewClasses}},
+		{"non-letters count as other", "пароль123!", "alice", nil},
+		{"contains username", "xxAlice123!", "alice", []string{ContainsUsername}},
+		{"contains reversed username", "ecila-Pass1", "alice", []string{ContainsUsername}},
+		{"short username inside", "Password1!ab", "ab", nil},
+		{"short username whole", "Ab", "ab", []string{TooShort, TooFewClasses, ContainsUsername}},
+		{"breached", "Summer2024!", "alice", []string{Breached}},
+		{"every failure listed", "alice", "alice", []string{TooShort, TooFewClasses, ContainsUsername}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, tt.username)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			var perr *Error
+			if !errors.As(err, &perr) || !errors.Is(err, ErrRejected) {
+				t.Fatalf("Check() = %v, want an *Error wrapping ErrRejected", err)
+			}
+			var codes []string
+			for _, v := range perr.Violations {
+				codes = append(codes, v.Code)
+			}
+			if !slices.Equal(codes, tt.want) {
+				t.Errorf("Check() violations = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDisabled(t *testing.T) {
+	// The zero Policy has no requirements, so it accepts anything.
+	var policy Policy
+	for _, password := range []string{"", "a", "alice", strings.Repeat("x", 200)} {
+		if err := policy.Check(password, "alice"); err != nil {
+			t.Errorf("Check(%q) = %v, want nil", password, err)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+// Package recurrence parses and expands the subset of iCalendar recurrence
+// rules (RFC 5545 RRULE) supported for recurring todos.
+//
+// A rule has a FREQ of DAILY, WEEKLY, MONTHLY or YEARLY and may add
+// INTERVAL, BYDAY, BYMONTHDAY and either COUNT or UNTIL, for example
+// FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH or FREQ=MONTHLY;BYDAY=-1FR. Weeks start
+// on Monday. Other rule parts are rejected.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRule is wrapped by every error returned by Parse.
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Frequency is the base period a rule repeats at.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds how many periods are scanned for instances, so rules
+// that rarely or never match, like BYMONTHDAY=31 with BYDAY=MO, terminate.
+const maxPeriods = 10000
+
+// WeekdayNum is a BYDAY entry. A non-zero Ordinal selects the nth weekday of
+// the month or year, counting from the end when negative.
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	// Count is the total number of instances in the series, including the
+	// first one, or 0 for no limit.
+	Count int
+	// Until is the last moment an instance may fall on, or zero for no
+	// limit. When UntilDate is set, Until only carries a calendar date that
+	// is compared in the location of the instances.
+	Until     time.Time
+	UntilDate bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Parse parses a rule such as FREQ=DAILY;COUNT=5. An RRULE: prefix is
+// accepted and parts may appear in any order.
+func Parse(input string) (*Rule, error) {
+	input = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(input)), "RRULE:")
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(input, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(value)
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = positive(value)
+		case "COUNT":
+			r.Count, err = positive(value)
+		case "UNTIL":
+			r.Until, r.UntilDate, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseByMonthDay(value)
+		default:
+			err = fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	switch {
+	case r.Freq == "":
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	case seen["COUNT"] && seen["UNTIL"]:
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalidRule)
+	}
+	if r.Freq == Daily || r.Freq == Weekly {
+		for _, d := range r.ByDay {
+			if d.Ordinal != 0 {
+				return nil, fmt.Errorf("%w: numbered BYDAY is only allowed with MONTHLY or YEARLY", ErrInvalidRule)
+			}
+		}
+	}
+	return r, nil
+}
+
+func positive(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q is not a positive number", v)
+	}
+	return n, nil
+}
+
+func parseUntil(v string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102", v); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102T150405Z", v); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("UNTIL %q must be a date like 20261231 or a UTC time like 20261231T170000Z", v)
+}
+
+func parseByDay(v string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(v, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		wd, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		d := WeekdayNum{Weekday: wd}
+		if num := item[:len(item)-2]; num != "" {
+			n, err := strconv.Atoi(num)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+			d.Ordinal = n
+		}
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(v string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("invalid BYMONTHDAY %q", item)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// String renders the rule in canonical form, suitable for storage.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = strings.ToUpper(d.Weekday.String()[:2])
+			if d.Ordinal != 0 {
+				days[i] = strconv.Itoa(d.Ordinal) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.UntilDate {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// After returns up to n instances that follow start, earliest first. start
+// is taken as the series start for aligning intervals, and its location and
+// clock time carry over to every instance. UNTIL is applied but COUNT is
+// not, since only the caller knows how far into the series start is.
+func (r *Rule) After(start time.Time, n int) []time.Time {
+	var out []time.Time
+	for period := 0; period < maxPeriods && len(out) < n; period++ {
+		for _, t := range r.candidates(start, period*r.Interval) {
+			if !t.After(start) {
+				continue
+			}
+			if r.pastUntil(t) {
+				return out
+			}
+			out = append(out, t)
+			if len(out) == n {
+				return out
+			}
+		}
+	}
+	return out
+}
+
+func (r *Rule) pastUntil(t time.Time) bool {
+	if r.Until.IsZero() {
+		return false
+	}
+	if r.UntilDate {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).After(r.Until)
+	}
+	return t.After(r.Until)
+}
+
+// candidates returns the instances of the period that is offset periods
+// after the one containing start, in order.
+func (r *Rule) candidates(start time.Time, offset int) []time.Time {
+	y, m, d := start.Date()
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		days = []time.Time{date(y, m, d+offset)}
+	case Weekly:
+		monday := date(y, m, d-(int(start.Weekday())+6)%7+7*offset)
+		if len(r.ByDay) == 0 {
+			days = []time.Time{monday.AddDate(0, 0, (int(start.Weekday())+6)%7)}
+		}
+		for _, wd := range r.ByDay {
+			days = append(days, monday.AddDate(0, 0, (int(wd.Weekday)+6)%7))
+		}
+	case Monthly:
+		first := date(y, m+time.Month(offset), 1)
+		days = r.expandMonth(first, d)
+	case Yearly:
+		year := y + offset
+		switch {
+		case len(r.ByMonthDay) > 0:
+			for month := time.January; month <= time.December; month++ {
+				days = append(days, r.monthDays(date(year, month, 1))...)
+			}
+		case len(r.ByDay) > 0:
+			days = byDayIn(r.ByDay, date(year, time.January, 1), date(year+1, time.January, 1))
+		default:
+			if day := date(year, m, d); day.Month() == m {
+				days = []time.Time{day}
+			}
+		}
+	}
+
+	var out []time.Time
+	for _, day := range days {
+		if !r.matches(day) {
+			continue
+		}
+		dy, dm, dd := day.Date()
+		out = append(out, time.Date(dy, dm, dd, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location()))
+	}
+	slices.SortFunc(out, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(out, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// expandMonth returns the days of the month starting at first selected by
+// BYMONTHDAY, else by BYDAY, else the day of month of the series start.
+func (r *Rule) expandMonth(first time.Time, startDay int) []time.Time {
+	switch {
+	case len(r.ByMonthDay) > 0:
+		return r.monthDays(first)
+	case len(r.ByDay) > 0:
+		return byDayIn(r.ByDay, first, first.AddDate(0, 1, 0))
+	default:
+		if day := first.AddDate(0, 0, startDay-1); day.Month() == first.Month() {
+			return []time.Time{day}
+		}
+		return nil
+	}
+}
+
+// monthDays resolves BYMONTHDAY in the month starting at first, skipping
+// days the month does not have.
+func (r *Rule) monthDays(first time.Time) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+	var days []time.Time
+	for _, md := range r.ByMonthDay {
+		if md < 0 {
+			md = length + 1 + md
+		}
+		if md >= 1 && md <= length {
+			days = append(days, first.AddDate(0, 0, md-1))
+		}
+	}
+	return days
+}
+
+// byDayIn returns the days in [from, to) selected by BYDAY, resolving
+// ordinals within that span.
+func byDayIn(byDay []WeekdayNum, from, to time.Time) []time.Time {
+	var days []time.Time
+	for _, wd := range byDay {
+		var matching []time.Time
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == wd.Weekday {
+				matching = append(matching, day)
+			}
+		}
+		switch {
+		case wd.Ordinal == 0:
+			days = append(days, matching...)
+		case wd.Ordinal > 0 && wd.Ordinal <= len(matching):
+			days = append(days, matching[wd.Ordinal-1])
+		case wd.Ordinal < 0 && -wd.Ordinal <= len(matching):
+			days = append(days, matching[len(matching)+wd.Ordinal])
+		}
+	}
+	return days
+}
+
+// matches applies the BYDAY and BYMONTHDAY parts that limit, rather than
+// expand, the days of a period.
+func (r *Rule) matches(day time.Time) bool {
+	limitByDay := len(r.ByDay) > 0 && (r.Freq == Daily ||
+		(r.Freq != Weekly && len(r.ByMonthDay) > 0))
+	if limitByDay && !slices.ContainsFunc(r.ByDay, func(wd WeekdayNum) bool { return wd.Weekday == day.Weekday() }) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && (r.Freq == Daily || r.Freq == Weekly) {
+		days := r.monthDays(date(day.Year(), day.Month(), 1))
+		return slices.ContainsFunc(days, func(d time.Time) bool { return d.Equal(day) })
+	}
+	return true
+}
+
+// date returns midnight UTC of the given day, normalizing overflowing
+// months and days. Days are computed in UTC so DST never skews them.
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,185 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=weekly;interval=2;byday=mo,we;count=4", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=4"},
+		{"BYDAY=-1FR;FREQ=MONTHLY", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"FREQ=YEARLY;INTERVAL=1;UNTIL=20261231", "FREQ=YEARLY;UNTIL=20261231"},
+		{"FREQ=DAILY;UNTIL=20261231T170000Z", "FREQ=DAILY;UNTIL=20261231T170000Z"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=3;UNTIL=20261231",
+		"FREQ=DAILY;UNTIL=2026-12-31",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ=DAILY;COUNT",
+	} {
+		if _, err := Parse(input); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidRule", input, err)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("loading Europe/Berlin: %v", err)
+	}
+	utc := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		n     int
+		want  []time.Time
+	}{
+		{
+			name:  "daily interval",
+			rule:  "FREQ=DAILY;INTERVAL=3",
+			start: utc("2026-10-14T09:00:00Z"),
+			n:     3,
+			want:  []time.Time{utc("2026-10-17T09:00:00Z"), utc("2026-10-20T09:00:00Z"), utc("2026-10-23T09:00:00Z")},
+		},
+		{
+			name:  "every other week on two days",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			start: utc("2026-10-14T09:00:00Z"),
+			n:     4,
+			want: []time.Time{utc("2026-10-26T09:00:00Z"), utc("2026-10-28T09:00:00Z"),
+				utc("2026-11-09T09:00:00Z"), utc("2026-11-11T09:00:00Z")},
+		},
+		{
+			name:  "last friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: utc("2026-10-30T16:00:00Z"),
+			n:     3,
+			want:  []time.Time{utc("2026-11-27T16:00:00Z"), utc("2026-12-25T16:00:00Z"), utc("2027-01-29T16:00:00Z")},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: utc("2026-01-31T12:00:00Z"),
+			n:     3,
+			want:  []time.Time{utc("2026-03-31T12:00:00Z"), utc("2026-05-31T12:00:00Z"), utc("2026-07-31T12:00:00Z")},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: utc("2026-01-31T12:00:00Z"),
+			n:     3,
+			want:  []time.Time{utc("2026-02-28T12:00:00Z"), utc("2026-03-31T12:00:00Z"), utc("2026-04-30T12:00:00Z")},
+		},
+		{
+			name:  "leap day",
+			rule:  "FREQ=YEARLY",
+			start: utc("2024-02-29T08:00:00Z"),
+			n:     2,
+			want:  []time.Time{utc("2028-02-29T08:00:00Z"), utc("2032-02-29T08:00:00Z")},
+		},
+		{
+			name:  "first monday of the year",
+			rule:  "FREQ=YEARLY;BYDAY=1MO",
+			start: utc("2026-01-05T08:00:00Z"),
+			n:     2,
+			want:  []time.Time{utc("2027-01-04T08:00:00Z"), utc("2028-01-03T08:00:00Z")},
+		},
+		{
+			name:  "until date includes the whole day",
+			rule:  "FREQ=DAILY;UNTIL=20261016",
+			start: utc("2026-10-14T09:00:00Z"),
+			n:     10,
+			want:  []time.Time{utc("2026-10-15T09:00:00Z"), utc("2026-10-16T09:00:00Z")},
+		},
+		{
+			name:  "until time",
+			rule:  "FREQ=DAILY;UNTIL=20261016T080000Z",
+			start: utc("2026-10-14T09:00:00Z"),
+			n:     10,
+			want:  []time.Time{utc("2026-10-15T09:00:00Z")},
+		},
+		{
+			name:  "wall clock time survives daylight saving",
+			rule:  "FREQ=DAILY",
+			start: time.Date(2026, 10, 24, 9, 0, 0, 0, berlin),
+			n:     2,
+			want:  []time.Time{time.Date(2026, 10, 25, 9, 0, 0, 0, berlin), time.Date(2026, 10, 26, 9, 0, 0, 0, berlin)},
+		},
+		{
+			name:  "never matching rule terminates",
+			rule:  "FREQ=DAILY;INTERVAL=7;BYDAY=MO",
+			start: utc("2026-10-13T09:00:00Z"),
+			n:     1,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := rule.After(tt.start, tt.n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("After() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("instance %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAfterStopsAtMaxPeriods(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The first period holds only start itself, which is not after it.
+	if got := rule.After(start, maxPeriods+10); len(got) != maxPeriods-1 {
+		t.Errorf("After() returned %d instances, want %d", len(got), maxPeriods-1)
+	}
+}
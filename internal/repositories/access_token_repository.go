@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// AccessTokenRepository is the storage contract for personal access tokens.
+type AccessTokenRepository interface {
+	CreateAccessToken(ctx context.Context, token *models.PersonalAccessToken) error
+	FindAccessTokenByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error)
+	// ListAccessTokens returns the tokens of the user, newest first.
+	ListAccessTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error)
+	// TouchAccessToken records that the token was used at the given time.
+	TouchAccessToken(ctx context.Context, id int, at time.Time) error
+	DeleteAccessToken(ctx context.Context, id int) error
+}
+
+// accessTokenColumns is the column list scanned by the access token scan
+// helpers.
+const accessTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at`
+
+type pgAccessTokenRepository struct {
+	db *database.DB
+}
+
+func NewAccessTokenRepository(db *database.DB) AccessTokenRepository {
+	return &pgAccessTokenRepository{db: db}
+}
+
+func scanPGAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	t := &models.PersonalAccessToken{}
+	var scopes string
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return t, nil
+}
+
+func (r *pgAccessTokenRepository) CreateAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err := r.db.Pool.QueryRow(ctx, query, token.UserID, token.Name, token.TokenHash,
+		strings.Join(token.Scopes, " "), token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	return pgError(err)
+}
+
+func (r *pgAccessTokenRepository) FindAccessTokenByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE token_hash = $1`
+	t, err := scanPGAccessToken(r.db.Pool.QueryRow(ctx, query, hash))
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return t, nil
+}
+
+func (r *pgAccessTokenRepository) ListAccessTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*models.PersonalAccessToken
+	for rows.Next() {
+		t, err := scanPGAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *pgAccessTokenRepository) TouchAccessToken(ctx context.Context, id int, at time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE personal_access_tokens SET last_used_at = $1 WHERE id = $2`, at, id)
+	return err
+}
+
+func (r *pgAccessTokenRepository) DeleteAccessToken(ctx context.Context, id int) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM personal_access_tokens WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// DependencyRepository is the storage contract for "blocked by" links
+// between todos.
+type DependencyRepository interface {
+	// AddDependency records that todoID is blocked by blockerID; adding it
+	// twice is not an error.
+	AddDependency(ctx context.Context, todoID, blockerID int) error
+	RemoveDependency(ctx context.Context, todoID, blockerID int) error
+	// FindBlockers returns the todos that todoID is directly blocked by.
+	FindBlockers(ctx context.Context, todoID int) ([]*models.Todo, error)
+	// FindBlocking returns the todos directly blocked by todoID.
+	FindBlocking(ctx context.Context, todoID int) ([]*models.Todo, error)
+	// DependsOn reports whether todoID is blocked by blockerID, directly or
+	// through other todos.
+	DependsOn(ctx context.Context, todoID, blockerID int) (bool, error)
+}
+
+// dependsOnQuery follows blocker links from the first placeholder and checks
+// whether the second one is reached. UNION discards todos already visited.
+func dependsOnQuery(todoID, blockerID string) string {
+	return `
+		WITH RECURSIVE blockers (id) AS (
+			SELECT blocker_id FROM todo_dependencies WHERE todo_id = ` + todoID + `
+			UNION
+			SELECT d.blocker_id FROM todo_dependencies d JOIN blockers b ON d.todo_id = b.id
+		)
+		SELECT EXISTS (SELECT 1 FROM blockers WHERE id = ` + blockerID + `)
+	`
+}
+
+type pgDependencyRepository struct {
+	db *database.DB
+}
+
+func NewDependencyRepository(db *database.DB) DependencyRepository {
+	return &pgDependencyRepository{db: db}
+}
+
+func (r *pgDependencyRepository) AddDependency(ctx context.Context, todoID, blockerID int) error {
+	query := `
+		INSERT INTO todo_dependencies (todo_id, blocker_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := r.db.Pool.Exec(ctx, query, todoID, blockerID)
+	return err
+}
+
+func (r *pgDependencyRepository) RemoveDependency(ctx context.Context, todoID, blockerID int) error {
+	query := `DELETE FROM todo_dependencies WHERE todo_id = $1 AND blocker_id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, todoID, blockerID)
+	return err
+}
+
+func (r *pgDependencyRepository) FindBlockers(ctx context.Context, todoID int) ([]*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todo_dependencies d
+		JOIN todos t ON t.id = d.blocker_id
+		WHERE d.todo_id = $1
+		ORDER BY t.id
+	`
+	return (&pgTodoRepository{db: r.db}).queryTodos(ctx, query, todoID)
+}
+
+func (r *pgDependencyRepository) FindBlocking(ctx context.Context, todoID int) ([]*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todo_dependencies d
+		JOIN todos t ON t.id = d.todo_id
+		WHERE d.blocker_id = $1
+		ORDER BY t.id
+	`
+	return (&pgTodoRepository{db: r.db}).queryTodos(ctx, query, todoID)
+}
+
+func (r *pgDependencyRepository) DependsOn(ctx context.Context, todoID, blockerID int) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx, dependsOnQuery("$1", "$2"), todoID, blockerID).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// IdentityRepository is the storage contract for the OpenID Connect
+// identities linked to users.
+type IdentityRepository interface {
+	FindIdentity(ctx context.Context, issuer, subject string) (*models.UserIdentity, error)
+	// CreateIdentity links identity to its user, failing with ErrDuplicate
+	// if it is linked already.
+	CreateIdentity(ctx context.Context, identity *models.UserIdentity) error
+}
+
+type pgIdentityRepository struct {
+	db *database.DB
+}
+
+func NewIdentityRepository(db *database.DB) IdentityRepository {
+	return &pgIdentityRepository{db: db}
+}
+
+func (r *pgIdentityRepository) FindIdentity(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	query := `SELECT issuer, subject, user_id, created_at FROM user_identities WHERE issuer = $1 AND subject = $2`
+	i := &models.UserIdentity{}
+	err := r.db.Pool.QueryRow(ctx, query, issuer, subject).Scan(&i.Issuer, &i.Subject, &i.UserID, &i.CreatedAt)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return i, nil
+}
+
+func (r *pgIdentityRepository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (issuer, subject, user_id)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`
+	err := r.db.Pool.QueryRow(ctx, query, identity.Issuer, identity.Subject, identity.UserID).
+		Scan(&identity.CreatedAt)
+	return pgError(err)
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// LoginThrottleRepository is the storage contract for failed login counters
+// and the lockouts they lead to. Counters live in storage so that every
+// instance of the API sees the same ones.
+type LoginThrottleRepository interface {
+	FindLoginThrottle(ctx context.Context, kind, subject string) (*models.LoginThrottle, error)
+	// RecordLoginFailure counts a failed login at at against subject and
+	// returns the updated throttle. Counts whose last failure was before
+	// resetBefore start over.
+	RecordLoginFailure(ctx context.Context, kind, subject string, at, resetBefore time.Time) (*models.LoginThrottle, error)
+	// LockLogin refuses logins of subject until until.
+	LockLogin(ctx context.Context, kind, subject string, until time.Time) error
+	// DeleteLoginThrottle forgets the failures and lockout of subject.
+	DeleteLoginThrottle(ctx context.Context, kind, subject string) error
+	// DeleteStaleLoginThrottles forgets the throttles whose last failure was
+	// before before and whose lockout is over.
+	DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error
+	CreateLockoutEvent(ctx context.Context, event *models.LockoutEvent) error
+	// ListLockoutEvents returns the events matching filter, newest first.
+	ListLockoutEvents(ctx context.Context, filter models.LockoutFilter) ([]*models.LockoutEvent, error)
+}
+
+type pgLoginThrottleRepository struct {
+	db *database.DB
+}
+
+func NewLoginThrottleRepository(db *database.DB) LoginThrottleRepository {
+	return &pgLoginThrottleRepository{db: db}
+}
+
+func (r *pgLoginThrottleRepository) FindLoginThrottle(ctx context.Context, kind, subject string) (*models.LoginThrottle, error) {
+	t := &models.LoginThrottle{}
+	query := `
+		SELECT kind, subject, failures, last_failure_at, locked_until
+		FROM login_throttles WHERE kind = $1 AND subject = $2
+	`
+	err := r.db.Pool.QueryRow(ctx, query, kind, subject).
+		Scan(&t.Kind, &t.Subject, &t.Failures, &t.LastFailureAt, &t.LockedUntil)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return t, nil
+}
+
+func (r *pgLoginThrottleRepository) RecordLoginFailure(ctx context.Context, kind, subject string,
+	at, resetBefore time.Time) (*models.LoginThrottle, error) {
+	t := &models.LoginThrottle{}
+	query := `
+		INSERT INTO login_throttles (kind, subject, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (kind, subject) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < $4 THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING kind, subject, failures, last_failure_at, locked_until
+	`
+	err := r.db.Pool.QueryRow(ctx, query, kind, subject, at, resetBefore).
+		Scan(&t.Kind, &t.Subject, &t.Failures, &t.LastFailureAt, &t.LockedUntil)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return t, nil
+}
+
+func (r *pgLoginThrottleRepository) LockLogin(ctx context.Context, kind, subject string, until time.Time) error {
+	query := `UPDATE login_throttles SET locked_until = $1 WHERE kind = $2 AND subject = $3`
+	_, err := r.db.Pool.Exec(ctx, query, until, kind, subject)
+	return err
+}
+
+func (r *pgLoginThrottleRepository) DeleteLoginThrottle(ctx context.Context, kind, subject string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM login_throttles WHERE kind = $1 AND subject = $2`, kind, subject)
+	return err
+}
+
+func (r *pgLoginThrottleRepository) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+	`
+	_, err := r.db.Pool.Exec(ctx, query, before)
+	return err
+}
+
+func (r *pgLoginThrottleRepository) CreateLockoutEvent(ctx context.Context, event *models.LockoutEvent) error {
+	query := `
+		INSERT INTO lockout_events (kind, subject, ip, failures, locked_until)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	return r.db.Pool.QueryRow(ctx, query, event.Kind, event.Subject, event.IP, event.Failures, event.LockedUntil).
+		Scan(&event.ID, &event.CreatedAt)
+}
+
+func (r *pgLoginThrottleRepository) ListLockoutEvents(ctx context.Context, filter models.LockoutFilter) ([]*models.LockoutEvent, error) {
+	b := &queryBuilder{}
+	applyLockoutFilter(b, filter)
+	query := `SELECT id, kind, subject, ip, failures, locked_until, created_at FROM lockout_events` +
+		b.whereClause() + lockoutPage(b, filter)
+	rows, err := r.db.Pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.LockoutEvent
+	for rows.Next() {
+		e := &models.LockoutEvent{}
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Subject, &e.IP, &e.Failures, &e.LockedUntil, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,1377 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/search"
+)
+
+// memoryDB holds the shared state of the in-memory backend. A single lock
+// guards every table so cross-table operations stay consistent.
+type memoryDB struct {
+	mu sync.RWMutex
+
+	users      map[int]*models.User
+	nextUserID int
+
+	todos      map[int]*models.Todo
+	nextTodoID int
+
+	tags      map[int]*models.Tag
+	nextTagID int
+	// todoTags maps a todo ID to the set of tag IDs attached to it.
+	todoTags map[int]map[int]bool
+
+	projects      map[int]*models.Project
+	nextProjectID int
+
+	// blockers maps a todo ID to the set of todo IDs blocking it.
+	blockers map[int]map[int]bool
+
+	refreshTokens      map[int]*models.RefreshToken
+	nextRefreshTokenID int
+
+	// revocations is keyed by user ID and JTI.
+	revocations map[revocationKey]*models.TokenRevocation
+
+	accessTokens      map[int]*models.PersonalAccessToken
+	nextAccessTokenID int
+
+	totp map[int]*models.TOTPEnrollment
+	// recoveryCodes maps a user ID to the hashes of their unused recovery
+	// codes.
+	recoveryCodes map[int][]string
+	// mfaAttempts is keyed by the JTI of the MFA token.
+	mfaAttempts map[string]*models.MFATokenAttempts
+
+	// loginThrottles is keyed by kind and subject.
+	loginThrottles   map[throttleKey]*models.LoginThrottle
+	lockoutEvents    []*models.LockoutEvent
+	nextLockoutEvent int
+
+	roles map[string]*models.Role
+
+	// identities is keyed by issuer and subject.
+	identities map[identityKey]*models.UserIdentity
+}
+
+type identityKey struct {
+	issuer  string
+	subject string
+}
+
+type throttleKey struct {
+	kind    string
+	subject string
+}
+
+// sameEmail reports whether a and b are the same email address, as the
+// unique index on LOWER(email) sees it. Empty addresses are NULL there.
+func sameEmail(a, b string) bool {
+	return a != "" && strings.EqualFold(a, b)
+}
+
+type revocationKey struct {
+	userID    int
+	jti       string
+	sessionID string
+}
+
+// matchTodo applies filter to t, including the tag links and projects held
+// by db.
+func (db *memoryDB) matchTodo(t *models.Todo, filter models.TodoFilter) bool {
+	if filter.ProjectID == nil && !filter.IncludeArchived {
+		if p := db.projects[t.ProjectID]; p != nil && p.Archived {
+			return false
+		}
+	}
+	return matchTodoFilter(t, filter) && matchTags(db.todoTags[t.ID], filter)
+}
+
+// NewMemoryStore returns a Store that keeps everything in process memory.
+// It is safe for concurrent use and intended for tests and demos.
+func NewMemoryStore() *Store {
+	db := &memoryDB{
+		users:    make(map[int]*models.User),
+		todos:    make(map[int]*models.Todo),
+		tags:     make(map[int]*models.Tag),
+		todoTags: make(map[int]map[int]bool),
+		projects: make(map[int]*models.Project),
+		blockers: make(map[int]map[int]bool),
+
+		refreshTokens: make(map[int]*models.RefreshToken),
+		revocations:   make(map[revocationKey]*models.TokenRevocation),
+		accessTokens:  make(map[int]*models.PersonalAccessToken),
+		totp:          make(map[int]*models.TOTPEnrollment),
+		recoveryCodes: make(map[int][]string),
+		mfaAttempts:   make(map[string]*models.MFATokenAttempts),
+
+		loginThrottles: make(map[throttleKey]*models.LoginThrottle),
+		roles:          make(map[string]*models.Role),
+		identities:     make(map[identityKey]*models.UserIdentity),
+	}
+	// The built-in roles, as seeded by the migrations.
+	now := time.Now()
+	db.roles[models.RoleAdmin] = &models.Role{Name: models.RoleAdmin, Description: "Holds every permission", CreatedAt: now}
+	db.roles[models.RoleUser] = &models.Role{Name: models.RoleUser, Description: "Works with their own todos, tags and projects", CreatedAt: now}
+	return &Store{
+		Users:            &memoryUserRepository{db: db},
+		Todos:            &memoryTodoRepository{db: db},
+		Tags:             &memoryTagRepository{db: db},
+		Projects:         &memoryProjectRepository{db: db},
+		Dependencies:     &memoryDependencyRepository{db: db},
+		RefreshTokens:    &memoryRefreshTokenRepository{db: db},
+		TokenRevocations: &memoryTokenRevocationRepository{db: db},
+		AccessTokens:     &memoryAccessTokenRepository{db: db},
+		MFA:              &memoryMFARepository{db: db},
+		LoginThrottles:   &memoryLoginThrottleRepository{db: db},
+		Roles:            &memoryRoleRepository{db: db},
+		Identities:       &memoryIdentityRepository{db: db},
+	}
+}
+
+type memoryUserRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if u.Username == user.Username || sameEmail(u.Email, user.Email) {
+			return ErrDuplicate
+		}
+	}
+
+	r.db.nextUserID++
+	user.ID = r.db.nextUserID
+	user.CreatedAt = time.Now()
+	if user.Role == "" {
+		user.Role = "user"
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.Locale == "" {
+		user.Locale = "en"
+	}
+
+	stored := *user
+	r.db.users[user.ID] = &stored
+	return nil
+}
+
+func (r *memoryUserRepository) FindUserByID(ctx context.Context, id int) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	u, ok := r.db.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (r *memoryUserRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, u := range r.db.users {
+		if u.Username == username {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, u := range r.db.users {
+		if sameEmail(u.Email, email) {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var users []*models.User
+	for _, u := range r.db.users {
+		if filter.Query != "" && !strings.Contains(strings.ToLower(u.Username), strings.ToLower(filter.Query)) {
+			continue
+		}
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.Disabled != nil && u.Disabled != *filter.Disabled {
+			continue
+		}
+		user := *u
+		users = append(users, &user)
+	}
+	slices.SortFunc(users, func(a, b *models.User) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Username), strings.ToLower(b.Username)), cmp.Compare(a.ID, b.ID))
+	})
+	if filter.Limit > 0 {
+		users = users[min(filter.Offset, len(users)):]
+		users = users[:min(filter.Limit, len(users))]
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[user.ID]
+	if !ok {
+		return nil
+	}
+	for _, other := range r.db.users {
+		if other.ID != user.ID && (other.Username == user.Username || sameEmail(other.Email, user.Email)) {
+			return ErrDuplicate
+		}
+	}
+	u.Username = user.Username
+	u.Role = user.Role
+	u.DisplayName = user.DisplayName
+	u.Email = user.Email
+	u.EmailVerifiedAt = user.EmailVerifiedAt
+	u.Timezone = user.Timezone
+	u.Locale = user.Locale
+	u.Disabled = user.Disabled
+	return nil
+}
+
+func (r *memoryUserRepository) VerifyEmail(ctx context.Context, id int, email string, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[id]
+	if !ok || !sameEmail(u.Email, email) {
+		return false, nil
+	}
+	u.EmailVerifiedAt = &at
+	return true, nil
+}
+
+func (r *memoryUserRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[id]; ok {
+		u.Password = hash
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) DeleteUser(ctx context.Context, id int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// Everything the user owns goes too, as with ON DELETE CASCADE.
+	for todoID, t := range r.db.todos {
+		if t.UserID == id {
+			delete(r.db.todos, todoID)
+			delete(r.db.todoTags, todoID)
+			delete(r.db.blockers, todoID)
+		}
+	}
+	for todoID, ids := range r.db.blockers {
+		for blockerID := range ids {
+			if _, ok := r.db.todos[blockerID]; !ok {
+				delete(ids, blockerID)
+			}
+		}
+		if len(ids) == 0 {
+			delete(r.db.blockers, todoID)
+		}
+	}
+	for tagID, t := range r.db.tags {
+		if t.UserID == id {
+			delete(r.db.tags, tagID)
+		}
+	}
+	for projectID, p := range r.db.projects {
+		if p.UserID == id {
+			delete(r.db.projects, projectID)
+		}
+	}
+	for tokenID, t := range r.db.refreshTokens {
+		if t.UserID == id {
+			delete(r.db.refreshTokens, tokenID)
+		}
+	}
+	for key := range r.db.revocations {
+		if key.userID == id {
+			delete(r.db.revocations, key)
+		}
+	}
+	for tokenID, t := range r.db.accessTokens {
+		if t.UserID == id {
+			delete(r.db.accessTokens, tokenID)
+		}
+	}
+	for key, i := range r.db.identities {
+		if i.UserID == id {
+			delete(r.db.identities, key)
+		}
+	}
+	delete(r.db.totp, id)
+	delete(r.db.recoveryCodes, id)
+	delete(r.db.users, id)
+	return nil
+}
+
+type memoryTodoRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryTodoRepository) CreateTodo(ctx context.Context, todo *models.Todo) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.nextTodoID++
+	todo.ID = r.db.nextTodoID
+	todo.CreatedAt = time.Now()
+
+	stored := *todo
+	r.db.todos[todo.ID] = &stored
+	return nil
+}
+
+func (r *memoryTodoRepository) FindTodoByID(ctx context.Context, id int) (*models.Todo, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	t, ok := r.db.todos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	todo := *t
+	return &todo, nil
+}
+
+func (r *memoryTodoRepository) FindTodosByUserID(ctx context.Context, userID int) ([]*models.Todo, error) {
+	return r.ListTodos(ctx, models.TodoListOptions{Filter: models.TodoFilter{UserID: &userID}})
+}
+
+func (r *memoryTodoRepository) ListTodos(ctx context.Context, opts models.TodoListOptions) ([]*models.Todo, error) {
+	var after *models.Todo
+	if opts.After != nil {
+		var err error
+		if after, err = cursorTodo(opts.After); err != nil {
+			return nil, err
+		}
+	}
+	order := func(a, b *models.Todo) int {
+		if opts.Desc {
+			return compareTodos(b, a, opts.Sort)
+		}
+		return compareTodos(a, b, opts.Sort)
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var todos []*models.Todo
+	for _, t := range r.db.todos {
+		if !r.db.matchTodo(t, opts.Filter) {
+			continue
+		}
+		if after != nil && order(t, after) <= 0 {
+			continue
+		}
+		todo := *t
+		todos = append(todos, &todo)
+	}
+	slices.SortFunc(todos, order)
+	if opts.Limit > 0 && len(todos) > opts.Limit {
+		todos = todos[:opts.Limit]
+	}
+	return todos, nil
+}
+
+func (r *memoryTodoRepository) SearchTodos(ctx context.Context, query search.Query, filter models.TodoFilter, limit int) ([]*models.TodoSearchResult, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var results []*models.TodoSearchResult
+	for _, t := range r.db.todos {
+		if !r.db.matchTodo(t, filter) {
+			continue
+		}
+		// Every term has to match somewhere; title hits weigh more.
+		titleHits, descHits := query.Count(t.Title), query.Count(t.Description)
+		if query.Count(t.Title+" \n "+t.Description) == 0 {
+			continue
+		}
+		todo := *t
+		res := &models.TodoSearchResult{
+			Todo:           &todo,
+			Rank:           float64(4*titleHits + descHits + 1),
+			TitleHighlight: query.Highlight(t.Title),
+		}
+		if t.Description != "" {
+			res.Snippet = query.Highlight(t.Description)
+		}
+		results = append(results, res)
+	}
+	slices.SortFunc(results, func(a, b *models.TodoSearchResult) int {
+		if a.Rank != b.Rank {
+			return cmp.Compare(b.Rank, a.Rank)
+		}
+		return cmp.Compare(a.Todo.ID, b.Todo.ID)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (r *memoryTodoRepository) TodoStatsByUser(ctx context.Context) ([]*models.UserTodoStats, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	byUser := make(map[int]*models.UserTodoStats, len(r.db.users))
+	stats := make([]*models.UserTodoStats, 0, len(r.db.users))
+	for _, u := range r.db.users {
+		s := &models.UserTodoStats{UserID: u.ID, Username: u.Username}
+		byUser[u.ID] = s
+		stats = append(stats, s)
+	}
+	for _, t := range r.db.todos {
+		s, ok := byUser[t.UserID]
+		if !ok {
+			continue
+		}
+		s.Total++
+		if t.Completed {
+			s.Completed++
+		} else {
+			s.Open++
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].UserID < stats[j].UserID })
+	return stats, nil
+}
+
+func (r *memoryTodoRepository) FindTodoTree(ctx context.Context, id int) ([]*models.Todo, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var todos []*models.Todo
+	for _, t := range r.db.subtree(id) {
+		todo := *t
+		todos = append(todos, &todo)
+	}
+	slices.SortFunc(todos, func(a, b *models.Todo) int { return cmp.Compare(a.ID, b.ID) })
+	return todos, nil
+}
+
+// subtree returns the stored todo with the given ID and all of its
+// descendants, or nothing if it does not exist.
+func (db *memoryDB) subtree(id int) []*models.Todo {
+	root, ok := db.todos[id]
+	if !ok {
+		return nil
+	}
+	seen := map[int]bool{id: true}
+	tree := []*models.Todo{root}
+	for i := 0; i < len(tree); i++ {
+		for _, t := range db.todos {
+			if t.ParentID != nil && *t.ParentID == tree[i].ID && !seen[t.ID] {
+				seen[t.ID] = true
+				tree = append(tree, t)
+			}
+		}
+	}
+	return tree
+}
+
+func (r *memoryTodoRepository) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.todos[todo.ID]
+	if !ok {
+		return nil
+	}
+	t.Title = todo.Title
+	t.Description = todo.Description
+	t.Completed = todo.Completed
+	t.Priority = todo.Priority
+	t.StartAt = todo.StartAt
+	t.DueAt = todo.DueAt
+	t.CompletedAt = todo.CompletedAt
+	t.ProjectID = todo.ProjectID
+	t.ParentID = todo.ParentID
+	t.RollUp = todo.RollUp
+	t.Recurrence = todo.Recurrence
+	t.SeriesID = todo.SeriesID
+	t.Occurrence = todo.Occurrence
+	return nil
+}
+
+func (r *memoryTodoRepository) DeleteTodo(ctx context.Context, id int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// Subtasks go with their parent, as with ON DELETE CASCADE.
+	for _, t := range r.db.subtree(id) {
+		delete(r.db.todos, t.ID)
+		delete(r.db.todoTags, t.ID)
+		delete(r.db.blockers, t.ID)
+		for _, ids := range r.db.blockers {
+			delete(ids, t.ID)
+		}
+	}
+	return nil
+}
+
+type memoryTagRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryTagRepository) CreateTag(ctx context.Context, tag *models.Tag) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.nameTaken(tag) {
+		return ErrDuplicate
+	}
+	r.db.nextTagID++
+	tag.ID = r.db.nextTagID
+	tag.CreatedAt = time.Now()
+
+	stored := *tag
+	r.db.tags[tag.ID] = &stored
+	return nil
+}
+
+// nameTaken reports whether another tag of the same user has tag's name.
+func (r *memoryTagRepository) nameTaken(tag *models.Tag) bool {
+	for _, g := range r.db.tags {
+		if g.ID != tag.ID && g.UserID == tag.UserID && strings.EqualFold(g.Name, tag.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// usage counts the todos a tag is attached to.
+func (r *memoryTagRepository) usage(tagID int) int {
+	n := 0
+	for _, ids := range r.db.todoTags {
+		if ids[tagID] {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *memoryTagRepository) FindTagByID(ctx context.Context, id int) (*models.Tag, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	g, ok := r.db.tags[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	tag := *g
+	tag.UsageCount = r.usage(id)
+	return &tag, nil
+}
+
+func (r *memoryTagRepository) ListTagsByUserID(ctx context.Context, userID int) ([]*models.Tag, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var tags []*models.Tag
+	for _, g := range r.db.tags {
+		if g.UserID == userID {
+			tag := *g
+			tag.UsageCount = r.usage(g.ID)
+			tags = append(tags, &tag)
+		}
+	}
+	sortTags(tags)
+	return tags, nil
+}
+
+func (r *memoryTagRepository) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	g, ok := r.db.tags[tag.ID]
+	if !ok {
+		return nil
+	}
+	if r.nameTaken(&models.Tag{ID: g.ID, UserID: g.UserID, Name: tag.Name}) {
+		return ErrDuplicate
+	}
+	g.Name = tag.Name
+	g.Color = tag.Color
+	return nil
+}
+
+func (r *memoryTagRepository) DeleteTag(ctx context.Context, id int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.tags, id)
+	for _, ids := range r.db.todoTags {
+		delete(ids, id)
+	}
+	return nil
+}
+
+func (r *memoryTagRepository) AttachTag(ctx context.Context, todoID, tagID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.todos[todoID] == nil || r.db.tags[tagID] == nil {
+		return ErrNotFound
+	}
+	if r.db.todoTags[todoID] == nil {
+		r.db.todoTags[todoID] = make(map[int]bool)
+	}
+	r.db.todoTags[todoID][tagID] = true
+	return nil
+}
+
+func (r *memoryTagRepository) DetachTag(ctx context.Context, todoID, tagID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.todoTags[todoID], tagID)
+	return nil
+}
+
+func (r *memoryTagRepository) FindTagsByTodoIDs(ctx context.Context, todoIDs []int) (map[int][]*models.Tag, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	tags := make(map[int][]*models.Tag)
+	for _, todoID := range todoIDs {
+		for tagID := range r.db.todoTags[todoID] {
+			tag := *r.db.tags[tagID]
+			tags[todoID] = append(tags[todoID], &tag)
+		}
+		sortTags(tags[todoID])
+	}
+	return tags, nil
+}
+
+func sortTags(tags []*models.Tag) {
+	slices.SortFunc(tags, func(a, b *models.Tag) int {
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+type memoryProjectRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryProjectRepository) CreateProject(ctx context.Context, project *models.Project) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if project.Inbox {
+		for _, p := range r.db.projects {
+			if p.UserID == project.UserID && p.Inbox {
+				return ErrDuplicate
+			}
+		}
+	}
+	r.db.nextProjectID++
+	project.ID = r.db.nextProjectID
+	project.CreatedAt = time.Now()
+
+	stored := *project
+	r.db.projects[project.ID] = &stored
+	return nil
+}
+
+func (r *memoryProjectRepository) FindProjectByID(ctx context.Context, id int) (*models.Project, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	p, ok := r.db.projects[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	project := *p
+	return &project, nil
+}
+
+func (r *memoryProjectRepository) FindInbox(ctx context.Context, userID int) (*models.Project, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, p := range r.db.projects {
+		if p.UserID == userID && p.Inbox {
+			project := *p
+			return &project, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryProjectRepository) ListProjectsByUserID(ctx context.Context, userID int, includeArchived bool) ([]*models.Project, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var projects []*models.Project
+	for _, p := range r.db.projects {
+		if p.UserID == userID && (includeArchived || !p.Archived) {
+			project := *p
+			projects = append(projects, &project)
+		}
+	}
+	slices.SortFunc(projects, func(a, b *models.Project) int {
+		if a.Inbox != b.Inbox {
+			return cmpBool(b.Inbox, a.Inbox)
+		}
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return projects, nil
+}
+
+func (r *memoryProjectRepository) UpdateProject(ctx context.Context, project *models.Project) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.projects[project.ID]
+	if !ok {
+		return nil
+	}
+	p.Name = project.Name
+	p.Description = project.Description
+	p.Color = project.Color
+	p.Archived = project.Archived
+	return nil
+}
+
+func (r *memoryProjectRepository) DeleteProject(ctx context.Context, id, moveTo int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.todos {
+		if t.ProjectID == id {
+			t.ProjectID = moveTo
+		}
+	}
+	delete(r.db.projects, id)
+	return nil
+}
+
+type memoryDependencyRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryDependencyRepository) AddDependency(ctx context.Context, todoID, blockerID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.todos[todoID] == nil || r.db.todos[blockerID] == nil {
+		return ErrNotFound
+	}
+	if r.db.blockers[todoID] == nil {
+		r.db.blockers[todoID] = make(map[int]bool)
+	}
+	r.db.blockers[todoID][blockerID] = true
+	return nil
+}
+
+func (r *memoryDependencyRepository) RemoveDependency(ctx context.Context, todoID, blockerID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.blockers[todoID], blockerID)
+	return nil
+}
+
+func (r *memoryDependencyRepository) FindBlockers(ctx context.Context, todoID int) ([]*models.Todo, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var todos []*models.Todo
+	for id := range r.db.blockers[todoID] {
+		todo := *r.db.todos[id]
+		todos = append(todos, &todo)
+	}
+	slices.SortFunc(todos, func(a, b *models.Todo) int { return cmp.Compare(a.ID, b.ID) })
+	return todos, nil
+}
+
+func (r *memoryDependencyRepository) FindBlocking(ctx context.Context, todoID int) ([]*models.Todo, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var todos []*models.Todo
+	for id, ids := range r.db.blockers {
+		if ids[todoID] {
+			todo := *r.db.todos[id]
+			todos = append(todos, &todo)
+		}
+	}
+	slices.SortFunc(todos, func(a, b *models.Todo) int { return cmp.Compare(a.ID, b.ID) })
+	return todos, nil
+}
+
+func (r *memoryDependencyRepository) DependsOn(ctx context.Context, todoID, blockerID int) (bool, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	seen := map[int]bool{}
+	queue := []int{todoID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for b := range r.db.blockers[id] {
+			if b == blockerID {
+				return true, nil
+			}
+			if !seen[b] {
+				seen[b] = true
+				queue = append(queue, b)
+			}
+		}
+	}
+	return false, nil
+}
+
+type memoryRefreshTokenRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.refreshTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	r.db.nextRefreshTokenID++
+	token.ID = r.db.nextRefreshTokenID
+	token.CreatedAt = time.Now()
+
+	stored := *token
+	r.db.refreshTokens[token.ID] = &stored
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, t := range r.db.refreshTokens {
+		if t.TokenHash == hash {
+			token := *t
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRefreshTokenRepository) UseRefreshToken(ctx context.Context, id int, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.refreshTokens[id]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &at
+	return true, nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int, at time.Time) ([]string, error) {
+	// Family IDs are never empty, so keeping the empty family keeps none.
+	return r.RevokeOtherRefreshTokens(ctx, userID, "", at)
+}
+
+func (r *memoryRefreshTokenRepository) RevokeOtherRefreshTokens(ctx context.Context, userID int, keepFamilyID string, at time.Time) ([]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var families []string
+	for _, t := range r.db.refreshTokens {
+		if t.UserID == userID && t.FamilyID != keepFamilyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+			if !slices.Contains(families, t.FamilyID) {
+				families = append(families, t.FamilyID)
+			}
+		}
+	}
+	return families, nil
+}
+
+type memoryTokenRevocationRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryTokenRevocationRepository) SaveTokenRevocation(ctx context.Context, rev *models.TokenRevocation) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored := *rev
+	r.db.revocations[revocationKey{rev.UserID, rev.JTI, rev.SessionID}] = &stored
+	return nil
+}
+
+func (r *memoryTokenRevocationRepository) ListTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var revs []*models.TokenRevocation
+	for _, rev := range r.db.revocations {
+		if rev.ExpiresAt.After(now) {
+			stored := *rev
+			revs = append(revs, &stored)
+		}
+	}
+	return revs, nil
+}
+
+func (r *memoryTokenRevocationRepository) DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for key, rev := range r.db.revocations {
+		if !rev.ExpiresAt.After(now) {
+			delete(r.db.revocations, key)
+		}
+	}
+	return nil
+}
+
+type memoryAccessTokenRepository struct {
+	db *memoryDB
+}
+
+// copyAccessToken returns a copy of t that shares no memory with it.
+func copyAccessToken(t *models.PersonalAccessToken) *models.PersonalAccessToken {
+	c := *t
+	c.Scopes = append([]string{}, t.Scopes...)
+	return &c
+}
+
+func (r *memoryAccessTokenRepository) CreateAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.accessTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	r.db.nextAccessTokenID++
+	token.ID = r.db.nextAccessTokenID
+	token.CreatedAt = time.Now()
+	stored := copyAccessToken(token)
+	stored.Token = ""
+	r.db.accessTokens[token.ID] = stored
+	return nil
+}
+
+func (r *memoryAccessTokenRepository) FindAccessTokenByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, t := range r.db.accessTokens {
+		if t.TokenHash == hash {
+			return copyAccessToken(t), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAccessTokenRepository) ListAccessTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var tokens []*models.PersonalAccessToken
+	for _, t := range r.db.accessTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, copyAccessToken(t))
+		}
+	}
+	slices.SortFunc(tokens, func(a, b *models.PersonalAccessToken) int { return cmp.Compare(b.ID, a.ID) })
+	return tokens, nil
+}
+
+func (r *memoryAccessTokenRepository) TouchAccessToken(ctx context.Context, id int, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if t, ok := r.db.accessTokens[id]; ok {
+		t.LastUsedAt = &at
+	}
+	return nil
+}
+
+func (r *memoryAccessTokenRepository) DeleteAccessToken(ctx context.Context, id int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.accessTokens, id)
+	return nil
+}
+
+type memoryMFARepository struct {
+	db *memoryDB
+}
+
+func (r *memoryMFARepository) SaveTOTPEnrollment(ctx context.Context, e *models.TOTPEnrollment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if existing, ok := r.db.totp[e.UserID]; ok {
+		e.CreatedAt = existing.CreatedAt
+	} else {
+		e.CreatedAt = time.Now()
+	}
+	stored := *e
+	r.db.totp[e.UserID] = &stored
+	return nil
+}
+
+func (r *memoryMFARepository) FindTOTPEnrollment(ctx context.Context, userID int) (*models.TOTPEnrollment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	e, ok := r.db.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *e
+	return &found, nil
+}
+
+func (r *memoryMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e, ok := r.db.totp[userID]
+	if !ok || e.LastStep >= step {
+		return false, nil
+	}
+	e.LastStep = step
+	return true, nil
+}
+
+func (r *memoryMFARepository) DeleteMFA(ctx context.Context, userID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.totp, userID)
+	delete(r.db.recoveryCodes, userID)
+	return nil
+}
+
+func (r *memoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.recoveryCodes[userID] = slices.Clone(hashes)
+	return nil
+}
+
+func (r *memoryMFARepository) UseRecoveryCode(ctx context.Context, userID int, hash string, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	codes := r.db.recoveryCodes[userID]
+	i := slices.Index(codes, hash)
+	if i < 0 {
+		return false, nil
+	}
+	r.db.recoveryCodes[userID] = slices.Delete(codes, i, i+1)
+	return true, nil
+}
+
+func (r *memoryMFARepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return len(r.db.recoveryCodes[userID]), nil
+}
+
+func (r *memoryMFARepository) RecordMFAAttempt(ctx context.Context, jti string, expiresAt time.Time) (*models.MFATokenAttempts, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	a, ok := r.db.mfaAttempts[jti]
+	if !ok {
+		a = &models.MFATokenAttempts{JTI: jti, ExpiresAt: expiresAt}
+		r.db.mfaAttempts[jti] = a
+	}
+	a.Attempts++
+	stored := *a
+	return &stored, nil
+}
+
+func (r *memoryMFARepository) UseMFAToken(ctx context.Context, jti string, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	a, ok := r.db.mfaAttempts[jti]
+	if !ok || a.UsedAt != nil {
+		return false, nil
+	}
+	a.UsedAt = &at
+	return true, nil
+}
+
+func (r *memoryMFARepository) DeleteExpiredMFATokenAttempts(ctx context.Context, before time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for jti, a := range r.db.mfaAttempts {
+		if a.ExpiresAt.Before(before) {
+			delete(r.db.mfaAttempts, jti)
+		}
+	}
+	return nil
+}
+
+type memoryLoginThrottleRepository struct {
+	db *memoryDB
+}
+
+// copyThrottle returns a copy of t that shares no memory with it.
+func copyThrottle(t *models.LoginThrottle) *models.LoginThrottle {
+	c := *t
+	if t.LockedUntil != nil {
+		until := *t.LockedUntil
+		c.LockedUntil = &until
+	}
+	return &c
+}
+
+func (r *memoryLoginThrottleRepository) FindLoginThrottle(ctx context.Context, kind, subject string) (*models.LoginThrottle, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	t, ok := r.db.loginThrottles[throttleKey{kind, subject}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyThrottle(t), nil
+}
+
+func (r *memoryLoginThrottleRepository) RecordLoginFailure(ctx context.Context, kind, subject string,
+	at, resetBefore time.Time) (*models.LoginThrottle, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key := throttleKey{kind, subject}
+	t, ok := r.db.loginThrottles[key]
+	if !ok {
+		t = &models.LoginThrottle{Kind: kind, Subject: subject}
+		r.db.loginThrottles[key] = t
+	}
+	if t.LastFailureAt.Before(resetBefore) {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailureAt = at
+	return copyThrottle(t), nil
+}
+
+func (r *memoryLoginThrottleRepository) LockLogin(ctx context.Context, kind, subject string, until time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if t, ok := r.db.loginThrottles[throttleKey{kind, subject}]; ok {
+		t.LockedUntil = &until
+	}
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) DeleteLoginThrottle(ctx context.Context, kind, subject string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.loginThrottles, throttleKey{kind, subject})
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for key, t := range r.db.loginThrottles {
+		if t.LastFailureAt.Before(before) && (t.LockedUntil == nil || t.LockedUntil.Before(before)) {
+			delete(r.db.loginThrottles, key)
+		}
+	}
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) CreateLockoutEvent(ctx context.Context, event *models.LockoutEvent) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.nextLockoutEvent++
+	event.ID = r.db.nextLockoutEvent
+	event.CreatedAt = time.Now()
+	stored := *event
+	r.db.lockoutEvents = append(r.db.lockoutEvents, &stored)
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) ListLockoutEvents(ctx context.Context, filter models.LockoutFilter) ([]*models.LockoutEvent, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var events []*models.LockoutEvent
+	for i := len(r.db.lockoutEvents) - 1; i >= 0; i-- {
+		e := r.db.lockoutEvents[i]
+		if (filter.Kind == "" || e.Kind == filter.Kind) && (filter.Subject == "" || e.Subject == filter.Subject) {
+			found := *e
+			events = append(events, &found)
+		}
+	}
+	if filter.Limit > 0 {
+		events = events[min(filter.Offset, len(events)):]
+		events = events[:min(filter.Limit, len(events))]
+	}
+	return events, nil
+}
+
+type memoryRoleRepository struct {
+	db *memoryDB
+}
+
+// copyRole returns a copy of role that shares no memory with it.
+func copyRole(role *models.Role) *models.Role {
+	c := *role
+	c.Permissions = append([]string{}, role.Permissions...)
+	return &c
+}
+
+func (r *memoryRoleRepository) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	roles := make([]*models.Role, 0, len(r.db.roles))
+	for _, role := range r.db.roles {
+		roles = append(roles, copyRole(role))
+	}
+	slices.SortFunc(roles, func(a, b *models.Role) int { return cmp.Compare(a.Name, b.Name) })
+	return roles, nil
+}
+
+func (r *memoryRoleRepository) FindRole(ctx context.Context, name string) (*models.Role, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	role, ok := r.db.roles[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (r *memoryRoleRepository) SaveRole(ctx context.Context, role *models.Role) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if existing, ok := r.db.roles[role.Name]; ok {
+		role.CreatedAt = existing.CreatedAt
+	} else {
+		role.CreatedAt = time.Now()
+	}
+	r.db.roles[role.Name] = copyRole(role)
+	return nil
+}
+
+func (r *memoryRoleRepository) DeleteRole(ctx context.Context, name string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.roles, name)
+	return nil
+}
+
+func (r *memoryRoleRepository) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.roles[role]
+	return ok && slices.Contains(stored.Permissions, permission), nil
+}
+
+type memoryIdentityRepository struct {
+	db *memoryDB
+}
+
+func (r *memoryIdentityRepository) FindIdentity(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	i, ok := r.db.identities[identityKey{issuer: issuer, subject: subject}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *i
+	return &c, nil
+}
+
+func (r *memoryIdentityRepository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key := identityKey{issuer: identity.Issuer, subject: identity.Subject}
+	if _, ok := r.db.identities[key]; ok {
+		return ErrDuplicate
+	}
+	if _, ok := r.db.users[identity.UserID]; !ok {
+		return ErrNotFound
+	}
+	identity.CreatedAt = time.Now()
+	stored := *identity
+	r.db.identities[key] = &stored
+	return nil
+}
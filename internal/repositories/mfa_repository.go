@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// MFARepository is the storage contract for second factors: TOTP
+// enrollments and recovery codes.
+type MFARepository interface {
+	// SaveTOTPEnrollment creates or replaces the enrollment of the user.
+	SaveTOTPEnrollment(ctx context.Context, enrollment *models.TOTPEnrollment) error
+	FindTOTPEnrollment(ctx context.Context, userID int) (*models.TOTPEnrollment, error)
+	// UseTOTPStep records step as the user's last accepted time step. It
+	// reports false when step is not after the last one, so that a code is
+	// accepted at most once.
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	// DeleteMFA removes the user's enrollment and recovery codes.
+	DeleteMFA(ctx context.Context, userID int) error
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores the
+	// given hashes instead.
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	// UseRecoveryCode marks the user's unused code with the given hash as
+	// used. It reports false when there is no such code.
+	UseRecoveryCode(ctx context.Context, userID int, hash string, at time.Time) (bool, error)
+	// CountRecoveryCodes returns how many unused recovery codes the user has.
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+	// RecordMFAAttempt atomically counts one more code tried with the MFA
+	// token jti, expiring at expiresAt, and returns the updated attempts.
+	RecordMFAAttempt(ctx context.Context, jti string, expiresAt time.Time) (*models.MFATokenAttempts, error)
+	// UseMFAToken marks the MFA token jti as having completed a login. It
+	// reports false when it already had.
+	UseMFAToken(ctx context.Context, jti string, at time.Time) (bool, error)
+	// DeleteExpiredMFATokenAttempts deletes the attempts of MFA tokens that
+	// expired before the given time.
+	DeleteExpiredMFATokenAttempts(ctx context.Context, before time.Time) error
+}
+
+type pgMFARepository struct {
+	db *database.DB
+}
+
+func NewMFARepository(db *database.DB) MFARepository {
+	return &pgMFARepository{db: db}
+}
+
+func (r *pgMFARepository) SaveTOTPEnrollment(ctx context.Context, e *models.TOTPEnrollment) error {
+	query := `
+		INSERT INTO totp_enrollments (user_id, secret, enabled, last_step, enabled_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, enabled = EXCLUDED.enabled, last_step = EXCLUDED.last_step,
+			enabled_at = EXCLUDED.enabled_at
+		RETURNING created_at
+	`
+	err := r.db.Pool.QueryRow(ctx, query, e.UserID, e.Secret, e.Enabled, e.LastStep, e.EnabledAt).Scan(&e.CreatedAt)
+	return pgError(err)
+}
+
+func (r *pgMFARepository) FindTOTPEnrollment(ctx context.Context, userID int) (*models.TOTPEnrollment, error) {
+	query := `SELECT user_id, secret, enabled, last_step, created_at, enabled_at FROM totp_enrollments WHERE user_id = $1`
+	e := &models.TOTPEnrollment{}
+	err := r.db.Pool.QueryRow(ctx, query, userID).
+		Scan(&e.UserID, &e.Secret, &e.Enabled, &e.LastStep, &e.CreatedAt, &e.EnabledAt)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return e, nil
+}
+
+func (r *pgMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	query := `UPDATE totp_enrollments SET last_step = $1 WHERE user_id = $2 AND last_step < $1`
+	tag, err := r.db.Pool.Exec(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *pgMFARepository) DeleteMFA(ctx context.Context, userID int) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM totp_enrollments WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		_, err := tx.Exec(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgMFARepository) UseRecoveryCode(ctx context.Context, userID int, hash string, at time.Time) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = $1
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL
+	`
+	tag, err := r.db.Pool.Exec(ctx, query, at, userID, hash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *pgMFARepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	err := r.db.Pool.QueryRow(ctx, query, userID).Scan(&n)
+	return n, err
+}
+
+func (r *pgMFARepository) RecordMFAAttempt(ctx context.Context, jti string, expiresAt time.Time) (*models.MFATokenAttempts, error) {
+	query := `
+		INSERT INTO mfa_token_attempts (jti, attempts, expires_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (jti) DO UPDATE SET attempts = mfa_token_attempts.attempts + 1
+		RETURNING jti, attempts, used_at, expires_at
+	`
+	a := &models.MFATokenAttempts{}
+	err := r.db.Pool.QueryRow(ctx, query, jti, expiresAt).Scan(&a.JTI, &a.Attempts, &a.UsedAt, &a.ExpiresAt)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return a, nil
+}
+
+func (r *pgMFARepository) UseMFAToken(ctx context.Context, jti string, at time.Time) (bool, error) {
+	query := `UPDATE mfa_token_attempts SET used_at = $1 WHERE jti = $2 AND used_at IS NULL`
+	tag, err := r.db.Pool.Exec(ctx, query, at, jti)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *pgMFARepository) DeleteExpiredMFATokenAttempts(ctx context.Context, before time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM mfa_token_attempts WHERE expires_at < $1`, before)
+	return err
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import "github.com/globallstudent/todo-project-go/internal/database"
+
+// NewPostgresStore returns a Store whose repositories are backed by db.
+func NewPostgresStore(db *database.DB) *Store {
+	return &Store{
+		Users:            NewUserRepository(db),
+		Todos:            NewTodoRepository(db),
+		Tags:             NewTagRepository(db),
+		Projects:         NewProjectRepository(db),
+		Dependencies:     NewDependencyRepository(db),
+		RefreshTokens:    NewRefreshTokenRepository(db),
+		TokenRevocations: NewTokenRevocationRepository(db),
+		AccessTokens:     NewAccessTokenRepository(db),
+		MFA:              NewMFARepository(db),
+		LoginThrottles:   NewLoginThrottleRepository(db),
+		Roles:            NewRoleRepository(db),
+		Identities:       NewIdentityRepository(db),
+		close:            db.Close,
+	}
+}
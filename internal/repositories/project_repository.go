@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// ProjectRepository is the storage contract for projects.
+type ProjectRepository interface {
+	CreateProject(ctx context.Context, project *models.Project) error
+	FindProjectByID(ctx context.Context, id int) (*models.Project, error)
+	// FindInbox returns the user's Inbox project.
+	FindInbox(ctx context.Context, userID int) (*models.Project, error)
+	// ListProjectsByUserID returns the user's projects, Inbox first and the
+	// rest by name. Archived projects are only included when asked for.
+	ListProjectsByUserID(ctx context.Context, userID int, includeArchived bool) ([]*models.Project, error)
+	UpdateProject(ctx context.Context, project *models.Project) error
+	// DeleteProject moves the todos of the project to moveTo and deletes
+	// it, atomically.
+	DeleteProject(ctx context.Context, id, moveTo int) error
+}
+
+// projectColumns is the column list scanned by the project scan helpers.
+const projectColumns = `id, user_id, name, description, color, archived, inbox, created_at`
+
+type pgProjectRepository struct {
+	db *database.DB
+}
+
+func NewProjectRepository(db *database.DB) ProjectRepository {
+	return &pgProjectRepository{db: db}
+}
+
+func scanPGProject(row rowScanner) (*models.Project, error) {
+	p := &models.Project{}
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Description, &p.Color, &p.Archived, &p.Inbox, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *pgProjectRepository) CreateProject(ctx context.Context, project *models.Project) error {
+	query := `
+		INSERT INTO projects (user_id, name, description, color, archived, inbox)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := r.db.Pool.QueryRow(ctx, query, project.UserID, project.Name, project.Description,
+		project.Color, project.Archived, project.Inbox).
+		Scan(&project.ID, &project.CreatedAt)
+	return pgError(err)
+}
+
+func (r *pgProjectRepository) FindProjectByID(ctx context.Context, id int) (*models.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1`
+	project, err := scanPGProject(r.db.Pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return project, nil
+}
+
+func (r *pgProjectRepository) FindInbox(ctx context.Context, userID int) (*models.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE user_id = $1 AND inbox`
+	project, err := scanPGProject(r.db.Pool.QueryRow(ctx, query, userID))
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return project, nil
+}
+
+func (r *pgProjectRepository) ListProjectsByUserID(ctx context.Context, userID int, includeArchived bool) ([]*models.Project, error) {
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects
+		WHERE user_id = $1 AND ($2 OR NOT archived)
+		ORDER BY inbox DESC, LOWER(name), id
+	`
+	rows, err := r.db.Pool.Query(ctx, query, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []*models.Project
+	for rows.Next() {
+		project, err := scanPGProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+func (r *pgProjectRepository) UpdateProject(ctx context.Context, project *models.Project) error {
+	query := `
+		UPDATE projects
+		SET name = $1, description = $2, color = $3, archived = $4
+		WHERE id = $5
+	`
+	_, err := r.db.Pool.Exec(ctx, query, project.Name, project.Description, project.Color, project.Archived, project.ID)
+	return pgError(err)
+}
+
+func (r *pgProjectRepository) DeleteProject(ctx context.Context, id, moveTo int) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE todos SET project_id = $1 WHERE project_id = $2`, moveTo, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM projects WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,389 @@
+package repositories
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// rowScanner is satisfied by pgx and database/sql rows alike.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryBuilder accumulates WHERE conditions and their arguments for either
+// SQL dialect, so filters are written once for Postgres and SQLite.
+type queryBuilder struct {
+	sqlite bool
+	conds  []string
+	args   []any
+}
+
+// arg records v as a query argument and returns its placeholder.
+func (b *queryBuilder) arg(v any) string {
+	if t, ok := v.(time.Time); ok && b.sqlite {
+		v = sqliteTime(t)
+	}
+	b.args = append(b.args, v)
+	if b.sqlite {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// where adds a condition; conditions are joined with AND.
+func (b *queryBuilder) where(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+// ilike is the case-insensitive LIKE operator of the dialect.
+func (b *queryBuilder) ilike() string {
+	if b.sqlite {
+		return "LIKE"
+	}
+	return "ILIKE"
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// applyTodoFilter adds the conditions for f, referring to the todos table
+// as t.
+func applyTodoFilter(b *queryBuilder, f models.TodoFilter) {
+	if f.UserID != nil {
+		b.where("t.user_id = " + b.arg(*f.UserID))
+	}
+	if f.ProjectID != nil {
+		b.where("t.project_id = " + b.arg(*f.ProjectID))
+	} else if !f.IncludeArchived {
+		b.where("NOT EXISTS (SELECT 1 FROM projects p WHERE p.id = t.project_id AND p.archived)")
+	}
+	if f.ParentID != nil {
+		b.where("t.parent_id = " + b.arg(*f.ParentID))
+	}
+	if f.Completed != nil {
+		b.where("t.completed = " + b.arg(*f.Completed))
+	}
+	if f.CreatedAfter != nil {
+		b.where("t.created_at >= " + b.arg(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		b.where("t.created_at < " + b.arg(*f.CreatedBefore))
+	}
+	if f.View != nil {
+		applyTodoView(b, f.View)
+	}
+	if len(f.TagIDs) > 0 {
+		placeholders := make([]string, len(f.TagIDs))
+		for i, id := range f.TagIDs {
+			placeholders[i] = b.arg(id)
+		}
+		in := strings.Join(placeholders, ", ")
+		if f.AllTags {
+			b.where(fmt.Sprintf("(SELECT COUNT(DISTINCT tt.tag_id) FROM todo_tags tt WHERE tt.todo_id = t.id AND tt.tag_id IN (%s)) = %s",
+				in, b.arg(len(uniqueInts(f.TagIDs)))))
+		} else {
+			b.where(fmt.Sprintf("EXISTS (SELECT 1 FROM todo_tags tt WHERE tt.todo_id = t.id AND tt.tag_id IN (%s))", in))
+		}
+	}
+	if f.Query != "" {
+		pattern := "%" + escapeLike(f.Query) + "%"
+		b.where(fmt.Sprintf(`(t.title %s %s ESCAPE '\' OR t.description %s %s ESCAPE '\')`,
+			b.ilike(), b.arg(pattern), b.ilike(), b.arg(pattern)))
+	}
+}
+
+// applyUserFilter adds the conditions of f for the users table.
+func applyUserFilter(b *queryBuilder, f models.UserFilter) {
+	if f.Query != "" {
+		b.where(fmt.Sprintf(`username %s %s ESCAPE '\'`, b.ilike(), b.arg("%"+escapeLike(f.Query)+"%")))
+	}
+	if f.Role != "" {
+		b.where("role = " + b.arg(f.Role))
+	}
+	if f.Disabled != nil {
+		b.where("disabled = " + b.arg(*f.Disabled))
+	}
+}
+
+// userPage returns the ordering and paging of a user listing.
+func userPage(b *queryBuilder, f models.UserFilter) string {
+	suffix := " ORDER BY LOWER(username), id"
+	if f.Limit > 0 {
+		suffix += " LIMIT " + b.arg(f.Limit)
+		// SQLite only accepts OFFSET after LIMIT.
+		if f.Offset > 0 {
+			suffix += " OFFSET " + b.arg(f.Offset)
+		}
+	}
+	return suffix
+}
+
+func applyLockoutFilter(b *queryBuilder, f models.LockoutFilter) {
+	if f.Kind != "" {
+		b.where("kind = " + b.arg(f.Kind))
+	}
+	if f.Subject != "" {
+		b.where("subject = " + b.arg(f.Subject))
+	}
+}
+
+// lockoutPage returns the ordering and paging of a lockout event listing,
+// newest first.
+func lockoutPage(b *queryBuilder, f models.LockoutFilter) string {
+	suffix := " ORDER BY created_at DESC, id DESC"
+	if f.Limit > 0 {
+		suffix += " LIMIT " + b.arg(f.Limit)
+		if f.Offset > 0 {
+			suffix += " OFFSET " + b.arg(f.Offset)
+		}
+	}
+	return suffix
+}
+
+func applyTodoView(b *queryBuilder, v *models.TodoView) {
+	b.where("NOT t.completed")
+	switch v.Name {
+	case models.TodoViewToday:
+		start, end := b.arg(v.DayStart), b.arg(v.DayEnd)
+		start2, end2 := b.arg(v.DayStart), b.arg(v.DayEnd)
+		b.where(fmt.Sprintf("((t.due_at >= %s AND t.due_at < %s) OR (t.start_at >= %s AND t.start_at < %s))", start, end, start2, end2))
+	case models.TodoViewUpcoming:
+		b.where(fmt.Sprintf("(t.due_at >= %s OR (t.due_at IS NULL AND t.start_at >= %s))", b.arg(v.DayEnd), b.arg(v.DayEnd)))
+	case models.TodoViewOverdue:
+		b.where("t.due_at < " + b.arg(v.Now))
+	case models.TodoViewSomeday:
+		b.where("t.due_at IS NULL AND t.start_at IS NULL")
+	}
+}
+
+func uniqueInts(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var out []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// matchTags reports whether a todo carrying tagIDs passes the tag filter of f.
+func matchTags(tagIDs map[int]bool, f models.TodoFilter) bool {
+	if len(f.TagIDs) == 0 {
+		return true
+	}
+	for _, id := range f.TagIDs {
+		if tagIDs[id] && !f.AllTags {
+			return true
+		}
+		if !tagIDs[id] && f.AllTags {
+			return false
+		}
+	}
+	return f.AllTags
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// matchTodoFilter is the in-memory equivalent of applyTodoFilter, except
+// for the archived project check, which needs the project table.
+func matchTodoFilter(t *models.Todo, f models.TodoFilter) bool {
+	if f.UserID != nil && t.UserID != *f.UserID {
+		return false
+	}
+	if f.ProjectID != nil && t.ProjectID != *f.ProjectID {
+		return false
+	}
+	if f.ParentID != nil && (t.ParentID == nil || *t.ParentID != *f.ParentID) {
+		return false
+	}
+	if f.Completed != nil && t.Completed != *f.Completed {
+		return false
+	}
+	if f.CreatedAfter != nil && t.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !t.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.View != nil && !matchTodoView(t, f.View) {
+		return false
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(t.Title), q) && !strings.Contains(strings.ToLower(t.Description), q) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTodoView(t *models.Todo, v *models.TodoView) bool {
+	if t.Completed {
+		return false
+	}
+	within := func(at *time.Time) bool {
+		return at != nil && !at.Before(v.DayStart) && at.Before(v.DayEnd)
+	}
+	switch v.Name {
+	case models.TodoViewToday:
+		return within(t.DueAt) || within(t.StartAt)
+	case models.TodoViewUpcoming:
+		if t.DueAt != nil {
+			return !t.DueAt.Before(v.DayEnd)
+		}
+		return t.StartAt != nil && !t.StartAt.Before(v.DayEnd)
+	case models.TodoViewOverdue:
+		return t.DueAt != nil && t.DueAt.Before(v.Now)
+	case models.TodoViewSomeday:
+		return t.DueAt == nil && t.StartAt == nil
+	}
+	return true
+}
+
+// todoSortKey describes how a sortable field is ordered in SQL and how a
+// cursor value for it is turned back into a query argument.
+type todoSortKey struct {
+	expr       string
+	sqliteExpr string
+	parse      func(v string) (any, error)
+}
+
+func (k todoSortKey) sql(b *queryBuilder) string {
+	if b.sqlite && k.sqliteExpr != "" {
+		return k.sqliteExpr
+	}
+	return k.expr
+}
+
+var todoSortKeys = map[string]todoSortKey{
+	models.TodoSortCreatedAt: {
+		expr:  "t.created_at",
+		parse: func(v string) (any, error) { return time.Parse(time.RFC3339Nano, v) },
+	},
+	models.TodoSortTitle: {
+		expr:  "t.title",
+		parse: func(v string) (any, error) { return v, nil },
+	},
+	models.TodoSortCompleted: {
+		expr:  "t.completed",
+		parse: func(v string) (any, error) { return strconv.ParseBool(v) },
+	},
+	models.TodoSortPriority: {
+		expr:  "t.priority",
+		parse: func(v string) (any, error) { return strconv.Atoi(v) },
+	},
+	models.TodoSortDueAt: {
+		expr:       "COALESCE(t.due_at, TIMESTAMPTZ '9999-12-31 00:00:00+00')",
+		sqliteExpr: "COALESCE(t.due_at, '" + sqliteTime(models.NoDueDate) + "')",
+		parse:      func(v string) (any, error) { return time.Parse(time.RFC3339Nano, v) },
+	},
+}
+
+// applyTodoListOptions adds the filter and keyset conditions of opts and
+// returns the ORDER BY and LIMIT clauses to append to the query.
+func applyTodoListOptions(b *queryBuilder, opts models.TodoListOptions) (string, error) {
+	applyTodoFilter(b, opts.Filter)
+
+	key, ok := todoSortKeys[opts.Sort]
+	if !ok {
+		key = todoSortKeys[models.TodoSortCreatedAt]
+	}
+	dir, cmpOp := "ASC", ">"
+	if opts.Desc {
+		dir, cmpOp = "DESC", "<"
+	}
+
+	if opts.After != nil {
+		value, err := key.parse(opts.After.Value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		b.where(fmt.Sprintf("(%s, t.id) %s (%s, %s)", key.sql(b), cmpOp, b.arg(value), b.arg(opts.After.ID)))
+	}
+
+	suffix := fmt.Sprintf(" ORDER BY %s %s, t.id %s", key.sql(b), dir, dir)
+	if opts.Limit > 0 {
+		suffix += " LIMIT " + b.arg(opts.Limit)
+	}
+	return suffix, nil
+}
+
+// compareTodos orders a and b by the sort field and then by ID, mirroring
+// the ORDER BY built by applyTodoListOptions.
+func compareTodos(a, b *models.Todo, sort string) int {
+	var c int
+	switch sort {
+	case models.TodoSortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	case models.TodoSortCompleted:
+		c = cmpBool(a.Completed, b.Completed)
+	case models.TodoSortPriority:
+		c = cmp.Compare(a.Priority, b.Priority)
+	case models.TodoSortDueAt:
+		c = dueOrNever(a).Compare(dueOrNever(b))
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// cursorTodo rebuilds the sort key of the row a cursor points at, so the
+// in-memory backend can compare against it with compareTodos.
+func cursorTodo(cursor *models.TodoCursor) (*models.Todo, error) {
+	t := &models.Todo{ID: cursor.ID}
+	var err error
+	switch cursor.Sort {
+	case models.TodoSortTitle:
+		t.Title = cursor.Value
+	case models.TodoSortCompleted:
+		t.Completed, err = strconv.ParseBool(cursor.Value)
+	case models.TodoSortPriority:
+		t.Priority, err = strconv.Atoi(cursor.Value)
+	case models.TodoSortDueAt:
+		var due time.Time
+		if due, err = time.Parse(time.RFC3339Nano, cursor.Value); err == nil && !due.Equal(models.NoDueDate) {
+			t.DueAt = &due
+		}
+	default:
+		t.CreatedAt, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return t, nil
+}
+
+func dueOrNever(t *models.Todo) time.Time {
+	if t.DueAt == nil {
+		return models.NoDueDate
+	}
+	return *t.DueAt
+}
+
+func cmpBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
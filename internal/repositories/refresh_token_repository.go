@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// RefreshTokenRepository is the storage contract for refresh tokens.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// UseRefreshToken marks the token as used at the given time. It reports
+	// false when the token was already used, so that only one of several
+	// concurrent refreshes succeeds.
+	UseRefreshToken(ctx context.Context, id int, at time.Time) (bool, error)
+	// RevokeRefreshTokenFamily revokes every token of the family that is not
+	// revoked yet.
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error
+	// RevokeUserRefreshTokens revokes every token of the user that is not
+	// revoked yet, and returns the families it revoked tokens of.
+	RevokeUserRefreshTokens(ctx context.Context, userID int, at time.Time) ([]string, error)
+	// RevokeOtherRefreshTokens revokes every token of the user that is not
+	// revoked yet except those of the family keepFamilyID, and returns the
+	// families it revoked tokens of.
+	RevokeOtherRefreshTokens(ctx context.Context, userID int, keepFamilyID string, at time.Time) ([]string, error)
+}
+
+// refreshTokenColumns is the column list scanned by the refresh token scan
+// helpers.
+const refreshTokenColumns = `id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at`
+
+type pgRefreshTokenRepository struct {
+	db *database.DB
+}
+
+func NewRefreshTokenRepository(db *database.DB) RefreshTokenRepository {
+	return &pgRefreshTokenRepository{db: db}
+}
+
+func (r *pgRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err := r.db.Pool.QueryRow(ctx, query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	return pgError(err)
+}
+
+func (r *pgRefreshTokenRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+	t := &models.RefreshToken{}
+	err := r.db.Pool.QueryRow(ctx, query, hash).
+		Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.RevokedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return t, nil
+}
+
+func (r *pgRefreshTokenRepository) UseRefreshToken(ctx context.Context, id int, at time.Time) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+	tag, err := r.db.Pool.Exec(ctx, query, at, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *pgRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+	_, err := r.db.Pool.Exec(ctx, query, at, familyID)
+	return err
+}
+
+func (r *pgRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int, at time.Time) ([]string, error) {
+	// Family IDs are never empty, so keeping the empty family keeps none.
+	return r.RevokeOtherRefreshTokens(ctx, userID, "", at)
+}
+
+func (r *pgRefreshTokenRepository) RevokeOtherRefreshTokens(ctx context.Context, userID int, keepFamilyID string, at time.Time) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = $1
+		WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL
+		RETURNING family_id
+	`
+	rows, err := r.db.Pool.Query(ctx, query, at, userID, keepFamilyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var families []string
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(families, family) {
+			families = append(families, family)
+		}
+	}
+	return families, rows.Err()
+}
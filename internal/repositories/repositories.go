@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a record violates a uniqueness constraint.
+	ErrDuplicate = errors.New("record already exists")
+	// ErrInvalidCursor is returned when a pagination cursor cannot be used.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Store bundles the repositories backed by a single storage engine.
+type Store struct {
+	Users            UserRepository
+	Todos            TodoRepository
+	Tags             TagRepository
+	Projects         ProjectRepository
+	Dependencies     DependencyRepository
+	RefreshTokens    RefreshTokenRepository
+	TokenRevocations TokenRevocationRepository
+	AccessTokens     AccessTokenRepository
+	MFA              MFARepository
+	LoginThrottles   LoginThrottleRepository
+	Roles            RoleRepository
+	Identities       IdentityRepository
+
+	close func()
+}
+
+// Close releases the resources held by the underlying storage engine.
+func (s *Store) Close() {
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// pgError translates pgx errors into the repository sentinel errors.
+func pgError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
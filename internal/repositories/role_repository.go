@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// RoleRepository is the storage contract for roles and the permissions
+// granted to them.
+type RoleRepository interface {
+	// ListRoles returns every role with its permissions, ordered by name.
+	ListRoles(ctx context.Context) ([]*models.Role, error)
+	FindRole(ctx context.Context, name string) (*models.Role, error)
+	// SaveRole creates or updates role and replaces its permissions.
+	SaveRole(ctx context.Context, role *models.Role) error
+	DeleteRole(ctx context.Context, name string) error
+	// HasPermission reports whether role has been granted permission.
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+type pgRoleRepository struct {
+	db *database.DB
+}
+
+func NewRoleRepository(db *database.DB) RoleRepository {
+	return &pgRoleRepository{db: db}
+}
+
+func (r *pgRoleRepository) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT name, description, created_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	byName := make(map[string]*models.Role)
+	for rows.Next() {
+		role := &models.Role{Permissions: []string{}}
+		if err := rows.Scan(&role.Name, &role.Description, &role.CreatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+		byName[role.Name] = role
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	perms, err := r.db.Pool.Query(ctx, `SELECT role, permission FROM role_permissions ORDER BY role, permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer perms.Close()
+
+	for perms.Next() {
+		var name, permission string
+		if err := perms.Scan(&name, &permission); err != nil {
+			return nil, err
+		}
+		if role := byName[name]; role != nil {
+			role.Permissions = append(role.Permissions, permission)
+		}
+	}
+	return roles, perms.Err()
+}
+
+func (r *pgRoleRepository) FindRole(ctx context.Context, name string) (*models.Role, error) {
+	role := &models.Role{Permissions: []string{}}
+	err := r.db.Pool.QueryRow(ctx, `SELECT name, description, created_at FROM roles WHERE name = $1`, name).
+		Scan(&role.Name, &role.Description, &role.CreatedAt)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		role.Permissions = append(role.Permissions, permission)
+	}
+	return role, rows.Err()
+}
+
+func (r *pgRoleRepository) SaveRole(ctx context.Context, role *models.Role) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO roles (name, description)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description
+		RETURNING created_at
+	`
+	if err := tx.QueryRow(ctx, query, role.Name, role.Description).Scan(&role.CreatedAt); err != nil {
+		return pgError(err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM role_permissions WHERE role = $1`, role.Name); err != nil {
+		return err
+	}
+	for _, permission := range role.Permissions {
+		_, err := tx.Exec(ctx, `INSERT INTO role_permissions (role, permission) VALUES ($1, $2)`, role.Name, permission)
+		if err != nil {
+			return pgError(err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *pgRoleRepository) DeleteRole(ctx context.Context, name string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM roles WHERE name = $1`, name)
+	return err
+}
+
+func (r *pgRoleRepository) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	var granted bool
+	query := `SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = $1 AND permission = $2)`
+	err := r.db.Pool.QueryRow(ctx, query, role, permission).Scan(&granted)
+	return granted, err
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// NewSQLiteStore returns a Store whose repositories are backed by db.
+func NewSQLiteStore(db *database.SQLiteDB) *Store {
+	return &Store{
+		Users:            &sqliteUserRepository{db: db},
+		Todos:            &sqliteTodoRepository{db: db},
+		Tags:             &sqliteTagRepository{db: db},
+		Projects:         &sqliteProjectRepository{db: db},
+		Dependencies:     &sqliteDependencyRepository{db: db},
+		RefreshTokens:    &sqliteRefreshTokenRepository{db: db},
+		TokenRevocations: &sqliteTokenRevocationRepository{db: db},
+		AccessTokens:     &sqliteAccessTokenRepository{db: db},
+		MFA:              &sqliteMFARepository{db: db},
+		LoginThrottles:   &sqliteLoginThrottleRepository{db: db},
+		Roles:            &sqliteRoleRepository{db: db},
+		Identities:       &sqliteIdentityRepository{db: db},
+		close:            db.Close,
+	}
+}
+
+// sqliteTimeLayout is how timestamps are stored in SQLite. A fixed width UTC
+// layout keeps lexical and chronological order identical, which range
+// filters and ordering rely on.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// sqliteTime formats t for storage in SQLite.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// timeScanner scans a SQLite timestamp column into a time.Time. It accepts
+// both the stored text and values already parsed by the driver.
+type timeScanner struct {
+	t *time.Time
+}
+
+func (s timeScanner) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*s.t = v.UTC()
+		return nil
+	case string:
+		t, err := time.Parse(sqliteTimeLayout, v)
+		if err != nil {
+			return err
+		}
+		*s.t = t
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into time.Time", src)
+	}
+}
+
+// sqliteNullTime formats t for storage in SQLite, mapping nil to NULL.
+func sqliteNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// nullTimeScanner scans a nullable SQLite timestamp column into a *time.Time.
+type nullTimeScanner struct {
+	t **time.Time
+}
+
+func (s nullTimeScanner) Scan(src any) error {
+	if src == nil {
+		*s.t = nil
+		return nil
+	}
+	var t time.Time
+	if err := (timeScanner{&t}).Scan(src); err != nil {
+		return err
+	}
+	*s.t = &t
+	return nil
+}
+
+// sqliteError translates database/sql and SQLite errors into the repository
+// sentinel errors.
+func sqliteError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return ErrDuplicate
+		}
+	}
+	return err
+}
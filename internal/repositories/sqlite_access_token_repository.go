@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteAccessTokenRepository struct {
+	db *database.SQLiteDB
+}
+
+func scanSQLiteAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	t := &models.PersonalAccessToken{}
+	var scopes string
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &scopes,
+		nullTimeScanner{&t.ExpiresAt}, nullTimeScanner{&t.LastUsedAt}, timeScanner{&t.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return t, nil
+}
+
+func (r *sqliteAccessTokenRepository) CreateAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	createdAt := time.Now().UTC()
+	err := r.db.DB.QueryRowContext(ctx, query, token.UserID, token.Name, token.TokenHash,
+		strings.Join(token.Scopes, " "), sqliteNullTime(token.ExpiresAt), sqliteTime(createdAt)).
+		Scan(&token.ID)
+	if err != nil {
+		return sqliteError(err)
+	}
+	token.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqliteAccessTokenRepository) FindAccessTokenByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE token_hash = ?`
+	t, err := scanSQLiteAccessToken(r.db.DB.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return t, nil
+}
+
+func (r *sqliteAccessTokenRepository) ListAccessTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*models.PersonalAccessToken
+	for rows.Next() {
+		t, err := scanSQLiteAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *sqliteAccessTokenRepository) TouchAccessToken(ctx context.Context, id int, at time.Time) error {
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`, sqliteTime(at), id)
+	return err
+}
+
+func (r *sqliteAccessTokenRepository) DeleteAccessToken(ctx context.Context, id int) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE id = ?`, id)
+	return err
+}
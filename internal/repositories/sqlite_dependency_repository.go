@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteDependencyRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteDependencyRepository) AddDependency(ctx context.Context, todoID, blockerID int) error {
+	query := `INSERT OR IGNORE INTO todo_dependencies (todo_id, blocker_id) VALUES (?, ?)`
+	_, err := r.db.DB.ExecContext(ctx, query, todoID, blockerID)
+	return err
+}
+
+func (r *sqliteDependencyRepository) RemoveDependency(ctx context.Context, todoID, blockerID int) error {
+	query := `DELETE FROM todo_dependencies WHERE todo_id = ? AND blocker_id = ?`
+	_, err := r.db.DB.ExecContext(ctx, query, todoID, blockerID)
+	return err
+}
+
+func (r *sqliteDependencyRepository) FindBlockers(ctx context.Context, todoID int) ([]*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todo_dependencies d
+		JOIN todos t ON t.id = d.blocker_id
+		WHERE d.todo_id = ?
+		ORDER BY t.id
+	`
+	return (&sqliteTodoRepository{db: r.db}).queryTodos(ctx, query, todoID)
+}
+
+func (r *sqliteDependencyRepository) FindBlocking(ctx context.Context, todoID int) ([]*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todo_dependencies d
+		JOIN todos t ON t.id = d.todo_id
+		WHERE d.blocker_id = ?
+		ORDER BY t.id
+	`
+	return (&sqliteTodoRepository{db: r.db}).queryTodos(ctx, query, todoID)
+}
+
+func (r *sqliteDependencyRepository) DependsOn(ctx context.Context, todoID, blockerID int) (bool, error) {
+	var exists bool
+	err := r.db.DB.QueryRowContext(ctx, dependsOnQuery("?", "?"), todoID, blockerID).Scan(&exists)
+	return exists, err
+}
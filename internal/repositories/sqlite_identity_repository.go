@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteIdentityRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteIdentityRepository) FindIdentity(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	query := `SELECT issuer, subject, user_id, created_at FROM user_identities WHERE issuer = ? AND subject = ?`
+	i := &models.UserIdentity{}
+	err := r.db.DB.QueryRowContext(ctx, query, issuer, subject).
+		Scan(&i.Issuer, &i.Subject, &i.UserID, timeScanner{&i.CreatedAt})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return i, nil
+}
+
+func (r *sqliteIdentityRepository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	query := `INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES (?, ?, ?, ?)`
+	createdAt := time.Now().UTC()
+	_, err := r.db.DB.ExecContext(ctx, query, identity.Issuer, identity.Subject, identity.UserID, sqliteTime(createdAt))
+	if err != nil {
+		return sqliteError(err)
+	}
+	identity.CreatedAt = createdAt
+	return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteLoginThrottleRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteLoginThrottleRepository) FindLoginThrottle(ctx context.Context, kind, subject string) (*models.LoginThrottle, error) {
+	t := &models.LoginThrottle{}
+	query := `
+		SELECT kind, subject, failures, last_failure_at, locked_until
+		FROM login_throttles WHERE kind = ? AND subject = ?
+	`
+	err := r.db.DB.QueryRowContext(ctx, query, kind, subject).
+		Scan(&t.Kind, &t.Subject, &t.Failures, timeScanner{&t.LastFailureAt}, nullTimeScanner{&t.LockedUntil})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return t, nil
+}
+
+func (r *sqliteLoginThrottleRepository) RecordLoginFailure(ctx context.Context, kind, subject string,
+	at, resetBefore time.Time) (*models.LoginThrottle, error) {
+	t := &models.LoginThrottle{}
+	query := `
+		INSERT INTO login_throttles (kind, subject, failures, last_failure_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (kind, subject) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING kind, subject, failures, last_failure_at, locked_until
+	`
+	err := r.db.DB.QueryRowContext(ctx, query, kind, subject, sqliteTime(at), sqliteTime(resetBefore)).
+		Scan(&t.Kind, &t.Subject, &t.Failures, timeScanner{&t.LastFailureAt}, nullTimeScanner{&t.LockedUntil})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return t, nil
+}
+
+func (r *sqliteLoginThrottleRepository) LockLogin(ctx context.Context, kind, subject string, until time.Time) error {
+	query := `UPDATE login_throttles SET locked_until = ? WHERE kind = ? AND subject = ?`
+	_, err := r.db.DB.ExecContext(ctx, query, sqliteTime(until), kind, subject)
+	return err
+}
+
+func (r *sqliteLoginThrottleRepository) DeleteLoginThrottle(ctx context.Context, kind, subject string) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM login_throttles WHERE kind = ? AND subject = ?`, kind, subject)
+	return err
+}
+
+func (r *sqliteLoginThrottleRepository) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)
+	`
+	_, err := r.db.DB.ExecContext(ctx, query, sqliteTime(before), sqliteTime(before))
+	return err
+}
+
+func (r *sqliteLoginThrottleRepository) CreateLockoutEvent(ctx context.Context, event *models.LockoutEvent) error {
+	event.CreatedAt = time.Now()
+	query := `
+		INSERT INTO lockout_events (kind, subject, ip, failures, locked_until, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	return r.db.DB.QueryRowContext(ctx, query, event.Kind, event.Subject, event.IP, event.Failures,
+		sqliteTime(event.LockedUntil), sqliteTime(event.CreatedAt)).Scan(&event.ID)
+}
+
+func (r *sqliteLoginThrottleRepository) ListLockoutEvents(ctx context.Context, filter models.LockoutFilter) ([]*models.LockoutEvent, error) {
+	b := &queryBuilder{sqlite: true}
+	applyLockoutFilter(b, filter)
+	query := `SELECT id, kind, subject, ip, failures, locked_until, created_at FROM lockout_events` +
+		b.whereClause() + lockoutPage(b, filter)
+	rows, err := r.db.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.LockoutEvent
+	for rows.Next() {
+		e := &models.LockoutEvent{}
+		err := rows.Scan(&e.ID, &e.Kind, &e.Subject, &e.IP, &e.Failures, timeScanner{&e.LockedUntil}, timeScanner{&e.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
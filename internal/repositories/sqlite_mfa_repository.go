@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteMFARepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteMFARepository) SaveTOTPEnrollment(ctx context.Context, e *models.TOTPEnrollment) error {
+	query := `
+		INSERT INTO totp_enrollments (user_id, secret, enabled, last_step, created_at, enabled_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = excluded.secret, enabled = excluded.enabled, last_step = excluded.last_step,
+			enabled_at = excluded.enabled_at
+		RETURNING created_at
+	`
+	err := r.db.DB.QueryRowContext(ctx, query, e.UserID, e.Secret, e.Enabled, e.LastStep,
+		sqliteTime(time.Now()), sqliteNullTime(e.EnabledAt)).
+		Scan(timeScanner{&e.CreatedAt})
+	return sqliteError(err)
+}
+
+func (r *sqliteMFARepository) FindTOTPEnrollment(ctx context.Context, userID int) (*models.TOTPEnrollment, error) {
+	query := `SELECT user_id, secret, enabled, last_step, created_at, enabled_at FROM totp_enrollments WHERE user_id = ?`
+	e := &models.TOTPEnrollment{}
+	err := r.db.DB.QueryRowContext(ctx, query, userID).
+		Scan(&e.UserID, &e.Secret, &e.Enabled, &e.LastStep, timeScanner{&e.CreatedAt}, nullTimeScanner{&e.EnabledAt})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return e, nil
+}
+
+func (r *sqliteMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	query := `UPDATE totp_enrollments SET last_step = ? WHERE user_id = ? AND last_step < ?`
+	res, err := r.db.DB.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *sqliteMFARepository) DeleteMFA(ctx context.Context, userID int) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_enrollments WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqliteMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		_, err := tx.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqliteMFARepository) UseRecoveryCode(ctx context.Context, userID int, hash string, at time.Time) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = ?
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL
+	`
+	res, err := r.db.DB.ExecContext(ctx, query, sqliteTime(at), userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *sqliteMFARepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL`
+	err := r.db.DB.QueryRowContext(ctx, query, userID).Scan(&n)
+	return n, err
+}
+
+func (r *sqliteMFARepository) RecordMFAAttempt(ctx context.Context, jti string, expiresAt time.Time) (*models.MFATokenAttempts, error) {
+	query := `
+		INSERT INTO mfa_token_attempts (jti, attempts, expires_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (jti) DO UPDATE SET attempts = mfa_token_attempts.attempts + 1
+		RETURNING jti, attempts, used_at, expires_at
+	`
+	a := &models.MFATokenAttempts{}
+	err := r.db.DB.QueryRowContext(ctx, query, jti, sqliteTime(expiresAt)).
+		Scan(&a.JTI, &a.Attempts, nullTimeScanner{&a.UsedAt}, timeScanner{&a.ExpiresAt})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return a, nil
+}
+
+func (r *sqliteMFARepository) UseMFAToken(ctx context.Context, jti string, at time.Time) (bool, error) {
+	query := `UPDATE mfa_token_attempts SET used_at = ? WHERE jti = ? AND used_at IS NULL`
+	res, err := r.db.DB.ExecContext(ctx, query, sqliteTime(at), jti)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *sqliteMFARepository) DeleteExpiredMFATokenAttempts(ctx context.Context, before time.Time) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM mfa_token_attempts WHERE expires_at < ?`, sqliteTime(before))
+	return err
+}
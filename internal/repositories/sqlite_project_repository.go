@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteProjectRepository struct {
+	db *database.SQLiteDB
+}
+
+func scanSQLiteProject(row rowScanner) (*models.Project, error) {
+	p := &models.Project{}
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Description, &p.Color, &p.Archived, &p.Inbox, timeScanner{&p.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *sqliteProjectRepository) CreateProject(ctx context.Context, project *models.Project) error {
+	query := `
+		INSERT INTO projects (user_id, name, description, color, archived, inbox, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	createdAt := time.Now().UTC()
+	err := r.db.DB.QueryRowContext(ctx, query, project.UserID, project.Name, project.Description,
+		project.Color, project.Archived, project.Inbox, sqliteTime(createdAt)).
+		Scan(&project.ID)
+	if err != nil {
+		return sqliteError(err)
+	}
+	project.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqliteProjectRepository) FindProjectByID(ctx context.Context, id int) (*models.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = ?`
+	project, err := scanSQLiteProject(r.db.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return project, nil
+}
+
+func (r *sqliteProjectRepository) FindInbox(ctx context.Context, userID int) (*models.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE user_id = ? AND inbox`
+	project, err := scanSQLiteProject(r.db.DB.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return project, nil
+}
+
+func (r *sqliteProjectRepository) ListProjectsByUserID(ctx context.Context, userID int, includeArchived bool) ([]*models.Project, error) {
+	query := `
+		SELECT ` + projectColumns + `
+		FROM projects
+		WHERE user_id = ? AND (? OR NOT archived)
+		ORDER BY inbox DESC, name COLLATE NOCASE, id
+	`
+	rows, err := r.db.DB.QueryContext(ctx, query, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []*models.Project
+	for rows.Next() {
+		project, err := scanSQLiteProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+func (r *sqliteProjectRepository) UpdateProject(ctx context.Context, project *models.Project) error {
+	query := `
+		UPDATE projects
+		SET name = ?, description = ?, color = ?, archived = ?
+		WHERE id = ?
+	`
+	_, err := r.db.DB.ExecContext(ctx, query, project.Name, project.Description, project.Color, project.Archived, project.ID)
+	return sqliteError(err)
+}
+
+func (r *sqliteProjectRepository) DeleteProject(ctx context.Context, id, moveTo int) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE todos SET project_id = ? WHERE project_id = ?`, moveTo, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM projects WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
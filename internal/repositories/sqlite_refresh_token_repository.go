@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteRefreshTokenRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	createdAt := time.Now().UTC()
+	err := r.db.DB.QueryRowContext(ctx, query, token.UserID, token.FamilyID, token.TokenHash,
+		sqliteTime(token.ExpiresAt), sqliteTime(createdAt)).
+		Scan(&token.ID)
+	if err != nil {
+		return sqliteError(err)
+	}
+	token.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqliteRefreshTokenRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = ?`
+	t := &models.RefreshToken{}
+	err := r.db.DB.QueryRowContext(ctx, query, hash).
+		Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, timeScanner{&t.ExpiresAt},
+			nullTimeScanner{&t.UsedAt}, nullTimeScanner{&t.RevokedAt}, timeScanner{&t.CreatedAt})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return t, nil
+}
+
+func (r *sqliteRefreshTokenRepository) UseRefreshToken(ctx context.Context, id int, at time.Time) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`
+	res, err := r.db.DB.ExecContext(ctx, query, sqliteTime(at), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *sqliteRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	query := `UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`
+	_, err := r.db.DB.ExecContext(ctx, query, sqliteTime(at), familyID)
+	return err
+}
+
+func (r *sqliteRefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int, at time.Time) ([]string, error) {
+	// Family IDs are never empty, so keeping the empty family keeps none.
+	return r.RevokeOtherRefreshTokens(ctx, userID, "", at)
+}
+
+func (r *sqliteRefreshTokenRepository) RevokeOtherRefreshTokens(ctx context.Context, userID int, keepFamilyID string, at time.Time) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE user_id = ? AND family_id <> ? AND revoked_at IS NULL
+		RETURNING family_id
+	`
+	rows, err := r.db.DB.QueryContext(ctx, query, sqliteTime(at), userID, keepFamilyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var families []string
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(families, family) {
+			families = append(families, family)
+		}
+	}
+	return families, rows.Err()
+}
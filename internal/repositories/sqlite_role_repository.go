@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteRoleRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteRoleRepository) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT name, description, created_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []*models.Role
+	byName := make(map[string]*models.Role)
+	for rows.Next() {
+		role := &models.Role{Permissions: []string{}}
+		if err := rows.Scan(&role.Name, &role.Description, timeScanner{&role.CreatedAt}); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		roles = append(roles, role)
+		byName[role.Name] = role
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	perms, err := r.db.DB.QueryContext(ctx, `SELECT role, permission FROM role_permissions ORDER BY role, permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer perms.Close()
+
+	for perms.Next() {
+		var name, permission string
+		if err := perms.Scan(&name, &permission); err != nil {
+			return nil, err
+		}
+		if role := byName[name]; role != nil {
+			role.Permissions = append(role.Permissions, permission)
+		}
+	}
+	return roles, perms.Err()
+}
+
+func (r *sqliteRoleRepository) FindRole(ctx context.Context, name string) (*models.Role, error) {
+	role := &models.Role{Permissions: []string{}}
+	err := r.db.DB.QueryRowContext(ctx, `SELECT name, description, created_at FROM roles WHERE name = ?`, name).
+		Scan(&role.Name, &role.Description, timeScanner{&role.CreatedAt})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		role.Permissions = append(role.Permissions, permission)
+	}
+	return role, rows.Err()
+}
+
+func (r *sqliteRoleRepository) SaveRole(ctx context.Context, role *models.Role) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO roles (name, description, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET description = excluded.description
+		RETURNING created_at
+	`
+	err = tx.QueryRowContext(ctx, query, role.Name, role.Description, sqliteTime(time.Now())).
+		Scan(timeScanner{&role.CreatedAt})
+	if err != nil {
+		return sqliteError(err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role = ?`, role.Name); err != nil {
+		return err
+	}
+	for _, permission := range role.Permissions {
+		_, err := tx.ExecContext(ctx, `INSERT INTO role_permissions (role, permission) VALUES (?, ?)`, role.Name, permission)
+		if err != nil {
+			return sqliteError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sqliteRoleRepository) DeleteRole(ctx context.Context, name string) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM roles WHERE name = ?`, name)
+	return err
+}
+
+func (r *sqliteRoleRepository) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	var granted bool
+	query := `SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = ? AND permission = ?)`
+	err := r.db.DB.QueryRowContext(ctx, query, role, permission).Scan(&granted)
+	return granted, err
+}
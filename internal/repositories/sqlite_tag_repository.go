@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteTagRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteTagRepository) CreateTag(ctx context.Context, tag *models.Tag) error {
+	query := `
+		INSERT INTO tags (user_id, name, color, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`
+	createdAt := time.Now().UTC()
+	err := r.db.DB.QueryRowContext(ctx, query, tag.UserID, tag.Name, tag.Color, sqliteTime(createdAt)).
+		Scan(&tag.ID)
+	if err != nil {
+		return sqliteError(err)
+	}
+	tag.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqliteTagRepository) FindTagByID(ctx context.Context, id int) (*models.Tag, error) {
+	tag := &models.Tag{}
+	query := `
+		SELECT g.id, g.user_id, g.name, g.color, g.created_at,
+			(SELECT COUNT(*) FROM todo_tags tt WHERE tt.tag_id = g.id)
+		FROM tags g
+		WHERE g.id = ?
+	`
+	err := r.db.DB.QueryRowContext(ctx, query, id).
+		Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, timeScanner{&tag.CreatedAt}, &tag.UsageCount)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return tag, nil
+}
+
+func (r *sqliteTagRepository) ListTagsByUserID(ctx context.Context, userID int) ([]*models.Tag, error) {
+	query := `
+		SELECT g.id, g.user_id, g.name, g.color, g.created_at, COUNT(tt.todo_id)
+		FROM tags g
+		LEFT JOIN todo_tags tt ON tt.tag_id = g.id
+		WHERE g.user_id = ?
+		GROUP BY g.id
+		ORDER BY g.name COLLATE NOCASE, g.id
+	`
+	rows, err := r.db.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, timeScanner{&tag.CreatedAt}, &tag.UsageCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (r *sqliteTagRepository) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	query := `UPDATE tags SET name = ?, color = ? WHERE id = ?`
+	_, err := r.db.DB.ExecContext(ctx, query, tag.Name, tag.Color, tag.ID)
+	return sqliteError(err)
+}
+
+func (r *sqliteTagRepository) DeleteTag(ctx context.Context, id int) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, id)
+	return err
+}
+
+func (r *sqliteTagRepository) AttachTag(ctx context.Context, todoID, tagID int) error {
+	query := `INSERT OR IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)`
+	_, err := r.db.DB.ExecContext(ctx, query, todoID, tagID)
+	return err
+}
+
+func (r *sqliteTagRepository) DetachTag(ctx context.Context, todoID, tagID int) error {
+	query := `DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?`
+	_, err := r.db.DB.ExecContext(ctx, query, todoID, tagID)
+	return err
+}
+
+func (r *sqliteTagRepository) FindTagsByTodoIDs(ctx context.Context, todoIDs []int) (map[int][]*models.Tag, error) {
+	tags := make(map[int][]*models.Tag)
+	if len(todoIDs) == 0 {
+		return tags, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(todoIDs)), ", ")
+	args := make([]any, len(todoIDs))
+	for i, id := range todoIDs {
+		args[i] = id
+	}
+	query := `
+		SELECT tt.todo_id, g.id, g.user_id, g.name, g.color, g.created_at
+		FROM todo_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.todo_id IN (` + placeholders + `)
+		ORDER BY g.name COLLATE NOCASE, g.id
+	`
+	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		tag := &models.Tag{}
+		if err := rows.Scan(&todoID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color, timeScanner{&tag.CreatedAt}); err != nil {
+			return nil, err
+		}
+		tags[todoID] = append(tags[todoID], tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,558 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/migrate"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/migrations"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestSQLiteStore returns a Store on a fresh, fully migrated SQLite
+// database file.
+func newTestSQLiteStore(t *testing.T) *Store {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.NewSQLiteDB(database.SQLiteScheme + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver, err := migrate.NewSQLiteDriver(ctx, db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(driver, migrations.SQLite)
+	if err == nil {
+		err = m.Up(ctx)
+	}
+	// The driver pins the only connection, so it has to go before the
+	// repositories can use the database.
+	driver.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewSQLiteStore(db)
+	t.Cleanup(store.Close)
+	return store
+}
+
+// eachStore runs test against the SQLite store and, so that both backends
+// keep behaving alike, against the memory store.
+func eachStore(t *testing.T, test func(t *testing.T, store *Store)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, newTestSQLiteStore(t))
+	})
+	t.Run("memory", func(t *testing.T) {
+		store := NewMemoryStore()
+		t.Cleanup(store.Close)
+		test(t, store)
+	})
+}
+
+// createTestUser creates a user with an Inbox project.
+func createTestUser(t *testing.T, store *Store, username string) (*models.User, *models.Project) {
+	t.Helper()
+	ctx := context.Background()
+	user := &models.User{Username: username, Password: "hash", Role: models.RoleUser}
+	if err := store.Users.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	inbox := &models.Project{UserID: user.ID, Name: models.InboxProjectName, Inbox: true}
+	if err := store.Projects.CreateProject(ctx, inbox); err != nil {
+		t.Fatal(err)
+	}
+	return user, inbox
+}
+
+func createTestTodo(t *testing.T, store *Store, todo *models.Todo) *models.Todo {
+	t.Helper()
+	if err := store.Todos.CreateTodo(context.Background(), todo); err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+func todoIDs(todos []*models.Todo) []int {
+	ids := make([]int, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+func TestUsers(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, _ := createTestUser(t, store, "alice")
+		createTestUser(t, store, "bob")
+		if alice.Timezone != "UTC" || alice.Locale != "en" {
+			t.Errorf("CreateUser() defaults = %q, %q, want UTC, en", alice.Timezone, alice.Locale)
+		}
+		if err := store.Users.CreateUser(ctx, &models.User{Username: "alice", Password: "hash"}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("CreateUser(alice) again = %v, want ErrDuplicate", err)
+		}
+
+		alice.Email = "Alice@Example.com"
+		alice.DisplayName = "Alice"
+		alice.Password = "stale"
+		if err := store.Users.UpdateUser(ctx, alice); err != nil {
+			t.Fatal(err)
+		}
+		found, err := store.Users.FindUserByEmail(ctx, "alice@example.COM")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.ID != alice.ID || found.Email != "Alice@Example.com" || found.DisplayName != "Alice" {
+			t.Errorf("FindUserByEmail() = %+v", found)
+		}
+		if found.Password != "hash" {
+			t.Errorf("UpdateUser() changed the password to %q", found.Password)
+		}
+		bob, err := store.Users.FindUserByUsername(ctx, "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bob.Email = "ALICE@example.com"
+		if err := store.Users.UpdateUser(ctx, bob); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("UpdateUser() with another user's email = %v, want ErrDuplicate", err)
+		}
+
+		if err := store.Users.UpdatePassword(ctx, alice.ID, "new-hash"); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := store.Users.VerifyEmail(ctx, alice.ID, "old@example.com", time.Now()); err != nil || ok {
+			t.Errorf("VerifyEmail() of a replaced address = %v, %v, want false", ok, err)
+		}
+		if ok, err := store.Users.VerifyEmail(ctx, alice.ID, "alice@example.com", time.Now()); err != nil || !ok {
+			t.Errorf("VerifyEmail() = %v, %v, want true", ok, err)
+		}
+		found, err = store.Users.FindUserByID(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Password != "new-hash" || found.EmailVerifiedAt == nil {
+			t.Errorf("FindUserByID() = %+v, want the new password and a verified email", found)
+		}
+
+		users, err := store.Users.ListUsers(ctx, models.UserFilter{Query: "LI"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 1 || users[0].Username != "alice" {
+			t.Errorf("ListUsers(LI) = %v, want alice", users)
+		}
+
+		createTestTodo(t, store, &models.Todo{Title: "Todo", UserID: alice.ID, ProjectID: mustInbox(t, store, alice.ID)})
+		if err := store.Users.DeleteUser(ctx, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Users.FindUserByID(ctx, alice.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("FindUserByID() after DeleteUser = %v, want ErrNotFound", err)
+		}
+		if todos, err := store.Todos.FindTodosByUserID(ctx, alice.ID); err != nil || len(todos) != 0 {
+			t.Errorf("FindTodosByUserID() after DeleteUser = %v, %v, want none", todos, err)
+		}
+	})
+}
+
+func mustInbox(t *testing.T, store *Store, userID int) int {
+	t.Helper()
+	inbox, err := store.Projects.FindInbox(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inbox.ID
+}
+
+func TestListTodosPagination(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, inbox := createTestUser(t, store, "alice")
+		bob, bobInbox := createTestUser(t, store, "bob")
+		due := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+		for i, priority := range []int{2, 0, 3, 2, 1, 2} {
+			todo := &models.Todo{Title: string(rune('f' - i)), Priority: priority, UserID: alice.ID, ProjectID: inbox.ID}
+			if i%2 == 0 {
+				d := due.AddDate(0, 0, -i)
+				todo.DueAt = &d
+			}
+			createTestTodo(t, store, todo)
+		}
+		createTestTodo(t, store, &models.Todo{Title: "bob's", UserID: bob.ID, ProjectID: bobInbox.ID})
+
+		for _, sort := range []string{models.TodoSortCreatedAt, models.TodoSortTitle, models.TodoSortPriority, models.TodoSortDueAt} {
+			for _, desc := range []bool{false, true} {
+				filter := models.TodoFilter{UserID: &alice.ID}
+				all, err := store.Todos.ListTodos(ctx, models.TodoListOptions{Filter: filter, Sort: sort, Desc: desc})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(all) != 6 {
+					t.Fatalf("ListTodos(%s) = %d todos, want 6", sort, len(all))
+				}
+				var paged []*models.Todo
+				var after *models.TodoCursor
+				for range len(all) {
+					page, err := store.Todos.ListTodos(ctx, models.TodoListOptions{
+						Filter: filter, Sort: sort, Desc: desc, Limit: 4, After: after,
+					})
+					if err != nil {
+						t.Fatal(err)
+					}
+					paged = append(paged, page...)
+					if len(page) < 4 {
+						break
+					}
+					last := page[len(page)-1]
+					after = &models.TodoCursor{Sort: sort, Desc: desc, Value: last.SortValue(sort), ID: last.ID}
+				}
+				if !slices.Equal(todoIDs(paged), todoIDs(all)) {
+					t.Errorf("pages of ListTodos(%s, desc %v) = %v, want %v", sort, desc, todoIDs(paged), todoIDs(all))
+				}
+				for i := 1; i < len(all); i++ {
+					a, b := all[i-1].SortValue(sort), all[i].SortValue(sort)
+					if sort == models.TodoSortPriority {
+						a, b = string(rune('0'+all[i-1].Priority)), string(rune('0'+all[i].Priority))
+					}
+					if a != b && (a < b) == desc {
+						t.Errorf("ListTodos(%s, desc %v) puts %q before %q", sort, desc, a, b)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestTodoFilters(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, inbox := createTestUser(t, store, "alice")
+		archived := &models.Project{UserID: alice.ID, Name: "Old", Archived: true}
+		if err := store.Projects.CreateProject(ctx, archived); err != nil {
+			t.Fatal(err)
+		}
+		open := createTestTodo(t, store, &models.Todo{Title: "Buy milk", UserID: alice.ID, ProjectID: inbox.ID})
+		done := createTestTodo(t, store, &models.Todo{Title: "Pay rent", Completed: true, UserID: alice.ID, ProjectID: inbox.ID})
+		old := createTestTodo(t, store, &models.Todo{Title: "Old milk", UserID: alice.ID, ProjectID: archived.ID})
+		sub := createTestTodo(t, store, &models.Todo{Title: "Sub", UserID: alice.ID, ProjectID: inbox.ID, ParentID: &open.ID})
+
+		completed, notCompleted := true, false
+		tests := []struct {
+			name   string
+			filter models.TodoFilter
+			want   []int
+		}{
+			{"all", models.TodoFilter{}, []int{open.ID, done.ID, sub.ID}},
+			{"completed", models.TodoFilter{Completed: &completed}, []int{done.ID}},
+			{"open", models.TodoFilter{Completed: &notCompleted}, []int{open.ID, sub.ID}},
+			{"query", models.TodoFilter{Query: "MILK"}, []int{open.ID}},
+			{"archived included", models.TodoFilter{Query: "milk", IncludeArchived: true}, []int{open.ID, old.ID}},
+			{"archived project", models.TodoFilter{ProjectID: &archived.ID}, []int{old.ID}},
+			{"subtasks", models.TodoFilter{ParentID: &open.ID}, []int{sub.ID}},
+		}
+		for _, tt := range tests {
+			todos, err := store.Todos.ListTodos(ctx, models.TodoListOptions{Filter: tt.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := todoIDs(todos); !slices.Equal(got, tt.want) {
+				t.Errorf("ListTodos(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		tree, err := store.Todos.FindTodoTree(ctx, open.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoIDs(tree); !slices.Equal(got, []int{open.ID, sub.ID}) {
+			t.Errorf("FindTodoTree() = %v, want %v", got, []int{open.ID, sub.ID})
+		}
+	})
+}
+
+func TestUpdateAndDeleteTodo(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, inbox := createTestUser(t, store, "alice")
+		todo := createTestTodo(t, store, &models.Todo{Title: "Draft", UserID: alice.ID, ProjectID: inbox.ID})
+
+		completedAt := time.Date(2026, 5, 1, 12, 30, 0, 123456789, time.UTC)
+		todo.Title = "Final"
+		todo.Completed = true
+		todo.CompletedAt = &completedAt
+		todo.Priority = 3
+		if err := store.Todos.UpdateTodo(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Todos.FindTodoByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != "Final" || !got.Completed || got.Priority != 3 || got.CompletedAt == nil || !got.CompletedAt.Equal(completedAt) {
+			t.Errorf("FindTodoByID() after UpdateTodo = %+v", got)
+		}
+		if !got.CreatedAt.Equal(todo.CreatedAt) {
+			t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, todo.CreatedAt)
+		}
+
+		if err := store.Todos.DeleteTodo(ctx, todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Todos.FindTodoByID(ctx, todo.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("FindTodoByID() after DeleteTodo = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestDeleteProjectMovesTodos(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, inbox := createTestUser(t, store, "alice")
+		work := &models.Project{UserID: alice.ID, Name: "Work"}
+		if err := store.Projects.CreateProject(ctx, work); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Projects.CreateProject(ctx, &models.Project{UserID: alice.ID, Name: "Second inbox", Inbox: true}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("CreateProject() of a second Inbox = %v, want ErrDuplicate", err)
+		}
+		todo := createTestTodo(t, store, &models.Todo{Title: "Report", UserID: alice.ID, ProjectID: work.ID})
+
+		if err := store.Projects.DeleteProject(ctx, work.ID, inbox.ID); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Todos.FindTodoByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ProjectID != inbox.ID {
+			t.Errorf("ProjectID after DeleteProject = %d, want the Inbox %d", got.ProjectID, inbox.ID)
+		}
+		projects, err := store.Projects.ListProjectsByUserID(ctx, alice.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(projects) != 1 || projects[0].ID != inbox.ID {
+			t.Errorf("ListProjectsByUserID() = %v, want only the Inbox", projects)
+		}
+	})
+}
+
+func TestTags(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, inbox := createTestUser(t, store, "alice")
+		bob, _ := createTestUser(t, store, "bob")
+		home := &models.Tag{UserID: alice.ID, Name: "home"}
+		work := &models.Tag{UserID: alice.ID, Name: "work"}
+		for _, tag := range []*models.Tag{work, home, {UserID: bob.ID, Name: "home"}} {
+			if err := store.Tags.CreateTag(ctx, tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.Tags.CreateTag(ctx, &models.Tag{UserID: alice.ID, Name: "Home"}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("CreateTag(Home) = %v, want ErrDuplicate", err)
+		}
+
+		first := createTestTodo(t, store, &models.Todo{Title: "First", UserID: alice.ID, ProjectID: inbox.ID})
+		second := createTestTodo(t, store, &models.Todo{Title: "Second", UserID: alice.ID, ProjectID: inbox.ID})
+		for _, link := range [][2]int{{first.ID, home.ID}, {first.ID, home.ID}, {first.ID, work.ID}, {second.ID, home.ID}} {
+			if err := store.Tags.AttachTag(ctx, link[0], link[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tags, err := store.Tags.ListTagsByUserID(ctx, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != 2 || tags[0].Name != "home" || tags[0].UsageCount != 2 || tags[1].UsageCount != 1 {
+			t.Errorf("ListTagsByUserID() = %+v, want home used twice and work once", tags)
+		}
+
+		filtered, err := store.Todos.ListTodos(ctx, models.TodoListOptions{
+			Filter: models.TodoFilter{TagIDs: []int{home.ID, work.ID}, AllTags: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoIDs(filtered); !slices.Equal(got, []int{first.ID}) {
+			t.Errorf("ListTodos(all tags) = %v, want %v", got, []int{first.ID})
+		}
+
+		if err := store.Tags.DetachTag(ctx, first.ID, home.ID); err != nil {
+			t.Fatal(err)
+		}
+		byTodo, err := store.Tags.FindTagsByTodoIDs(ctx, []int{first.ID, second.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(byTodo[first.ID]) != 1 || byTodo[first.ID][0].ID != work.ID ||
+			len(byTodo[second.ID]) != 1 || byTodo[second.ID][0].ID != home.ID {
+			t.Errorf("FindTagsByTodoIDs() = %v", byTodo)
+		}
+	})
+}
+
+func TestDependencies(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, inbox := createTestUser(t, store, "alice")
+		var todos []*models.Todo
+		for _, title := range []string{"Design", "Build", "Ship"} {
+			todos = append(todos, createTestTodo(t, store, &models.Todo{Title: title, UserID: alice.ID, ProjectID: inbox.ID}))
+		}
+		design, build, ship := todos[0].ID, todos[1].ID, todos[2].ID
+		for _, dep := range [][2]int{{build, design}, {ship, build}, {ship, build}} {
+			if err := store.Dependencies.AddDependency(ctx, dep[0], dep[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			todo, blocker int
+			want          bool
+		}{
+			{build, design, true},
+			{ship, design, true},
+			{design, ship, false},
+			{design, design, false},
+		}
+		for _, tt := range tests {
+			if got, err := store.Dependencies.DependsOn(ctx, tt.todo, tt.blocker); err != nil || got != tt.want {
+				t.Errorf("DependsOn(%d, %d) = %v, %v, want %v", tt.todo, tt.blocker, got, err, tt.want)
+			}
+		}
+
+		blocking, err := store.Dependencies.FindBlocking(ctx, build)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoIDs(blocking); !slices.Equal(got, []int{ship}) {
+			t.Errorf("FindBlocking() = %v, want %v", got, []int{ship})
+		}
+		if err := store.Dependencies.RemoveDependency(ctx, build, design); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Dependencies.DependsOn(ctx, ship, design); err != nil || got {
+			t.Errorf("DependsOn() after RemoveDependency = %v, %v, want false", got, err)
+		}
+	})
+}
+
+func TestRefreshTokens(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		alice, _ := createTestUser(t, store, "alice")
+		expires := time.Now().Add(time.Hour)
+		tokens := map[string]*models.RefreshToken{}
+		for _, name := range []string{"a1", "a2", "b1", "c1"} {
+			token := &models.RefreshToken{UserID: alice.ID, FamilyID: name[:1], TokenHash: name, ExpiresAt: expires}
+			if err := store.RefreshTokens.CreateRefreshToken(ctx, token); err != nil {
+				t.Fatal(err)
+			}
+			tokens[name] = token
+		}
+		dup := &models.RefreshToken{UserID: alice.ID, FamilyID: "d", TokenHash: "a1", ExpiresAt: expires}
+		if err := store.RefreshTokens.CreateRefreshToken(ctx, dup); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("CreateRefreshToken() with a used hash = %v, want ErrDuplicate", err)
+		}
+
+		now := time.Now()
+		if ok, err := store.RefreshTokens.UseRefreshToken(ctx, tokens["a1"].ID, now); err != nil || !ok {
+			t.Fatalf("UseRefreshToken() = %v, %v, want true", ok, err)
+		}
+		if ok, err := store.RefreshTokens.UseRefreshToken(ctx, tokens["a1"].ID, now); err != nil || ok {
+			t.Errorf("UseRefreshToken() twice = %v, %v, want false", ok, err)
+		}
+
+		if err := store.RefreshTokens.RevokeRefreshTokenFamily(ctx, "a", now); err != nil {
+			t.Fatal(err)
+		}
+		revoked, err := store.RefreshTokens.RevokeOtherRefreshTokens(ctx, alice.ID, "b", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(revoked, []string{"c"}) {
+			t.Errorf("RevokeOtherRefreshTokens() = %v, want [c]", revoked)
+		}
+		for name, want := range map[string]bool{"a1": true, "a2": true, "b1": false, "c1": true} {
+			token, err := store.RefreshTokens.FindRefreshTokenByHash(ctx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := token.RevokedAt != nil; got != want {
+				t.Errorf("token %s revoked = %v, want %v", name, got, want)
+			}
+		}
+		revoked, err = store.RefreshTokens.RevokeUserRefreshTokens(ctx, alice.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(revoked, []string{"b"}) {
+			t.Errorf("RevokeUserRefreshTokens() = %v, want [b]", revoked)
+		}
+		if _, err := store.RefreshTokens.FindRefreshTokenByHash(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("FindRefreshTokenByHash(unknown) = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	eachStore(t, func(t *testing.T, store *Store) {
+		ctx := context.Background()
+		role := &models.Role{Name: "support", Permissions: []string{models.PermTodosReadAny, models.PermUsersRead}}
+		if err := store.Roles.SaveRole(ctx, role); err != nil {
+			t.Fatal(err)
+		}
+		role.Permissions = []string{models.PermUsersRead}
+		role.Description = "Helps users"
+		if err := store.Roles.SaveRole(ctx, role); err != nil {
+			t.Fatal(err)
+		}
+
+		roles, err := store.Roles.ListRoles(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, r := range roles {
+			names = append(names, r.Name)
+		}
+		if want := []string{models.RoleAdmin, "support", models.RoleUser}; !slices.Equal(names, want) {
+			t.Errorf("ListRoles() = %v, want %v", names, want)
+		}
+		found, err := store.Roles.FindRole(ctx, "support")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Description != "Helps users" || !slices.Equal(found.Permissions, []string{models.PermUsersRead}) {
+			t.Errorf("FindRole() = %+v", found)
+		}
+		for permission, want := range map[string]bool{models.PermUsersRead: true, models.PermTodosReadAny: false} {
+			if got, err := store.Roles.HasPermission(ctx, "support", permission); err != nil || got != want {
+				t.Errorf("HasPermission(%s) = %v, %v, want %v", permission, got, err, want)
+			}
+		}
+
+		if err := store.Roles.DeleteRole(ctx, "support"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Roles.FindRole(ctx, "support"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("FindRole() after DeleteRole = %v, want ErrNotFound", err)
+		}
+	})
+}
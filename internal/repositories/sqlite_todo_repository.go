@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/search"
+)
+
+type sqliteTodoRepository struct {
+	db *database.SQLiteDB
+}
+
+// scanSQLiteTodo scans todoColumns followed by any extra selected columns.
+func scanSQLiteTodo(row rowScanner, extra ...any) (*models.Todo, error) {
+	todo := &models.Todo{}
+	dest := []any{&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.Priority,
+		nullTimeScanner{&todo.StartAt}, nullTimeScanner{&todo.DueAt}, nullTimeScanner{&todo.CompletedAt},
+		&todo.ProjectID, &todo.ParentID, &todo.RollUp, &todo.Recurrence, &todo.SeriesID, &todo.Occurrence, &todo.UserID, timeScanner{&todo.CreatedAt}}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (r *sqliteTodoRepository) CreateTodo(ctx context.Context, todo *models.Todo) error {
+	query := `
+		INSERT INTO todos (title, description, completed, priority, start_at, due_at, completed_at, project_id, parent_id, rollup, recurrence, series_id, occurrence, user_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	createdAt := time.Now().UTC()
+	err := r.db.DB.QueryRowContext(ctx, query, todo.Title, todo.Description, todo.Completed, todo.Priority,
+		sqliteNullTime(todo.StartAt), sqliteNullTime(todo.DueAt), sqliteNullTime(todo.CompletedAt),
+		todo.ProjectID, todo.ParentID, todo.RollUp, todo.Recurrence, todo.SeriesID, todo.Occurrence, todo.UserID, sqliteTime(createdAt)).
+		Scan(&todo.ID)
+	if err != nil {
+		return sqliteError(err)
+	}
+	todo.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqliteTodoRepository) FindTodoByID(ctx context.Context, id int) (*models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos t WHERE t.id = ?`
+	todo, err := scanSQLiteTodo(r.db.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return todo, nil
+}
+
+func (r *sqliteTodoRepository) FindTodosByUserID(ctx context.Context, userID int) ([]*models.Todo, error) {
+	return r.ListTodos(ctx, models.TodoListOptions{Filter: models.TodoFilter{UserID: &userID}})
+}
+
+func (r *sqliteTodoRepository) ListTodos(ctx context.Context, opts models.TodoListOptions) ([]*models.Todo, error) {
+	b := &queryBuilder{sqlite: true}
+	suffix, err := applyTodoListOptions(b, opts)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + todoColumns + ` FROM todos t` + b.whereClause() + suffix
+	return r.queryTodos(ctx, query, b.args...)
+}
+
+func (r *sqliteTodoRepository) queryTodos(ctx context.Context, query string, args ...any) ([]*models.Todo, error) {
+	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []*models.Todo
+	for rows.Next() {
+		todo, err := scanSQLiteTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
+
+func (r *sqliteTodoRepository) SearchTodos(ctx context.Context, query search.Query, filter models.TodoFilter, limit int) ([]*models.TodoSearchResult, error) {
+	b := &queryBuilder{sqlite: true}
+	b.where("todos_fts MATCH " + b.arg(query.FTS5()))
+	applyTodoFilter(b, filter)
+
+	// bm25 scores better matches lower, so it is negated into a rank. Title
+	// hits weigh more than description hits, as in the Postgres index.
+	sql := `
+		SELECT ` + todoColumns + `,
+			-bm25(todos_fts, 4.0, 1.0) AS rank,
+			highlight(todos_fts, 0, '` + search.HighlightStart + `', '` + search.HighlightStop + `'),
+			snippet(todos_fts, 1, '` + search.HighlightStart + `', '` + search.HighlightStop + `', '...', 20)
+		FROM todos_fts
+		JOIN todos t ON t.id = todos_fts.rowid` +
+		b.whereClause() + `
+		ORDER BY rank DESC, t.id
+		LIMIT ` + b.arg(limit)
+
+	rows, err := r.db.DB.QueryContext(ctx, sql, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.TodoSearchResult
+	for rows.Next() {
+		res := &models.TodoSearchResult{}
+		res.Todo, err = scanSQLiteTodo(rows, &res.Rank, &res.TitleHighlight, &res.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func (r *sqliteTodoRepository) TodoStatsByUser(ctx context.Context) ([]*models.UserTodoStats, error) {
+	query := `
+		SELECT u.id, u.username, COUNT(t.id), COUNT(t.id) FILTER (WHERE t.completed)
+		FROM users u
+		LEFT JOIN todos t ON t.user_id = u.id
+		GROUP BY u.id, u.username
+		ORDER BY u.id
+	`
+	rows, err := r.db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.UserTodoStats
+	for rows.Next() {
+		s := &models.UserTodoStats{}
+		if err := rows.Scan(&s.UserID, &s.Username, &s.Total, &s.Completed); err != nil {
+			return nil, err
+		}
+		s.Open = s.Total - s.Completed
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (r *sqliteTodoRepository) FindTodoTree(ctx context.Context, id int) ([]*models.Todo, error) {
+	return r.queryTodos(ctx, todoTreeQuery("?"), id)
+}
+
+func (r *sqliteTodoRepository) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	query := `
+		UPDATE todos
+		SET title = ?, description = ?, completed = ?, priority = ?, start_at = ?, due_at = ?, completed_at = ?,
+			project_id = ?, parent_id = ?, rollup = ?, recurrence = ?, series_id = ?, occurrence = ?
+		WHERE id = ?
+	`
+	_, err := r.db.DB.ExecContext(ctx, query, todo.Title, todo.Description, todo.Completed, todo.Priority,
+		sqliteNullTime(todo.StartAt), sqliteNullTime(todo.DueAt), sqliteNullTime(todo.CompletedAt),
+		todo.ProjectID, todo.ParentID, todo.RollUp, todo.Recurrence, todo.SeriesID, todo.Occurrence, todo.ID)
+	return err
+}
+
+func (r *sqliteTodoRepository) DeleteTodo(ctx context.Context, id int) error {
+	query := `DELETE FROM todos WHERE id = ?`
+	_, err := r.db.DB.ExecContext(ctx, query, id)
+	return err
+}
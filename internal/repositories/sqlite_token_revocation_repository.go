@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteTokenRevocationRepository struct {
+	db *database.SQLiteDB
+}
+
+func (r *sqliteTokenRevocationRepository) SaveTokenRevocation(ctx context.Context, rev *models.TokenRevocation) error {
+	query := `
+		INSERT INTO token_revocations (user_id, jti, session_id, issued_before, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, jti, session_id) DO UPDATE
+		SET issued_before = excluded.issued_before, expires_at = excluded.expires_at
+	`
+	_, err := r.db.DB.ExecContext(ctx, query, rev.UserID, rev.JTI, rev.SessionID, sqliteNullTime(rev.IssuedBefore), sqliteTime(rev.ExpiresAt))
+	return err
+}
+
+func (r *sqliteTokenRevocationRepository) ListTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error) {
+	query := `SELECT user_id, jti, session_id, issued_before, expires_at FROM token_revocations WHERE expires_at > ?`
+	rows, err := r.db.DB.QueryContext(ctx, query, sqliteTime(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []*models.TokenRevocation
+	for rows.Next() {
+		rev := &models.TokenRevocation{}
+		if err := rows.Scan(&rev.UserID, &rev.JTI, &rev.SessionID, nullTimeScanner{&rev.IssuedBefore}, timeScanner{&rev.ExpiresAt}); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+}
+
+func (r *sqliteTokenRevocationRepository) DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM token_revocations WHERE expires_at <= ?`, sqliteTime(now))
+	return err
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+type sqliteUserRepository struct {
+	db *database.SQLiteDB
+}
+
+func scanSQLiteUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.DisplayName, &user.Email,
+		nullTimeScanner{&user.EmailVerifiedAt}, &user.Timezone, &user.Locale, &user.Disabled, timeScanner{&user.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *sqliteUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.Locale == "" {
+		user.Locale = "en"
+	}
+	query := `
+		INSERT INTO users (username, password, role, display_name, email, timezone, locale, disabled, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?)
+		RETURNING id
+	`
+	createdAt := time.Now().UTC()
+	err := r.db.DB.QueryRowContext(ctx, query, user.Username, user.Password, user.Role, user.DisplayName, user.Email,
+		user.Timezone, user.Locale, user.Disabled, sqliteTime(createdAt)).
+		Scan(&user.ID)
+	if err != nil {
+		return sqliteError(err)
+	}
+	user.CreatedAt = createdAt
+	return nil
+}
+
+func (r *sqliteUserRepository) FindUserByID(ctx context.Context, id int) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	user, err := scanSQLiteUser(r.db.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return user, nil
+}
+
+func (r *sqliteUserRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+	user, err := scanSQLiteUser(r.db.DB.QueryRowContext(ctx, query, username))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return user, nil
+}
+
+func (r *sqliteUserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(email) = LOWER(?)`
+	user, err := scanSQLiteUser(r.db.DB.QueryRowContext(ctx, query, email))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return user, nil
+}
+
+func (r *sqliteUserRepository) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error) {
+	b := &queryBuilder{sqlite: true}
+	applyUserFilter(b, filter)
+	query := `SELECT ` + userColumns + ` FROM users` + b.whereClause() + userPage(b, filter)
+	rows, err := r.db.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *sqliteUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET username = ?, role = ?, display_name = ?, email = NULLIF(?, ''),
+			email_verified_at = ?, timezone = ?, locale = ?, disabled = ?
+		WHERE id = ?
+	`
+	_, err := r.db.DB.ExecContext(ctx, query, user.Username, user.Role, user.DisplayName, user.Email,
+		sqliteNullTime(user.EmailVerifiedAt), user.Timezone, user.Locale, user.Disabled, user.ID)
+	return sqliteError(err)
+}
+
+func (r *sqliteUserRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE users SET password = ? WHERE id = ?`, hash, id)
+	return err
+}
+
+func (r *sqliteUserRepository) VerifyEmail(ctx context.Context, id int, email string, at time.Time) (bool, error) {
+	query := `UPDATE users SET email_verified_at = ? WHERE id = ? AND LOWER(email) = LOWER(?)`
+	res, err := r.db.DB.ExecContext(ctx, query, sqliteTime(at), id, email)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *sqliteUserRepository) DeleteUser(ctx context.Context, id int) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	return err
+}
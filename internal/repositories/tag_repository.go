@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// TagRepository is the storage contract for tags and their links to todos.
+type TagRepository interface {
+	CreateTag(ctx context.Context, tag *models.Tag) error
+	FindTagByID(ctx context.Context, id int) (*models.Tag, error)
+	// ListTagsByUserID returns the user's tags by name, with usage counts.
+	ListTagsByUserID(ctx context.Context, userID int) ([]*models.Tag, error)
+	UpdateTag(ctx context.Context, tag *models.Tag) error
+	DeleteTag(ctx context.Context, id int) error
+	// AttachTag links a tag to a todo; attaching twice is not an error.
+	AttachTag(ctx context.Context, todoID, tagID int) error
+	DetachTag(ctx context.Context, todoID, tagID int) error
+	// FindTagsByTodoIDs returns the tags of each of the given todos.
+	FindTagsByTodoIDs(ctx context.Context, todoIDs []int) (map[int][]*models.Tag, error)
+}
+
+type pgTagRepository struct {
+	db *database.DB
+}
+
+func NewTagRepository(db *database.DB) TagRepository {
+	return &pgTagRepository{db: db}
+}
+
+func (r *pgTagRepository) CreateTag(ctx context.Context, tag *models.Tag) error {
+	query := `
+		INSERT INTO tags (user_id, name, color)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	err := r.db.Pool.QueryRow(ctx, query, tag.UserID, tag.Name, tag.Color).
+		Scan(&tag.ID, &tag.CreatedAt)
+	return pgError(err)
+}
+
+func (r *pgTagRepository) FindTagByID(ctx context.Context, id int) (*models.Tag, error) {
+	tag := &models.Tag{}
+	query := `
+		SELECT g.id, g.user_id, g.name, g.color, g.created_at,
+			(SELECT COUNT(*) FROM todo_tags tt WHERE tt.tag_id = g.id)
+		FROM tags g
+		WHERE g.id = $1
+	`
+	err := r.db.Pool.QueryRow(ctx, query, id).
+		Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UsageCount)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return tag, nil
+}
+
+func (r *pgTagRepository) ListTagsByUserID(ctx context.Context, userID int) ([]*models.Tag, error) {
+	query := `
+		SELECT g.id, g.user_id, g.name, g.color, g.created_at, COUNT(tt.todo_id)
+		FROM tags g
+		LEFT JOIN todo_tags tt ON tt.tag_id = g.id
+		WHERE g.user_id = $1
+		GROUP BY g.id
+		ORDER BY LOWER(g.name), g.id
+	`
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UsageCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (r *pgTagRepository) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	query := `UPDATE tags SET name = $1, color = $2 WHERE id = $3`
+	_, err := r.db.Pool.Exec(ctx, query, tag.Name, tag.Color, tag.ID)
+	return pgError(err)
+}
+
+func (r *pgTagRepository) DeleteTag(ctx context.Context, id int) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM tags WHERE id = $1`, id)
+	return err
+}
+
+func (r *pgTagRepository) AttachTag(ctx context.Context, todoID, tagID int) error {
+	query := `
+		INSERT INTO todo_tags (todo_id, tag_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := r.db.Pool.Exec(ctx, query, todoID, tagID)
+	return err
+}
+
+func (r *pgTagRepository) DetachTag(ctx context.Context, todoID, tagID int) error {
+	query := `DELETE FROM todo_tags WHERE todo_id = $1 AND tag_id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, todoID, tagID)
+	return err
+}
+
+func (r *pgTagRepository) FindTagsByTodoIDs(ctx context.Context, todoIDs []int) (map[int][]*models.Tag, error) {
+	tags := make(map[int][]*models.Tag)
+	if len(todoIDs) == 0 {
+		return tags, nil
+	}
+	query := `
+		SELECT tt.todo_id, g.id, g.user_id, g.name, g.color, g.created_at
+		FROM todo_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.todo_id = ANY($1)
+		ORDER BY LOWER(g.name), g.id
+	`
+	rows, err := r.db.Pool.Query(ctx, query, todoIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		tag := &models.Tag{}
+		if err := rows.Scan(&todoID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags[todoID] = append(tags[todoID], tag)
+	}
+	return tags, rows.Err()
+}
@@ -5,48 +5,105 @@ import (
 
 	"github.com/globallstudent/todo-project-go/internal/database"
 	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/search"
 )
 
-type TodoRepository struct {
+// TodoRepository is the storage contract for todos.
+type TodoRepository interface {
+	CreateTodo(ctx context.Context, todo *models.Todo) error
+	FindTodoByID(ctx context.Context, id int) (*models.Todo, error)
+	FindTodosByUserID(ctx context.Context, userID int) ([]*models.Todo, error)
+	// ListTodos returns the todos of every user selected by opts, in the
+	// requested order and starting after opts.After.
+	ListTodos(ctx context.Context, opts models.TodoListOptions) ([]*models.Todo, error)
+	// SearchTodos returns the todos selected by filter that match query,
+	// best match first.
+	SearchTodos(ctx context.Context, query search.Query, filter models.TodoFilter, limit int) ([]*models.TodoSearchResult, error)
+	// TodoStatsByUser returns todo counts for every user, including users
+	// without any todos.
+	TodoStatsByUser(ctx context.Context) ([]*models.UserTodoStats, error)
+	// FindTodoTree returns the todo and all of its subtasks at any depth,
+	// ordered by ID.
+	FindTodoTree(ctx context.Context, id int) ([]*models.Todo, error)
+	UpdateTodo(ctx context.Context, todo *models.Todo) error
+	DeleteTodo(ctx context.Context, id int) error
+}
+
+// todoColumns is the column list scanned by scanTodo, with todos aliased t.
+const todoColumns = `t.id, t.title, t.description, t.completed, t.priority, t.start_at, t.due_at, t.completed_at, t.project_id, t.parent_id, t.rollup, t.recurrence, t.series_id, t.occurrence, t.user_id, t.created_at`
+
+// todoTreeQuery selects the todo whose ID is bound to placeholder and all of
+// its descendants. UNION rather than UNION ALL discards rows already seen, so
+// the recursion ends even if the parent links were to form a cycle.
+func todoTreeQuery(placeholder string) string {
+	return `
+		WITH RECURSIVE tree (id) AS (
+			SELECT id FROM todos WHERE id = ` + placeholder + `
+			UNION
+			SELECT c.id FROM todos c JOIN tree ON c.parent_id = tree.id
+		)
+		SELECT ` + todoColumns + `
+		FROM todos t
+		JOIN tree ON tree.id = t.id
+		ORDER BY t.id
+	`
+}
+
+type pgTodoRepository struct {
 	db *database.DB
 }
 
-func NewTodoRepository(db *database.DB) *TodoRepository {
-	return &TodoRepository{db: db}
+func NewTodoRepository(db *database.DB) TodoRepository {
+	return &pgTodoRepository{db: db}
+}
+
+// scanPGTodo scans todoColumns followed by any extra selected columns.
+func scanPGTodo(row rowScanner, extra ...any) (*models.Todo, error) {
+	todo := &models.Todo{}
+	dest := []any{&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.Priority,
+		&todo.StartAt, &todo.DueAt, &todo.CompletedAt, &todo.ProjectID, &todo.ParentID, &todo.RollUp, &todo.Recurrence, &todo.SeriesID, &todo.Occurrence, &todo.UserID, &todo.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return todo, nil
 }
 
-func (r *TodoRepository) CreateTodo(ctx context.Context, todo *models.Todo) error {
+func (r *pgTodoRepository) CreateTodo(ctx context.Context, todo *models.Todo) error {
 	query := `
-		INSERT INTO todos (title, description, completed, user_id)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO todos (title, description, completed, priority, start_at, due_at, completed_at, project_id, parent_id, rollup, recurrence, series_id, occurrence, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING id, created_at
 	`
-	return r.db.Pool.QueryRow(ctx, query, todo.Title, todo.Description, todo.Completed, todo.UserID).
+	return r.db.Pool.QueryRow(ctx, query, todo.Title, todo.Description, todo.Completed, todo.Priority,
+		todo.StartAt, todo.DueAt, todo.CompletedAt, todo.ProjectID, todo.ParentID, todo.RollUp, todo.Recurrence, todo.SeriesID, todo.Occurrence, todo.UserID).
 		Scan(&todo.ID, &todo.CreatedAt)
 }
 
-func (r *TodoRepository) FindTodoByID(ctx context.Context, id int) (*models.Todo, error) {
-	todo := &models.Todo{}
-	query := `
-		SELECT id, title, description, completed, user_id, created_at
-		FROM todos
-		WHERE id = $1
-	`
-	err := r.db.Pool.QueryRow(ctx, query, id).
-		Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.UserID, &todo.CreatedAt)
+func (r *pgTodoRepository) FindTodoByID(ctx context.Context, id int) (*models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos t WHERE t.id = $1`
+	todo, err := scanPGTodo(r.db.Pool.QueryRow(ctx, query, id))
 	if err != nil {
-		return nil, err
+		return nil, pgError(err)
 	}
 	return todo, nil
 }
 
-func (r *TodoRepository) FindTodosByUserID(ctx context.Context, userID int) ([]*models.Todo, error) {
-	query := `
-		SELECT id, title, description, completed, user_id, created_at
-		FROM todos
-		WHERE user_id = $1
-	`
-	rows, err := r.db.Pool.Query(ctx, query, userID)
+func (r *pgTodoRepository) FindTodosByUserID(ctx context.Context, userID int) ([]*models.Todo, error) {
+	return r.ListTodos(ctx, models.TodoListOptions{Filter: models.TodoFilter{UserID: &userID}})
+}
+
+func (r *pgTodoRepository) ListTodos(ctx context.Context, opts models.TodoListOptions) ([]*models.Todo, error) {
+	b := &queryBuilder{}
+	suffix, err := applyTodoListOptions(b, opts)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + todoColumns + ` FROM todos t` + b.whereClause() + suffix
+	return r.queryTodos(ctx, query, b.args...)
+}
+
+func (r *pgTodoRepository) queryTodos(ctx context.Context, query string, args ...any) ([]*models.Todo, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,26 +111,94 @@ func (r *TodoRepository) FindTodosByUserID(ctx context.Context, userID int) ([]*
 
 	var todos []*models.Todo
 	for rows.Next() {
-		todo := &models.Todo{}
-		if err := rows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.UserID, &todo.CreatedAt); err != nil {
+		todo, err := scanPGTodo(rows)
+		if err != nil {
 			return nil, err
 		}
 		todos = append(todos, todo)
 	}
-	return todos, nil
+	return todos, rows.Err()
+}
+
+func (r *pgTodoRepository) SearchTodos(ctx context.Context, query search.Query, filter models.TodoFilter, limit int) ([]*models.TodoSearchResult, error) {
+	b := &queryBuilder{}
+	tsquery := b.arg(query.TSQuery())
+	b.where("t.search_vector @@ q")
+	applyTodoFilter(b, filter)
+
+	sql := `
+		SELECT ` + todoColumns + `,
+			ts_rank(t.search_vector, q) AS rank,
+			ts_headline('english', t.title, q, 'HighlightAll=true, StartSel=` + search.HighlightStart + `, StopSel=` + search.HighlightStop + `'),
+			ts_headline('english', COALESCE(t.description, ''), q, 'MaxFragments=2, MaxWords=20, MinWords=5, StartSel=` + search.HighlightStart + `, StopSel=` + search.HighlightStop + `')
+		FROM todos t, to_tsquery('english', ` + tsquery + `) q` +
+		b.whereClause() + `
+		ORDER BY rank DESC, t.id
+		LIMIT ` + b.arg(limit)
+
+	rows, err := r.db.Pool.Query(ctx, sql, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.TodoSearchResult
+	for rows.Next() {
+		res := &models.TodoSearchResult{}
+		var rank float32
+		res.Todo, err = scanPGTodo(rows, &rank, &res.TitleHighlight, &res.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		res.Rank = float64(rank)
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func (r *pgTodoRepository) TodoStatsByUser(ctx context.Context) ([]*models.UserTodoStats, error) {
+	query := `
+		SELECT u.id, u.username, COUNT(t.id), COUNT(t.id) FILTER (WHERE t.completed)
+		FROM users u
+		LEFT JOIN todos t ON t.user_id = u.id
+		GROUP BY u.id, u.username
+		ORDER BY u.id
+	`
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.UserTodoStats
+	for rows.Next() {
+		s := &models.UserTodoStats{}
+		if err := rows.Scan(&s.UserID, &s.Username, &s.Total, &s.Completed); err != nil {
+			return nil, err
+		}
+		s.Open = s.Total - s.Completed
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (r *pgTodoRepository) FindTodoTree(ctx context.Context, id int) ([]*models.Todo, error) {
+	return r.queryTodos(ctx, todoTreeQuery("$1"), id)
 }
 
-func (r *TodoRepository) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+func (r *pgTodoRepository) UpdateTodo(ctx context.Context, todo *models.Todo) error {
 	query := `
 		UPDATE todos
-		SET title = $1, description = $2, completed = $3
-		WHERE id = $4
+		SET title = $1, description = $2, completed = $3, priority = $4, start_at = $5, due_at = $6, completed_at = $7,
+			project_id = $8, parent_id = $9, rollup = $10, recurrence = $11, series_id = $12, occurrence = $13
+		WHERE id = $14
 	`
-	_, err := r.db.Pool.Exec(ctx, query, todo.Title, todo.Description, todo.Completed, todo.ID)
+	_, err := r.db.Pool.Exec(ctx, query, todo.Title, todo.Description, todo.Completed, todo.Priority,
+		todo.StartAt, todo.DueAt, todo.CompletedAt, todo.ProjectID, todo.ParentID, todo.RollUp, todo.Recurrence, todo.SeriesID, todo.Occurrence, todo.ID)
 	return err
 }
 
-func (r *TodoRepository) DeleteTodo(ctx context.Context, id int) error {
+func (r *pgTodoRepository) DeleteTodo(ctx context.Context, id int) error {
 	query := `DELETE FROM todos WHERE id = $1`
 	_, err := r.db.Pool.Exec(ctx, query, id)
 	return err
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/database"
+	"github.com/globallstudent/todo-project-go/internal/models"
+)
+
+// TokenRevocationRepository is the storage contract for revoked access
+// tokens.
+type TokenRevocationRepository interface {
+	// SaveTokenRevocation stores rev, replacing an earlier revocation of the
+	// same user, JTI and session.
+	SaveTokenRevocation(ctx context.Context, rev *models.TokenRevocation) error
+	// ListTokenRevocations returns the revocations that have not expired at
+	// now.
+	ListTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error)
+	// DeleteExpiredTokenRevocations forgets the revocations expired at now.
+	DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error
+}
+
+type pgTokenRevocationRepository struct {
+	db *database.DB
+}
+
+func NewTokenRevocationRepository(db *database.DB) TokenRevocationRepository {
+	return &pgTokenRevocationRepository{db: db}
+}
+
+func (r *pgTokenRevocationRepository) SaveTokenRevocation(ctx context.Context, rev *models.TokenRevocation) error {
+	query := `
+		INSERT INTO token_revocations (user_id, jti, session_id, issued_before, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, jti, session_id) DO UPDATE
+		SET issued_before = EXCLUDED.issued_before, expires_at = EXCLUDED.expires_at
+	`
+	_, err := r.db.Pool.Exec(ctx, query, rev.UserID, rev.JTI, rev.SessionID, rev.IssuedBefore, rev.ExpiresAt)
+	return err
+}
+
+func (r *pgTokenRevocationRepository) ListTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error) {
+	query := `SELECT user_id, jti, session_id, issued_before, expires_at FROM token_revocations WHERE expires_at > $1`
+	rows, err := r.db.Pool.Query(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []*models.TokenRevocation
+	for rows.Next() {
+		rev := &models.TokenRevocation{}
+		if err := rows.Scan(&rev.UserID, &rev.JTI, &rev.SessionID, &rev.IssuedBefore, &rev.ExpiresAt); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+}
+
+func (r *pgTokenRevocationRepository) DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM token_revocations WHERE expires_at <= $1`, now)
+	return err
+}
@@ -2,40 +2,153 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/globallstudent/todo-project-go/internal/database"
 	"github.com/globallstudent/todo-project-go/internal/models"
 )
 
-type UserRepository struct {
+// UserRepository is the storage contract for user accounts.
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	FindUserByID(ctx context.Context, id int) (*models.User, error)
+	FindUserByUsername(ctx context.Context, username string) (*models.User, error)
+	// ListUsers returns the users matching filter ordered by username.
+	ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error)
+	// FindUserByEmail finds the user with the email address, ignoring case.
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// UpdateUser stores the account fields of the user. The password is left
+	// alone, so that writing back a user read earlier cannot undo a password
+	// change made in between; UpdatePassword changes it.
+	UpdateUser(ctx context.Context, user *models.User) error
+	// UpdatePassword replaces the password hash of the user, leaving the
+	// rest of the account untouched.
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	// VerifyEmail marks the email address of the user as verified at the
+	// given time. It reports false if the user's address is no longer email,
+	// ignoring case.
+	VerifyEmail(ctx context.Context, id int, email string, at time.Time) (bool, error)
+	// DeleteUser deletes the user together with everything they own.
+	DeleteUser(ctx context.Context, id int) error
+}
+
+// userColumns is the column list scanned by the user scan helpers. Users
+// without an email have NULL in the column, so that the unique index only
+// covers the ones that do.
+const userColumns = `id, username, password, role, display_name, COALESCE(email, ''), email_verified_at, timezone, locale,
+	disabled, created_at`
+
+type pgUserRepository struct {
 	db *database.DB
 }
 
-func NewUserRepository(db *database.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db *database.DB) UserRepository {
+	return &pgUserRepository{db: db}
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+func scanPGUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.DisplayName, &user.Email,
+		&user.EmailVerifiedAt, &user.Timezone, &user.Locale, &user.Disabled, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *pgUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.Locale == "" {
+		user.Locale = "en"
+	}
 	query := `
-		INSERT INTO users (username, password, role)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (username, password, role, display_name, email, timezone, locale, disabled)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
 		RETURNING id, created_at
 	`
-	return r.db.Pool.QueryRow(ctx, query, user.Username, user.Password, user.Role).
+	err := r.db.Pool.QueryRow(ctx, query, user.Username, user.Password, user.Role, user.DisplayName, user.Email,
+		user.Timezone, user.Locale, user.Disabled).
 		Scan(&user.ID, &user.CreatedAt)
+	return pgError(err)
 }
 
-func (r *UserRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	user := &models.User{}
+func (r *pgUserRepository) FindUserByID(ctx context.Context, id int) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	user, err := scanPGUser(r.db.Pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return user, nil
+}
+
+func (r *pgUserRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	user, err := scanPGUser(r.db.Pool.QueryRow(ctx, query, username))
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return user, nil
+}
+
+func (r *pgUserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(email) = LOWER($1)`
+	user, err := scanPGUser(r.db.Pool.QueryRow(ctx, query, email))
+	if err != nil {
+		return nil, pgError(err)
+	}
+	return user, nil
+}
+
+func (r *pgUserRepository) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error) {
+	b := &queryBuilder{}
+	applyUserFilter(b, filter)
+	query := `SELECT ` + userColumns + ` FROM users` + b.whereClause() + userPage(b, filter)
+	rows, err := r.db.Pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanPGUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *pgUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	query := `
-		SELECT id, username, password, role, created_at
-		FROM users
-		WHERE username = $1
+		UPDATE users
+		SET username = $1, role = $2, display_name = $3, email = NULLIF($4, ''),
+			email_verified_at = $5, timezone = $6, locale = $7, disabled = $8
+		WHERE id = $9
 	`
-	err := r.db.Pool.QueryRow(ctx, query, username).
-		Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.CreatedAt)
+	_, err := r.db.Pool.Exec(ctx, query, user.Username, user.Role, user.DisplayName, user.Email,
+		user.EmailVerifiedAt, user.Timezone, user.Locale, user.Disabled, user.ID)
+	return pgError(err)
+}
+
+func (r *pgUserRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE users SET password = $1 WHERE id = $2`, hash, id)
+	return err
+}
+
+func (r *pgUserRepository) VerifyEmail(ctx context.Context, id int, email string, at time.Time) (bool, error) {
+	query := `UPDATE users SET email_verified_at = $1 WHERE id = $2 AND LOWER(email) = LOWER($3)`
+	tag, err := r.db.Pool.Exec(ctx, query, at, id, email)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	return user, nil
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *pgUserRepository) DeleteUser(ctx context.Context, id int) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	return err
 }
@@ -0,0 +1,204 @@
+// Package search parses the todo search syntax and renders it for each
+// storage backend.
+//
+// A query is a list of terms that must all match. A term is either a single
+// word or a "quoted phrase" whose words must appear next to each other. A
+// trailing * turns the last word of a term into a prefix, so plan* matches
+// planning and "weekly rev*" matches "weekly review".
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptyQuery is returned when a query contains no searchable words.
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// HighlightStart and HighlightStop surround matched words in highlighted
+// titles and snippets.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// Term is a word or phrase that must appear in a matching todo.
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+// Query is a parsed search query.
+type Query struct {
+	Terms []Term
+}
+
+// Parse turns user input into a Query. Punctuation inside words splits them
+// into a phrase, so "e-mail" searches for "e mail".
+func Parse(input string) (Query, error) {
+	var q Query
+	rest := strings.TrimSpace(input)
+	for rest != "" {
+		var raw string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+			if strings.HasPrefix(rest, "*") {
+				raw += "*"
+				rest = rest[1:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			raw, rest = rest[:end], rest[end:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		if term, ok := parseTerm(raw); ok {
+			q.Terms = append(q.Terms, term)
+		}
+	}
+	if len(q.Terms) == 0 {
+		return q, ErrEmptyQuery
+	}
+	return q, nil
+}
+
+func parseTerm(raw string) (Term, bool) {
+	term := Term{Prefix: strings.HasSuffix(raw, "*")}
+	term.Words = Words(raw)
+	return term, len(term.Words) > 0
+}
+
+// Words splits text into lower-cased runs of letters and digits.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TSQuery renders q in Postgres to_tsquery syntax. Words only contain
+// letters and digits, so the result is always well formed.
+func (q Query) TSQuery() string {
+	terms := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		words := make([]string, len(t.Words))
+		for j, w := range t.Words {
+			words[j] = "'" + w + "'"
+		}
+		if t.Prefix {
+			words[len(words)-1] += ":*"
+		}
+		terms[i] = strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			terms[i] = "(" + terms[i] + ")"
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// FTS5 renders q in SQLite FTS5 MATCH syntax.
+func (q Query) FTS5() string {
+	terms := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		terms[i] = `"` + strings.Join(t.Words, " ") + `"`
+		if t.Prefix {
+			terms[i] += " *"
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// Count returns how many times the terms of q occur in text, or 0 unless
+// every term occurs at least once. It is the in-memory stand-in for a full
+// text index.
+func (q Query) Count(text string) int {
+	words := Words(text)
+	total := 0
+	for _, t := range q.Terms {
+		n := len(t.matches(words))
+		if n == 0 {
+			return 0
+		}
+		total += n
+	}
+	return total
+}
+
+// Highlight wraps the words of text matched by any term of q in
+// HighlightStart and HighlightStop.
+func (q Query) Highlight(text string) string {
+	type span struct{ start, end int }
+	var spans []span
+	var words []string
+	for i := 0; i < len(text); {
+		start := strings.IndexFunc(text[i:], isWordRune)
+		if start < 0 {
+			break
+		}
+		start += i
+		end := strings.IndexFunc(text[start:], func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		spans = append(spans, span{start, end})
+		words = append(words, strings.ToLower(text[start:end]))
+		i = end
+	}
+
+	marked := make([]bool, len(words))
+	for _, t := range q.Terms {
+		for _, at := range t.matches(words) {
+			for k := range t.Words {
+				marked[at+k] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, s := range spans {
+		if !marked[i] {
+			continue
+		}
+		b.WriteString(text[last:s.start])
+		b.WriteString(HighlightStart)
+		b.WriteString(text[s.start:s.end])
+		b.WriteString(HighlightStop)
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// matches returns the indexes in words at which t starts.
+func (t Term) matches(words []string) []int {
+	var at []int
+	for i := 0; i+len(t.Words) <= len(words); i++ {
+		ok := true
+		for j, w := range t.Words {
+			last := j == len(t.Words)-1
+			if words[i+j] != w && !(last && t.Prefix && strings.HasPrefix(words[i+j], w)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			at = append(at, i)
+		}
+	}
+	return at
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/config"
+	"github.com/globallstudent/todo-project-go/internal/handlers"
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+	"github.com/globallstudent/todo-project-go/internal/mailer"
+	"github.com/globallstudent/todo-project-go/internal/middleware"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/oidc"
+	"github.com/globallstudent/todo-project-go/internal/passhash"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/services"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// NewRouter wires the services and handlers on top of store, signing tokens
+// with keys, hashing passwords with passwords once they pass policy and
+// sending emails with mail, and returns the gin engine serving the API.
+// Users may also log in with the OpenID Connect provider of rp, unless it is
+// nil. It does not start listening, so tests can drive the engine directly
+// through httptest.
+func NewRouter(cfg *config.Config, store *repositories.Store, keys *keyset.Keyset, passwords *passhash.Hasher,
+	policy *passpolicy.Policy, mail mailer.Mailer, rp *oidc.RelyingParty, oidcSettings services.OIDCSettings) *gin.Engine {
+	revocationService := services.NewRevocationService(store.TokenRevocations, cfg.AccessTokenTTL)
+	throttleService := services.NewLoginThrottleService(store.LoginThrottles, LoginLimits(cfg))
+	mfaService := services.NewMFAService(store.MFA, throttleService, cfg.MFAIssuer)
+	authService := services.NewAuthService(store.Users, store.Projects, store.RefreshTokens, revocationService,
+		mfaService, throttleService, keys, passwords, policy, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	accessTokenService := services.NewAccessTokenService(store.AccessTokens, store.Users)
+	policyService := services.NewPolicyService(store.Roles, store.Users)
+	todoService := services.NewTodoService(store.Todos, store.Users, store.Tags, store.Projects, store.Dependencies,
+		policyService)
+	tagService := services.NewTagService(store.Tags)
+	projectService := services.NewProjectService(store.Projects)
+	emailService := services.NewEmailService(store.Users, authService, mail, passwords, policy, throttleService,
+		keys, services.EmailSettings{
+			AppURL:           cfg.AppURL,
+			ResetTTL:         cfg.PasswordResetTTL,
+			VerificationTTL:  cfg.EmailVerificationTTL,
+			ResetMinEmailAge: cfg.PasswordResetMinEmailAge,
+		})
+	userService := services.NewUserService(store.Users, authService, emailService, passwords, policy,
+		throttleService)
+	adminService := services.NewAdminService(store.Users, policyService, authService, mfaService, throttleService)
+
+	authHandler := handlers.NewAuthHandler(authService)
+	todoHandler := handlers.NewTodoHandler(todoService)
+	tagHandler := handlers.NewTagHandler(tagService, policyService)
+	projectHandler := handlers.NewProjectHandler(projectService, todoService, policyService)
+	adminHandler := handlers.NewAdminHandler(adminService, policyService)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	userHandler := handlers.NewUserHandler(userService)
+	emailHandler := handlers.NewEmailHandler(emailService)
+	jwksHandler := handlers.NewJWKSHandler(keys)
+
+	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Invalid TRUSTED_PROXIES, trusting none: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
+	auth := middleware.AuthMiddleware(authService, accessTokenService)
+	session := middleware.RequireSession()
+	verified := middleware.RequireVerifiedEmail(cfg.RequireVerifiedEmail)
+	require := func(permission string) gin.HandlerFunc {
+		return middleware.RequirePermission(policyService, permission)
+	}
+
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+	r.POST("/register", authHandler.Register)
+	r.POST("/login", authHandler.Login)
+	r.POST("/login/mfa", authHandler.LoginMFA)
+	r.POST("/token/refresh", authHandler.Refresh)
+	r.POST("/logout", auth, session, authHandler.Logout)
+	r.POST("/logout/all", auth, session, authHandler.LogoutAll)
+	if rp != nil {
+		oidcService := services.NewOIDCService(rp, store.Users, store.Identities, store.Projects, policyService,
+			authService, keys, oidcSettings)
+		oidcHandler := handlers.NewOIDCHandler(oidcService, strings.HasPrefix(oidcRedirectURL(cfg), "https://"))
+		r.GET("/oidc/login", oidcHandler.Login)
+		r.GET("/oidc/callback", oidcHandler.Callback)
+	}
+	r.POST("/password/forgot", emailHandler.ForgotPassword)
+	r.POST("/password/reset", emailHandler.ResetPassword)
+	r.GET("/email/verify", emailHandler.VerifyEmail)
+	r.POST("/email/verify", emailHandler.VerifyEmail)
+
+	me := r.Group("/me")
+	me.Use(auth, session)
+	{
+		me.GET("", userHandler.GetProfile)
+		me.PATCH("", userHandler.UpdateProfile)
+		me.DELETE("", userHandler.DeleteAccount)
+		me.PUT("/password", userHandler.ChangePassword)
+		me.PUT("/username", userHandler.ChangeUsername)
+		me.POST("/email/verification", emailHandler.SendVerification)
+	}
+
+	accessTokens := r.Group("/tokens")
+	accessTokens.Use(auth, session)
+	{
+		accessTokens.POST("", verified, accessTokenHandler.CreateAccessToken)
+		accessTokens.GET("", accessTokenHandler.GetAccessTokens)
+		accessTokens.DELETE("/:id", accessTokenHandler.RevokeAccessToken)
+	}
+
+	mfa := r.Group("/mfa")
+	mfa.Use(auth, session)
+	{
+		mfa.GET("", mfaHandler.GetMFAStatus)
+		mfa.POST("/totp", mfaHandler.EnrollTOTP)
+		mfa.POST("/totp/confirm", mfaHandler.ConfirmTOTP)
+		mfa.POST("/totp/disable", mfaHandler.DisableTOTP)
+		mfa.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+	}
+
+	protected := r.Group("/todos")
+	protected.Use(auth, middleware.RequireScope("todos"))
+	{
+		protected.POST("", todoHandler.CreateTodo)
+		protected.GET("/search", todoHandler.SearchTodos)
+		protected.GET("/:id", todoHandler.GetTodo)
+		protected.GET("/:id/tree", todoHandler.GetTodoTree)
+		protected.GET("", todoHandler.GetTodos)
+		protected.PUT("/:id", todoHandler.UpdateTodo)
+		protected.DELETE("/:id", todoHandler.DeleteTodo)
+		protected.POST("/:id/tags/:tag_id", todoHandler.AttachTag)
+		protected.DELETE("/:id/tags/:tag_id", todoHandler.DetachTag)
+		protected.POST("/:id/blockers/:blocker_id", todoHandler.AddBlocker)
+		protected.DELETE("/:id/blockers/:blocker_id", todoHandler.RemoveBlocker)
+		protected.GET("/:id/occurrences", todoHandler.GetOccurrences)
+		protected.POST("/:id/recurrence/skip", todoHandler.SkipOccurrence)
+		protected.POST("/:id/recurrence/end", todoHandler.EndSeries)
+	}
+
+	tags := r.Group("/tags")
+	tags.Use(auth, middleware.RequireScope("tags"))
+	{
+		tags.POST("", tagHandler.CreateTag)
+		tags.GET("", tagHandler.GetTags)
+		tags.PUT("/:id", tagHandler.UpdateTag)
+		tags.DELETE("/:id", tagHandler.DeleteTag)
+	}
+
+	projects := r.Group("/projects")
+	projects.Use(auth, middleware.RequireScope("projects"))
+	{
+		projects.POST("", projectHandler.CreateProject)
+		projects.GET("", projectHandler.GetProjects)
+		projects.GET("/:id", projectHandler.GetProject)
+		projects.GET("/:id/todos", projectHandler.GetProjectTodos)
+		projects.PUT("/:id", projectHandler.UpdateProject)
+		projects.DELETE("/:id", projectHandler.DeleteProject)
+	}
+
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	admin := r.Group("/admin")
+	admin.Use(auth, middleware.RequireScope("admin"))
+	{
+		admin.GET("/todos", require(models.PermTodosReadAny), todoHandler.ListAllTodos)
+		admin.GET("/todos/stats", require(models.PermTodosReadAny), todoHandler.GetTodoStats)
+		admin.GET("/users/:id/todos", require(models.PermTodosReadAny), todoHandler.GetUserTodos)
+		admin.GET("/users", require(models.PermUsersRead), adminHandler.ListUsers)
+		admin.GET("/users/:id", require(models.PermUsersRead), adminHandler.GetUser)
+		admin.POST("/users/:id/promote", require(models.PermRolesManage), adminHandler.PromoteUser)
+		admin.POST("/users/:id/demote", require(models.PermRolesManage), adminHandler.DemoteUser)
+		admin.PUT("/users/:id/role", require(models.PermRolesManage), adminHandler.SetUserRole)
+		admin.POST("/users/:id/disable", require(models.PermUsersManage), adminHandler.DisableUser)
+		admin.POST("/users/:id/enable", require(models.PermUsersManage), adminHandler.EnableUser)
+		admin.DELETE("/users/:id", require(models.PermUsersManage), adminHandler.DeleteUser)
+		admin.DELETE("/users/:id/mfa", require(models.PermUsersManage), adminHandler.ResetUserMFA)
+		admin.POST("/users/:id/unlock", require(models.PermUsersManage), adminHandler.UnlockUser)
+		admin.GET("/lockouts", require(models.PermUsersRead), adminHandler.ListLockouts)
+		admin.GET("/permissions", require(models.PermRolesManage), adminHandler.ListPermissions)
+		admin.GET("/roles", require(models.PermRolesManage), adminHandler.ListRoles)
+		admin.GET("/roles/:name", require(models.PermRolesManage), adminHandler.GetRole)
+		admin.PUT("/roles/:name", require(models.PermRolesManage), adminHandler.SaveRole)
+		admin.DELETE("/roles/:name", require(models.PermRolesManage), adminHandler.DeleteRole)
+	}
+
+	return r
+}
+
+// LoginLimits returns the login throttling configured in cfg.
+func LoginLimits(cfg *config.Config) services.LoginLimits {
+	return services.LoginLimits{
+		MaxFailures:   cfg.LoginMaxFailures,
+		MaxIPFailures: cfg.LoginMaxIPFailures,
+		Lockout:       cfg.LoginLockout,
+		MaxLockout:    cfg.LoginMaxLockout,
+	}
+}
+
+// PasswordHasher returns the password hasher configured in cfg.
+func PasswordHasher(cfg *config.Config) (*passhash.Hasher, error) {
+	return passhash.Configure(cfg.PasswordHash, passhash.Params{
+		Argon2Memory:      cfg.Argon2Memory,
+		Argon2Time:        cfg.Argon2Time,
+		Argon2Parallelism: cfg.Argon2Parallelism,
+		BcryptCost:        cfg.BcryptCost,
+	})
+}
+
+// Mailer returns the mailer configured in cfg, or nil if MAILER is not set.
+// Without a mailer, password resets and email verification are
+// unavailable, so REQUIRE_VERIFIED_EMAIL needs one.
+func Mailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "":
+		if cfg.RequireVerifiedEmail {
+			return nil, fmt.Errorf("REQUIRE_VERIFIED_EMAIL needs MAILER to be set")
+		}
+		log.Printf("MAILER is not set: password resets and email verification are unavailable")
+		return nil, nil
+	case "log":
+		log.Printf("MAILER=log writes password reset links to the log; use it for development only")
+		return mailer.Log{}, nil
+	case "file":
+		return mailer.NewFile(cfg.MailDir, cfg.MailFrom), nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("MAILER=smtp needs SMTP_HOST")
+		}
+		return mailer.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q, want log, file or smtp", cfg.Mailer)
+	}
+}
+
+// OIDC returns the OpenID Connect relying party and identity settings
+// configured in cfg, or a nil relying party if OIDC_ISSUER is not set.
+func OIDC(cfg *config.Config) (*oidc.RelyingParty, services.OIDCSettings, error) {
+	settings := services.OIDCSettings{
+		GroupsClaim:   cfg.OIDCGroupsClaim,
+		AutoProvision: cfg.OIDCAutoProvision,
+		LinkByEmail:   cfg.OIDCLinkByEmail,
+	}
+	if cfg.OIDCIssuer == "" {
+		return nil, settings, nil
+	}
+	if cfg.OIDCClientID == "" {
+		return nil, settings, fmt.Errorf("OIDC_ISSUER needs OIDC_CLIENT_ID")
+	}
+	// Group names may contain "=", as LDAP DNs do, so the role follows the
+	// last one.
+	for _, item := range cfg.OIDCRoleMapping {
+		i := strings.LastIndex(item, "=")
+		if i <= 0 || i == len(item)-1 {
+			return nil, settings, fmt.Errorf("invalid OIDC_ROLE_MAPPING item %q, want group=role", item)
+		}
+		settings.RoleMappings = append(settings.RoleMappings, services.RoleMapping{Group: item[:i], Role: item[i+1:]})
+	}
+
+	scopes := cfg.OIDCScopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	rp := oidc.New(oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  oidcRedirectURL(cfg),
+		Scopes:       scopes,
+	})
+	return rp, settings, nil
+}
+
+// oidcRedirectURL returns where the OpenID Connect provider sends users
+// back to, the callback under APP_URL unless configured otherwise.
+func oidcRedirectURL(cfg *config.Config) string {
+	if cfg.OIDCRedirectURL != "" {
+		return cfg.OIDCRedirectURL
+	}
+	return strings.TrimRight(cfg.AppURL, "/") + "/oidc/callback"
+}
+
+// PasswordPolicy returns the password policy configured in cfg, loading its
+// breached password corpus.
+func PasswordPolicy(cfg *config.Config) (*passpolicy.Policy, error) {
+	policy := &passpolicy.Policy{
+		MinLength:      cfg.PasswordMinLength,
+		MaxLength:      cfg.PasswordMaxLength,
+		MinClasses:     cfg.PasswordMinClasses,
+		RejectUsername: cfg.PasswordRejectUsername,
+	}
+	if cfg.PasswordHash == "bcrypt" && policy.MaxLength > 72 {
+		log.Printf("PASSWORD_MAX_LENGTH %d is more than bcrypt hashes, using 72", policy.MaxLength)
+		policy.MaxLength = 72
+	}
+	if cfg.PasswordBreachedCorpus != "" {
+		corpus, err := passpolicy.LoadCorpus(cfg.PasswordBreachedCorpus)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded %d breached passwords", corpus.Len())
+		policy.Breached = corpus
+	}
+	return policy, nil
+}
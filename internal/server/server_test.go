@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/globallstudent/todo-project-go/internal/config"
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/services"
+)
+
+// newTestRouter returns the API on an in-memory store, hashing passwords
+// with the cheapest bcrypt cost to keep the tests fast.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	cfg := &config.Config{
+		AccessTokenTTL:     15 * time.Minute,
+		RefreshTokenTTL:    time.Hour,
+		MFAIssuer:          "Todo API",
+		LoginMaxFailures:   5,
+		LoginMaxIPFailures: 20,
+		LoginLockout:       time.Minute,
+		LoginMaxLockout:    time.Hour,
+		PasswordHash:       "bcrypt",
+		BcryptCost:         4,
+		PasswordMinLength:  8,
+		PasswordMaxLength:  72,
+		PasswordMinClasses: 1,
+		AppURL:             "http://localhost:8080",
+	}
+	passwords, err := PasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := PasswordPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := repositories.NewMemoryStore()
+	t.Cleanup(func() { store.Close() })
+	return NewRouter(cfg, store, keyset.NewHMAC("test-secret"), passwords, policy, nil, nil, services.OIDCSettings{})
+}
+
+// do sends a request with body encoded as JSON, unless it is nil, and
+// decodes the response into out, unless it is nil.
+func do(t *testing.T, r http.Handler, method, path, token string, body, out any) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+type todoJSON struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	r := newTestRouter(t)
+	creds := map[string]string{"username": "alice", "password": "correct horse"}
+
+	if code := do(t, r, http.MethodPost, "/register", "", creds, nil); code != http.StatusCreated {
+		t.Fatalf("register: status %d, want %d", code, http.StatusCreated)
+	}
+	if code := do(t, r, http.MethodPost, "/register", "", creds, nil); code != http.StatusBadRequest {
+		t.Errorf("register twice: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	wrong := map[string]string{"username": "alice", "password": "battery staple"}
+	if code := do(t, r, http.MethodPost, "/login", "", wrong, nil); code != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password: status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	long := map[string]string{"username": strings.Repeat("a", 51), "password": "correct horse"}
+	if code := do(t, r, http.MethodPost, "/login", "", long, nil); code != http.StatusBadRequest {
+		t.Errorf("login with a 51 character username: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if code := do(t, r, http.MethodPost, "/login", "", creds, &tokens); code != http.StatusOK {
+		t.Fatalf("login: status %d, want %d", code, http.StatusOK)
+	}
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login returned %+v, want both tokens", tokens)
+	}
+
+	var me struct {
+		Username string `json:"username"`
+	}
+	if code := do(t, r, http.MethodGet, "/me", tokens.Token, nil, &me); code != http.StatusOK || me.Username != "alice" {
+		t.Errorf("GET /me: status %d, username %q", code, me.Username)
+	}
+	if code := do(t, r, http.MethodGet, "/me", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /me without a token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestTodoCRUD(t *testing.T) {
+	r := newTestRouter(t)
+	alice := registerAndLogin(t, r, "alice")
+	bob := registerAndLogin(t, r, "bob")
+
+	var created todoJSON
+	if code := do(t, r, http.MethodPost, "/todos", alice, map[string]any{"title": "buy milk"}, &created); code != http.StatusCreated {
+		t.Fatalf("create: status %d, want %d", code, http.StatusCreated)
+	}
+	if created.ID == 0 || created.Title != "buy milk" || created.Completed {
+		t.Fatalf("create returned %+v", created)
+	}
+	if code := do(t, r, http.MethodPost, "/todos", alice, map[string]any{}, nil); code != http.StatusBadRequest {
+		t.Errorf("create without a title: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	path := "/todos/" + strconv.Itoa(created.ID)
+	var got todoJSON
+	if code := do(t, r, http.MethodGet, path, alice, nil, &got); code != http.StatusOK || got != created {
+		t.Errorf("get: status %d, todo %+v, want %+v", code, got, created)
+	}
+	if code := do(t, r, http.MethodGet, path, bob, nil, nil); code != http.StatusForbidden {
+		t.Errorf("get by another user: status %d, want %d", code, http.StatusForbidden)
+	}
+
+	var list struct {
+		Todos []todoJSON `json:"todos"`
+	}
+	if code := do(t, r, http.MethodGet, "/todos", alice, nil, &list); code != http.StatusOK || len(list.Todos) != 1 {
+		t.Errorf("list: status %d, %d todos, want 1", code, len(list.Todos))
+	}
+	list.Todos = nil
+	if code := do(t, r, http.MethodGet, "/todos", bob, nil, &list); code != http.StatusOK || len(list.Todos) != 0 {
+		t.Errorf("list by another user: status %d, %d todos, want 0", code, len(list.Todos))
+	}
+
+	var updated todoJSON
+	update := map[string]any{"title": "buy oat milk", "completed": true}
+	if code := do(t, r, http.MethodPut, path, alice, update, &updated); code != http.StatusOK {
+		t.Fatalf("update: status %d, want %d", code, http.StatusOK)
+	}
+	if updated.Title != "buy oat milk" || !updated.Completed {
+		t.Errorf("update returned %+v", updated)
+	}
+	if code := do(t, r, http.MethodPut, path, bob, update, nil); code != http.StatusForbidden {
+		t.Errorf("update by another user: status %d, want %d", code, http.StatusForbidden)
+	}
+
+	if code := do(t, r, http.MethodDelete, path, bob, nil, nil); code != http.StatusForbidden {
+		t.Errorf("delete by another user: status %d, want %d", code, http.StatusForbidden)
+	}
+	if code := do(t, r, http.MethodDelete, path, alice, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d, want %d", code, http.StatusOK)
+	}
+	if code := do(t, r, http.MethodGet, path, alice, nil, nil); code != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func registerAndLogin(t *testing.T, r http.Handler, username string) string {
+	t.Helper()
+	creds := map[string]string{"username": username, "password": "correct horse"}
+	if code := do(t, r, http.MethodPost, "/register", "", creds, nil); code != http.StatusCreated {
+		t.Fatalf("register %s: status %d", username, code)
+	}
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	if code := do(t, r, http.MethodPost, "/login", "", creds, &tokens); code != http.StatusOK {
+		t.Fatalf("login %s: status %d", username, code)
+	}
+	return tokens.Token
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+// AccessTokenPrefix starts every personal access token, which tells them
+// apart from JWTs and makes leaked tokens easy to scan for.
+const AccessTokenPrefix = "tdp_"
+
+// accessTokenTouchInterval is how stale last_used_at may get before a use
+// of the token updates it, so busy scripts do not write on every request.
+const accessTokenTouchInterval = time.Minute
+
+var (
+	// ErrAccessTokenNotFound is returned for personal access tokens that do
+	// not exist or belong to someone else.
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	// ErrInvalidTokenName is returned for empty or overlong token names.
+	ErrInvalidTokenName = errors.New("token name must be 1 to 100 characters")
+	// ErrInvalidScope is returned for tokens without scopes or with unknown
+	// ones.
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidExpiry is returned for expiry times in the past.
+	ErrInvalidExpiry = errors.New("expires_at must be in the future")
+	// ErrAccessTokenExpired is returned when an expired personal access
+	// token is used.
+	ErrAccessTokenExpired = errors.New("access token has expired")
+)
+
+// AccessTokenService manages personal access tokens, which let scripts act
+// as a user within the token's scopes.
+type AccessTokenService struct {
+	repo     repositories.AccessTokenRepository
+	userRepo repositories.UserRepository
+}
+
+func NewAccessTokenService(repo repositories.AccessTokenRepository, userRepo repositories.UserRepository) *AccessTokenService {
+	return &AccessTokenService{repo: repo, userRepo: userRepo}
+}
+
+// CreateToken creates a token for the user. The returned token carries the
+// secret in Token; it is not stored and cannot be shown again.
+func (s *AccessTokenService) CreateToken(ctx context.Context, userID int, name string, scopes []string,
+	expiresAt *time.Time) (*models.PersonalAccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, ErrInvalidTokenName
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return nil, fmt.Errorf("%w %q", ErrInvalidScope, scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	secret, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Token:     AccessTokenPrefix + secret,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		ExpiresAt: expiresAt,
+	}
+	token.TokenHash = utils.HashToken(token.Token)
+	if err := s.repo.CreateAccessToken(ctx, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ListTokens returns the user's tokens, newest first.
+func (s *AccessTokenService) ListTokens(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	tokens, err := s.repo.ListAccessTokens(ctx, userID)
+	if tokens == nil {
+		tokens = []*models.PersonalAccessToken{}
+	}
+	return tokens, err
+}
+
+// RevokeToken deletes one of the user's tokens.
+func (s *AccessTokenService) RevokeToken(ctx context.Context, userID, id int) error {
+	tokens, err := s.repo.ListAccessTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ID == id {
+			return s.repo.DeleteAccessToken(ctx, id)
+		}
+	}
+	return ErrAccessTokenNotFound
+}
+
+// Authenticate resolves a personal access token to the token and its user,
+// recording the use. It rejects unknown and expired tokens and tokens of
+// disabled accounts.
+func (s *AccessTokenService) Authenticate(ctx context.Context, secret string) (*models.User, *models.PersonalAccessToken, error) {
+	token, err := s.repo.FindAccessTokenByHash(ctx, utils.HashToken(secret))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return nil, nil, ErrAccessTokenExpired
+	}
+
+	user, err := s.userRepo.FindUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= accessTokenTouchInterval {
+		if err := s.repo.TouchAccessToken(ctx, token.ID, now); err != nil {
+			return nil, nil, err
+		}
+		token.LastUsedAt = &now
+	}
+	return user, token, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+var (
+	// ErrLastAdmin is returned when demoting, disabling or deleting the only
+	// enabled admin, which would leave nobody able to manage users.
+	ErrLastAdmin = errors.New("cannot remove the last active admin")
+	// ErrAdminAccount is returned when someone other than an admin changes
+	// an admin account or hands out the admin role.
+	ErrAdminAccount = fmt.Errorf("%w: only admins can manage admin accounts", ErrForbidden)
+	// ErrUnknownRole is returned when assigning a role that does not exist.
+	ErrUnknownRole = errors.New("unknown role")
+)
+
+// AdminService manages user accounts on behalf of callers holding
+// users:manage or roles:manage.
+type AdminService struct {
+	userRepo    repositories.UserRepository
+	policy      *PolicyService
+	authService *AuthService
+	mfa         *MFAService
+	throttle    *LoginThrottleService
+}
+
+func NewAdminService(userRepo repositories.UserRepository, policy *PolicyService, authService *AuthService,
+	mfa *MFAService, throttle *LoginThrottleService) *AdminService {
+	return &AdminService{userRepo: userRepo, policy: policy, authService: authService, mfa: mfa, throttle: throttle}
+}
+
+// ListUsers returns the users matching filter, one page at a time.
+func (s *AdminService) ListUsers(ctx context.Context, filter models.UserFilter) ([]*models.User, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+	users, err := s.userRepo.ListUsers(ctx, filter)
+	if users == nil {
+		users = []*models.User{}
+	}
+	return users, err
+}
+
+func (s *AdminService) GetUser(ctx context.Context, id int) (*models.User, error) {
+	return s.userRepo.FindUserByID(ctx, id)
+}
+
+// SetRole assigns role to user.
+func (s *AdminService) SetRole(ctx context.Context, actor *models.Principal, user *models.User, role string) error {
+	if err := checkAdminAccount(actor, user); err != nil {
+		return err
+	}
+	if role == models.RoleAdmin && actor.Role != models.RoleAdmin {
+		return ErrAdminAccount
+	}
+	if _, err := s.policy.GetRole(ctx, role); err != nil {
+		if errors.Is(err, ErrRoleNotFound) {
+			return fmt.Errorf("%w %q", ErrUnknownRole, role)
+		}
+		return err
+	}
+	if role != models.RoleAdmin {
+		if err := keepAnAdmin(ctx, s.userRepo, user); err != nil {
+			return err
+		}
+	}
+	user.Role = role
+	return s.userRepo.UpdateUser(ctx, user)
+}
+
+// SetDisabled disables or re-enables user. Disabling also ends all of the
+// user's sessions.
+func (s *AdminService) SetDisabled(ctx context.Context, actor *models.Principal, user *models.User, disabled bool) error {
+	if err := checkAdminAccount(actor, user); err != nil {
+		return err
+	}
+	if disabled {
+		if err := keepAnAdmin(ctx, s.userRepo, user); err != nil {
+			return err
+		}
+	}
+	user.Disabled = disabled
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	if !disabled {
+		return nil
+	}
+	return s.authService.LogoutAll(ctx, user.ID)
+}
+
+// DeleteUser deletes user together with everything they own.
+func (s *AdminService) DeleteUser(ctx context.Context, actor *models.Principal, user *models.User) error {
+	if err := checkAdminAccount(actor, user); err != nil {
+		return err
+	}
+	if err := keepAnAdmin(ctx, s.userRepo, user); err != nil {
+		return err
+	}
+	return s.userRepo.DeleteUser(ctx, user.ID)
+}
+
+// ResetMFA turns off two-factor authentication for user, who can then log
+// in with their password alone and enroll again.
+func (s *AdminService) ResetMFA(ctx context.Context, actor *models.Principal, user *models.User) error {
+	if err := checkAdminAccount(actor, user); err != nil {
+		return err
+	}
+	return s.mfa.Reset(ctx, user.ID)
+}
+
+// UnlockUser lifts a lockout of user caused by failed logins. Lockouts of
+// the client IPs involved stay in place.
+func (s *AdminService) UnlockUser(ctx context.Context, user *models.User) error {
+	return s.throttle.Unlock(ctx, user.Username)
+}
+
+// ListLockouts returns the lockout events matching filter, newest first.
+func (s *AdminService) ListLockouts(ctx context.Context, filter models.LockoutFilter) ([]*models.LockoutEvent, error) {
+	return s.throttle.ListLockouts(ctx, filter)
+}
+
+// keepAnAdmin fails with ErrLastAdmin if user is the only enabled admin.
+func keepAnAdmin(ctx context.Context, userRepo repositories.UserRepository, user *models.User) error {
+	if user.Role != models.RoleAdmin || user.Disabled {
+		return nil
+	}
+	disabled := false
+	admins, err := userRepo.ListUsers(ctx, models.UserFilter{Role: models.RoleAdmin, Disabled: &disabled, Limit: 2})
+	if err != nil {
+		return err
+	}
+	for _, a := range admins {
+		if a.ID != user.ID {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+// checkAdminAccount fails with ErrAdminAccount if user is an admin and actor
+// is not, so that lesser roles cannot lock admins out.
+func checkAdminAccount(actor *models.Principal, user *models.User) error {
+	if user.Role == models.RoleAdmin && actor.Role != models.RoleAdmin {
+		return ErrAdminAccount
+	}
+	return nil
+}
@@ -3,33 +3,142 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
+	"time"
 
+	"github.com/globallstudent/todo-project-go/internal/keyset"
 	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/passhash"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
 	"github.com/globallstudent/todo-project-go/internal/repositories"
 	"github.com/globallstudent/todo-project-go/internal/utils"
 )
 
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are unknown,
+	// expired or revoked.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrInvalidCredentials is returned for logins with an unknown username
+	// or a wrong password.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrRefreshTokenReused is returned when a refresh token is presented a
+	// second time. The whole token family is revoked when this happens, since
+	// either the client or an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, please log in again")
+	// ErrInvalidToken is returned for access tokens that fail validation or
+	// belong to a deleted account.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenRevoked is returned for access tokens revoked by a logout.
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrUserDisabled is returned when a disabled account logs in or uses a
+	// token.
+	ErrUserDisabled = errors.New("account is disabled")
+	// ErrInvalidMFAToken is returned for MFA tokens that are invalid, expired
+	// or used up by wrong codes.
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token, please log in again")
+)
+
+const (
+	// mfaTokenTTL is how long a user has to enter their second factor after
+	// entering their password.
+	mfaTokenTTL = 5 * time.Minute
+	// maxMFAAttempts is how many wrong codes one MFA token allows before the
+	// password has to be entered again.
+	maxMFAAttempts = 5
+)
+
 type AuthService struct {
-	userRepo  *repositories.UserRepository
-	jwtSecret string
+	userRepo    repositories.UserRepository
+	projectRepo repositories.ProjectRepository
+	refreshRepo repositories.RefreshTokenRepository
+	revocations *RevocationService
+	mfa         *MFAService
+	throttle    *LoginThrottleService
+	keys        *keyset.Keyset
+	passwords   *passhash.Hasher
+	policy      *passpolicy.Policy
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+}
+
+func NewAuthService(userRepo repositories.UserRepository, projectRepo repositories.ProjectRepository,
+	refreshRepo repositories.RefreshTokenRepository, revocations *RevocationService, mfa *MFAService,
+	throttle *LoginThrottleService, keys *keyset.Keyset, passwords *passhash.Hasher,
+	policy *passpolicy.Policy, accessTTL, refreshTTL time.Duration) *AuthService {
+	return &AuthService{
+		userRepo:    userRepo,
+		projectRepo: projectRepo,
+		refreshRepo: refreshRepo,
+		revocations: revocations,
+		mfa:         mfa,
+		throttle:    throttle,
+		keys:        keys,
+		passwords:   passwords,
+		policy:      policy,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// Register creates a regular user account. Admins are created with
+// CreateAdmin or promoted by another admin. Passwords failing the password
+// policy are rejected with a *passpolicy.Error.
+func (s *AuthService) Register(ctx context.Context, username, password string) (*models.User, error) {
+	return s.createUser(ctx, username, password, models.RoleUser)
+}
+
+// CreateAdmin creates an admin account, or promotes and re-enables the user
+// if the username is taken, leaving their password unchanged. It is meant
+// for the create-admin command, run by someone with access to the server.
+func (s *AuthService) CreateAdmin(ctx context.Context, username, password string) (*models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.FindUserByUsername(ctx, username)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return s.createUser(ctx, username, password, models.RoleAdmin)
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.Role = models.RoleAdmin
+	user.Disabled = false
+	return user, s.userRepo.UpdateUser(ctx, user)
 }
 
-func NewAuthService(userRepo *repositories.UserRepository, jwtSecret string) *AuthService {
-	return &AuthService{userRepo: userRepo, jwtSecret: jwtSecret}
+// BootstrapAdmin creates an admin account on startup. Unlike CreateAdmin it
+// never promotes an existing user, since anyone could have registered the
+// configured username first; it fails with ErrUsernameTaken instead.
+func (s *AuthService) BootstrapAdmin(ctx context.Context, username, password string) (*models.User, error) {
+	return s.createUser(ctx, username, password, models.RoleAdmin)
 }
 
-func (s *AuthService) Register(ctx context.Context, username, password, role string) (*models.User, error) {
+// HasActiveAdmin reports whether any enabled admin account exists.
+func (s *AuthService) HasActiveAdmin(ctx context.Context) (bool, error) {
+	disabled := false
+	admins, err := s.userRepo.ListUsers(ctx, models.UserFilter{Role: models.RoleAdmin, Disabled: &disabled, Limit: 1})
+	return len(admins) > 0, err
+}
 
-	if username == "" || password == "" {
-		return nil, errors.New("username and password are required")
+func (s *AuthService) createUser(ctx context.Context, username, password, role string) (*models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+	if err := s.policy.Check(password, username); err != nil {
+		return nil, err
 	}
 
-	_, err := s.userRepo.FindUserByUsername(ctx, username)
+	_, err = s.userRepo.FindUserByUsername(ctx, username)
 	if err == nil {
-		return nil, errors.New("username already exists")
+		return nil, ErrUsernameTaken
 	}
 
-	hashedPassword, err := utils.HashPassword(password)
+	hashedPassword, err := s.passwords.Hash(password)
 	if err != nil {
 		return nil, err
 	}
@@ -39,27 +148,308 @@ func (s *AuthService) Register(ctx context.Context, username, password, role str
 		Password: hashedPassword,
 		Role:     role,
 	}
-	if err := s.userRepo.CreateUser(ctx, user); err != nil {
+	err = s.userRepo.CreateUser(ctx, user)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, ErrUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ensureInbox(ctx, s.projectRepo, user.ID); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, username, password string) (string, error) {
+// Login checks the user's credentials, coming from the client at ip, and
+// starts a new refresh token family. Accounts with two-factor
+// authentication get a challenge instead of tokens, to be completed with
+// LoginMFA. Too many failed logins lock out the username or ip for a while,
+// failing with a *LoginLockedError. A password hash made with an outdated
+// algorithm or parameters is replaced once the password checks out.
+func (s *AuthService) Login(ctx context.Context, username, password, ip string) (*models.TokenPair, *models.MFAChallenge, error) {
+	if err := s.throttle.Check(ctx, username, ip); err != nil {
+		return nil, nil, err
+	}
+
 	user, err := s.userRepo.FindUserByUsername(ctx, username)
+	if errors.Is(err, repositories.ErrNotFound) {
+		// Hash anyway, so that unknown usernames take as long to refuse as
+		// wrong passwords.
+		_, _ = s.passwords.Hash(password)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if err != nil || !s.checkPassword(ctx, user, password) {
+		if err := s.throttle.Fail(ctx, username, ip); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	challenge, err := s.mfaChallenge(ctx, user, time.Time{})
+	if err != nil || challenge != nil {
+		return nil, challenge, err
+	}
+
+	if err := s.throttle.Succeed(ctx, user.Username); err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.startSession(ctx, user, time.Time{})
+	return tokens, nil, err
+}
+
+// mfaChallenge returns the challenge for the second factor of a login of
+// user, or nil if they have not turned on two-factor authentication.
+// ssoAuthTime is as for startSession.
+func (s *AuthService) mfaChallenge(ctx context.Context, user *models.User, ssoAuthTime time.Time) (*models.MFAChallenge, error) {
+	mfaEnabled, err := s.mfa.Enabled(ctx, user.ID)
+	if err != nil || !mfaEnabled {
+		return nil, err
+	}
+	mfaToken, err := utils.GenerateMFAToken(user.ID, ssoAuthTime, s.keys, mfaTokenTTL)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, err
 	}
+	return &models.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresIn:   int(mfaTokenTTL.Seconds()),
+	}, nil
+}
 
-	if !utils.CheckPasswordHash(password, user.Password) {
-		return "", errors.New("invalid credentials")
+// checkPassword reports whether password is the user's, upgrading its hash
+// if it is outdated. A failed upgrade is only logged, as the old hash keeps
+// working. Users without a password never match.
+func (s *AuthService) checkPassword(ctx context.Context, user *models.User, password string) bool {
+	if !hasPassword(user) {
+		_, _ = s.passwords.Hash(password)
+		return false
 	}
+	ok, rehash, err := s.passwords.Verify(password, user.Password)
+	if err != nil {
+		log.Printf("Failed to verify password of user %d: %v", user.ID, err)
+		return false
+	}
+	if !ok || !rehash {
+		return ok
+	}
+	hash, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.userRepo.UpdatePassword(ctx, user.ID, hash)
+	}
+	if err != nil {
+		log.Printf("Failed to upgrade password hash of user %d: %v", user.ID, err)
+		return true
+	}
+	user.Password = hash
+	return true
+}
 
-	token, err := utils.GenerateJWT(user.ID, user.Username, user.Role, s.jwtSecret)
+// LoginMFA completes a login challenged for a second factor. code is a code
+// from the user's authenticator app or one of their recovery codes. An MFA
+// token completes one login and stops working after maxMFAAttempts codes,
+// which is tracked in storage so that it holds across instances. Wrong codes
+// also count as failed logins of the user from ip.
+func (s *AuthService) LoginMFA(ctx context.Context, mfaToken, code, ip string) (*models.TokenPair, error) {
+	userID, claims, err := utils.ValidateMFAToken(mfaToken, s.keys)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.userRepo.FindUserByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidMFAToken
+	}
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	if err := s.throttle.Check(ctx, user.Username, ip); err != nil {
+		return nil, err
+	}
+	if err := s.mfa.countAttempt(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.Verify(ctx, user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.throttle.Fail(ctx, user.Username, ip); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	if err := s.mfa.useToken(ctx, claims.ID); err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Succeed(ctx, user.Username); err != nil {
+		return nil, err
+	}
+	var ssoAuthTime time.Time
+	if claims.SSOAuthTime != nil {
+		ssoAuthTime = claims.SSOAuthTime.Time
+	}
+	return s.startSession(ctx, user, ssoAuthTime)
+}
+
+// startSession issues the first token pair of a new refresh token family.
+// ssoAuthTime is when the user authenticated at the single sign-on provider
+// for logins through it, and zero otherwise.
+func (s *AuthService) startSession(ctx context.Context, user *models.User, ssoAuthTime time.Time) (*models.TokenPair, error) {
+	familyID, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, familyID, ssoAuthTime)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// can be used once; presenting it again revokes its whole family and the
+// access tokens issued in it.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	stored, err := s.refreshRepo.FindRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil || now.After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	used := stored.UsedAt != nil
+	if !used {
+		// Another request may have used the token since it was read.
+		ok, err := s.refreshRepo.UseRefreshToken(ctx, stored.ID, now)
+		if err != nil {
+			return nil, err
+		}
+		used = !ok
+	}
+	if used {
+		if err := s.refreshRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID, now); err != nil {
+			return nil, err
+		}
+		// Whoever replayed the token may hold access tokens of the family,
+		// so end those too.
+		if err := s.revocations.RevokeSessions(ctx, stored.UserID, []string{stored.FamilyID}); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// Reload the user so that role changes take effect on refresh.
+	user, err := s.userRepo.FindUserByID(ctx, stored.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	return s.issueTokens(ctx, user, stored.FamilyID, time.Time{})
+}
+
+// Authenticate resolves an access token to its claims and current user. It
+// rejects tokens that are invalid or revoked and accounts that were disabled
+// or deleted since the token was issued.
+func (s *AuthService) Authenticate(ctx context.Context, token string) (*models.User, *utils.Claims, error) {
+	claims, err := utils.ValidateJWT(token, s.keys)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if s.revocations.IsRevoked(ctx, claims) {
+		return nil, nil, ErrTokenRevoked
+	}
+	user, err := s.userRepo.FindUserByID(ctx, claims.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+	return user, claims, nil
+}
+
+// Logout revokes the access token described by claims and the refresh
+// tokens of the login it was issued from.
+func (s *AuthService) Logout(ctx context.Context, claims *utils.Claims) error {
+	if err := s.revocations.RevokeToken(ctx, claims); err != nil {
+		return err
+	}
+	if claims.SessionID == "" {
+		return nil
+	}
+	return s.refreshRepo.RevokeRefreshTokenFamily(ctx, claims.SessionID, time.Now())
+}
+
+// LogoutAll revokes every access and refresh token of the user, ending all
+// of their sessions. The sessions are revoked by ID as well, since the
+// user-wide cutoff spares tokens issued earlier in the current second.
+func (s *AuthService) LogoutAll(ctx context.Context, userID int) error {
+	if err := s.revocations.RevokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	families, err := s.refreshRepo.RevokeUserRefreshTokens(ctx, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.revocations.RevokeSessions(ctx, userID, families)
+}
+
+// LogoutOthers ends every session of the user except the one claims were
+// issued from, revoking their refresh tokens and the access tokens issued
+// from them.
+func (s *AuthService) LogoutOthers(ctx context.Context, claims *utils.Claims) error {
+	families, err := s.refreshRepo.RevokeOtherRefreshTokens(ctx, claims.UserID, claims.SessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.revocations.RevokeSessions(ctx, claims.UserID, families)
+}
+
+// issueTokens signs an access token for user and stores a new refresh token
+// in the given family. Only the access token records ssoAuthTime, so that
+// refreshing does not extend it.
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, familyID string,
+	ssoAuthTime time.Time) (*models.TokenPair, error) {
+	accessToken, err := utils.GenerateJWT(user.ID, user.Username, user.Role, familyID, ssoAuthTime, s.keys,
+		s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.refreshRepo.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return token, nil
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}, nil
 }
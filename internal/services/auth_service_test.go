@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+	"github.com/globallstudent/todo-project-go/internal/passhash"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+const testPassword = "correct horse"
+
+// newTestAuthService returns an AuthService on an in-memory store, hashing
+// passwords with the cheapest bcrypt cost to keep the tests fast.
+func newTestAuthService(t *testing.T) (*AuthService, *repositories.Store) {
+	t.Helper()
+	store := repositories.NewMemoryStore()
+	t.Cleanup(store.Close)
+	bcrypt, err := passhash.NewBcrypt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttle := NewLoginThrottleService(store.LoginThrottles, LoginLimits{
+		MaxFailures: 5, MaxIPFailures: 20, Lockout: time.Minute, MaxLockout: time.Hour,
+	})
+	mfa := NewMFAService(store.MFA, throttle, "Todo API")
+	auth := NewAuthService(store.Users, store.Projects, store.RefreshTokens,
+		NewRevocationService(store.TokenRevocations, time.Minute), mfa, throttle, keyset.NewHMAC("test-secret"),
+		passhash.NewHasher(bcrypt), &passpolicy.Policy{}, time.Minute, time.Hour)
+	return auth, store
+}
+
+func TestRegisterUsername(t *testing.T) {
+	auth, _ := newTestAuthService(t)
+	tests := []struct {
+		name     string
+		username string
+		want     string
+		wantErr  error
+	}{
+		{name: "plain", username: "alice", want: "alice"},
+		{name: "trimmed", username: "  bob\t", want: "bob"},
+		{name: "longest", username: strings.Repeat("ä", MaxUsernameLength), want: strings.Repeat("ä", MaxUsernameLength)},
+		{name: "too long", username: strings.Repeat("a", MaxUsernameLength+1), wantErr: ErrInvalidProfile},
+		{name: "empty", username: "", wantErr: ErrInvalidProfile},
+		{name: "blank", username: "   ", wantErr: ErrInvalidProfile},
+		{name: "taken once trimmed", username: " alice ", wantErr: ErrUsernameTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := auth.Register(context.Background(), tt.username, testPassword)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Register(%q) = %v, want %v", tt.username, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Register(%q) = %v", tt.username, err)
+			}
+			if user.Username != tt.want {
+				t.Errorf("Register(%q) created %q, want %q", tt.username, user.Username, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshReuseEndsSession(t *testing.T) {
+	ctx := context.Background()
+	auth, _ := newTestAuthService(t)
+	if _, err := auth.Register(ctx, "alice", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := auth.Login(ctx, "alice", testPassword, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := auth.Login(ctx, "alice", testPassword, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Refresh() of a used token = %v, want ErrRefreshTokenReused", err)
+	}
+
+	for name, token := range map[string]string{"first": first.AccessToken, "second": second.AccessToken} {
+		if _, _, err := auth.Authenticate(ctx, token); err == nil {
+			t.Errorf("%s access token of the family still works", name)
+		}
+	}
+	if _, err := auth.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with the family revoked = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := auth.Authenticate(ctx, other.AccessToken); err != nil {
+		t.Errorf("access token of another session: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+	"github.com/globallstudent/todo-project-go/internal/mailer"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/passhash"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+// mailCooldown is how long a user has to wait between two emails of the
+// same kind.
+const mailCooldown = time.Minute
+
+// Kinds of emails, as told apart by the cooldown.
+const (
+	mailPasswordReset     = "password-reset"
+	mailEmailVerification = "email-verification"
+)
+
+var (
+	// ErrInvalidResetToken is returned for password reset tokens that are
+	// invalid, expired or already used.
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	// ErrInvalidVerificationToken is returned for email verification tokens
+	// that are invalid, expired or for an address the user no longer has.
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	// ErrNoEmail is returned when verifying the email of an account without
+	// one.
+	ErrNoEmail = errors.New("the account has no email address")
+	// ErrEmailAlreadyVerified is returned when asking to verify an address
+	// that is verified already.
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+	// ErrEmailNotVerified is returned for features that need a verified
+	// email address.
+	ErrEmailNotVerified = errors.New("verify your email address first")
+	// ErrMailCooldown is returned when asking for another email too soon.
+	ErrMailCooldown = errors.New("an email was sent recently, try again later")
+	// ErrMailDisabled is returned for features that send emails when no
+	// mailer is configured.
+	ErrMailDisabled = errors.New("password resets and email verification are not available")
+)
+
+// EmailSettings configures the emails sent to users.
+type EmailSettings struct {
+	// AppURL is where the links in emails point to, followed by
+	// /password/reset or /email/verify and the token.
+	AppURL string
+	// ResetTTL and VerificationTTL are how long password reset and email
+	// verification links work.
+	ResetTTL        time.Duration
+	VerificationTTL time.Duration
+	// ResetMinEmailAge is how long an address has to have been verified
+	// before password reset links are sent to it, so that whoever takes
+	// over a session cannot set their own address and reset the password
+	// right away.
+	ResetMinEmailAge time.Duration
+}
+
+// EmailService mails users links to reset a forgotten password and to
+// verify their email address, unless it has no mailer. The links carry
+// signed tokens, so nothing needs to be stored until they are used. A reset
+// token is bound to the password it replaces and stops working once the
+// password changes; a verification token is bound to the address it
+// verifies.
+type EmailService struct {
+	userRepo    repositories.UserRepository
+	authService *AuthService
+	mailer      mailer.Mailer
+	passwords   *passhash.Hasher
+	policy      *passpolicy.Policy
+	throttle    *LoginThrottleService
+	keys        *keyset.Keyset
+	settings    EmailSettings
+
+	// lastSent is when each user was last sent an email of each kind.
+	mu       sync.Mutex
+	lastSent map[mailKey]time.Time
+}
+
+type mailKey struct {
+	userID int
+	kind   string
+}
+
+func NewEmailService(userRepo repositories.UserRepository, authService *AuthService, mailer mailer.Mailer,
+	passwords *passhash.Hasher, policy *passpolicy.Policy, throttle *LoginThrottleService, keys *keyset.Keyset,
+	settings EmailSettings) *EmailService {
+	return &EmailService{
+		userRepo:    userRepo,
+		authService: authService,
+		mailer:      mailer,
+		passwords:   passwords,
+		policy:      policy,
+		throttle:    throttle,
+		keys:        keys,
+		settings:    settings,
+		lastSent:    make(map[mailKey]time.Time),
+	}
+}
+
+// ForgotPassword mails a password reset link to the account with the
+// email address, if there is an enabled one and it verified the address at
+// least ResetMinEmailAge ago. Whether there is is not revealed: the link is
+// sent in the background and failures are only logged.
+func (s *EmailService) ForgotPassword(ctx context.Context, email string) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+	user, err := s.userRepo.FindUserByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt == nil || user.Disabled {
+		return nil
+	}
+	if age := time.Since(*user.EmailVerifiedAt); age < s.settings.ResetMinEmailAge {
+		log.Printf("Not mailing password reset link to user %d: email address verified %s ago",
+			user.ID, age.Round(time.Second))
+		return nil
+	}
+	if !s.allowMail(user.ID, mailPasswordReset) {
+		return nil
+	}
+
+	token, err := utils.GeneratePasswordResetToken(user.ID, passwordFingerprint(user.Password), s.keys, s.settings.ResetTTL)
+	if err != nil {
+		return err
+	}
+	msg := &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account %q.\n\n"+
+			"To choose a new password, open\n\n%s\n\n"+
+			"The link works once and expires in %s. If you did not ask for this, ignore this email; "+
+			"your password stays the same.\n",
+			user.Username, s.link("/password/reset", token), humanDuration(s.settings.ResetTTL)),
+	}
+	go func() {
+		if err := s.mailer.Send(context.WithoutCancel(ctx), msg); err != nil {
+			log.Printf("Failed to mail password reset link to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword sets a new password for the user a reset token was mailed
+// to, ends all of their sessions and lifts a lockout of their username.
+// Passwords failing the password policy are rejected with a
+// *passpolicy.Error.
+func (s *EmailService) ResetPassword(ctx context.Context, token, password string) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+	userID, fingerprint, err := utils.ValidatePasswordResetToken(token, s.keys)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	user, err := s.userRepo.FindUserByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(passwordFingerprint(user.Password))) != 1 {
+		return ErrInvalidResetToken
+	}
+	if user.Disabled {
+		return ErrUserDisabled
+	}
+	if err := s.policy.Check(password, user.Username); err != nil {
+		return err
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+	if err := s.authService.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+	return s.throttle.Unlock(ctx, user.Username)
+}
+
+// SendVerification mails a link verifying the email address of the user.
+func (s *EmailService) SendVerification(ctx context.Context, userID int) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+	user, err := s.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	if !s.allowMail(user.ID, mailEmailVerification) {
+		return ErrMailCooldown
+	}
+
+	token, err := utils.GenerateEmailVerificationToken(user.ID, user.Email, s.keys, s.settings.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that %s is the email address of your account %q by opening\n\n%s\n\n"+
+			"The link expires in %s.\n",
+			user.Email, user.Username, s.link("/email/verify", token), humanDuration(s.settings.VerificationTTL)),
+	})
+}
+
+// NotifyEmailChanged tells the previous address of user, as it was before
+// the change, that the account now uses email instead, so that the owner
+// notices if someone else changed it.
+func (s *EmailService) NotifyEmailChanged(ctx context.Context, user *models.User, email string) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+	change := "now uses " + email
+	if email == "" {
+		change = "no longer has an email address"
+	}
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Your account %q %s instead of %s.\n\n"+
+			"If you did not make this change, change your password and contact the administrator, "+
+			"as password reset links no longer reach you.\n",
+			user.Username, change, user.Email),
+	})
+}
+
+// VerifyEmail marks the address a verification token was mailed to as
+// verified, provided it is still the user's.
+func (s *EmailService) VerifyEmail(ctx context.Context, token string) error {
+	userID, email, err := utils.ValidateEmailVerificationToken(token, s.keys)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	user, err := s.userRepo.FindUserByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Email, email) {
+		return ErrInvalidVerificationToken
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	ok, err := s.userRepo.VerifyEmail(ctx, user.ID, email, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+	return nil
+}
+
+// Enabled reports whether the service has a mailer to send emails with.
+func (s *EmailService) Enabled() bool {
+	return s.mailer != nil
+}
+
+// allowMail reports whether the user may be sent an email of the kind now,
+// and if so counts it as sent. Entries older than mailCooldown are
+// forgotten on the way.
+func (s *EmailService) allowMail(userID int, kind string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, at := range s.lastSent {
+		if now.Sub(at) >= mailCooldown {
+			delete(s.lastSent, key)
+		}
+	}
+	key := mailKey{userID: userID, kind: kind}
+	if _, ok := s.lastSent[key]; ok {
+		return false
+	}
+	s.lastSent[key] = now
+	return true
+}
+
+func (s *EmailService) link(path, token string) string {
+	return strings.TrimRight(s.settings.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// passwordFingerprint identifies a password hash without revealing it.
+func passwordFingerprint(hash string) string {
+	return utils.HashToken(hash)[:32]
+}
+
+// humanDuration spells out d for emails, as "30 minutes" or "48 hours".
+func humanDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+// throttleSweepInterval is how often throttles without recent failures are
+// deleted from storage.
+const throttleSweepInterval = 10 * time.Minute
+
+// ErrLoginLocked is returned for logins refused because of too many failed
+// attempts. It is always wrapped in a *LoginLockedError.
+var ErrLoginLocked = errors.New("too many failed logins, try again later")
+
+// LoginLockedError refuses a login that is locked out, telling when to try
+// again.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string { return ErrLoginLocked.Error() }
+
+func (e *LoginLockedError) Unwrap() error { return ErrLoginLocked }
+
+// LoginLimits configures how failed logins are throttled.
+type LoginLimits struct {
+	// MaxFailures is how many failed logins of one username lock it out.
+	MaxFailures int
+	// MaxIPFailures is how many failed logins from one client IP, for any
+	// usernames, lock it out. It should allow for users sharing an address.
+	MaxIPFailures int
+	// Lockout is how long the first lockout lasts. Each further failure
+	// doubles it, up to MaxLockout. Failures are forgotten once MaxLockout
+	// passes without one.
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+// LoginThrottleService slows down password guessing. It counts failed
+// logins per username and per client IP and locks either out for
+// exponentially longer once it has too many. Counters are kept in storage,
+// so they hold across instances of the API.
+type LoginThrottleService struct {
+	repo   repositories.LoginThrottleRepository
+	limits LoginLimits
+
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+}
+
+func NewLoginThrottleService(repo repositories.LoginThrottleRepository, limits LoginLimits) *LoginThrottleService {
+	return &LoginThrottleService{repo: repo, limits: limits}
+}
+
+// Check fails with a *LoginLockedError while username or ip is locked out.
+func (s *LoginThrottleService) Check(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range throttleKeys(username, ip) {
+		t, err := s.repo.FindLoginThrottle(ctx, key.Kind, key.Subject)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if t.LockedUntil != nil && t.LockedUntil.After(now) {
+			wait = max(wait, t.LockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return &LoginLockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed login of username from ip and locks out whichever
+// of them has had too many, recording a lockout event. It returns a
+// *LoginLockedError if the failure led to a lockout.
+func (s *LoginThrottleService) Fail(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	s.sweep(ctx, now)
+
+	var wait time.Duration
+	for _, key := range throttleKeys(username, ip) {
+		t, err := s.repo.RecordLoginFailure(ctx, key.Kind, key.Subject, now, now.Add(-s.limits.MaxLockout))
+		if err != nil {
+			return err
+		}
+		limit := s.limits.MaxFailures
+		if key.Kind == models.ThrottleIP {
+			limit = s.limits.MaxIPFailures
+		}
+		if t.Failures < limit {
+			continue
+		}
+
+		lockout := s.lockout(t.Failures - limit)
+		until := now.Add(lockout)
+		if err := s.repo.LockLogin(ctx, key.Kind, key.Subject, until); err != nil {
+			return err
+		}
+		event := &models.LockoutEvent{
+			Kind:        key.Kind,
+			Subject:     key.Subject,
+			IP:          ip,
+			Failures:    t.Failures,
+			LockedUntil: until,
+		}
+		if err := s.repo.CreateLockoutEvent(ctx, event); err != nil {
+			return err
+		}
+		log.Printf("Locked out %s %q for %v after %d failed logins", key.Kind, key.Subject, lockout, t.Failures)
+		wait = max(wait, lockout)
+	}
+	if wait > 0 {
+		return &LoginLockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Succeed forgets the failed logins of username once it has logged in. The
+// count of the client IP is kept, so that logging into one's own account
+// does not make up for guessing the passwords of others.
+func (s *LoginThrottleService) Succeed(ctx context.Context, username string) error {
+	return s.repo.DeleteLoginThrottle(ctx, models.ThrottleUsername, username)
+}
+
+// Unlock lifts the lockout of username and forgets its failed logins.
+func (s *LoginThrottleService) Unlock(ctx context.Context, username string) error {
+	return s.repo.DeleteLoginThrottle(ctx, models.ThrottleUsername, username)
+}
+
+// ListLockouts returns the lockout events matching filter, newest first,
+// one page at a time.
+func (s *LoginThrottleService) ListLockouts(ctx context.Context, filter models.LockoutFilter) ([]*models.LockoutEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+	events, err := s.repo.ListLockoutEvents(ctx, filter)
+	if events == nil {
+		events = []*models.LockoutEvent{}
+	}
+	return events, err
+}
+
+// lockout returns how long to lock out a subject that has had extra
+// failures beyond its limit.
+func (s *LoginThrottleService) lockout(extra int) time.Duration {
+	d := s.limits.Lockout
+	for i := 0; i < extra && d < s.limits.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, s.limits.MaxLockout)
+}
+
+// sweep deletes throttles without recent failures once
+// throttleSweepInterval has passed. Failures are only logged, as they do
+// not affect throttling.
+func (s *LoginThrottleService) sweep(ctx context.Context, now time.Time) {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+
+	if now.Sub(s.lastSweep) < throttleSweepInterval {
+		return
+	}
+	if err := s.repo.DeleteStaleLoginThrottles(ctx, now.Add(-s.limits.MaxLockout)); err != nil {
+		log.Printf("Failed to sweep login throttles: %v", err)
+		return
+	}
+	s.lastSweep = now
+}
+
+// throttleKeys returns what a login of username from ip is counted against.
+// Logins without a known client IP are only counted per username.
+func throttleKeys(username, ip string) []models.LoginThrottle {
+	keys := []models.LoginThrottle{{Kind: models.ThrottleUsername, Subject: username}}
+	if ip != "" {
+		keys = append(keys, models.LoginThrottle{Kind: models.ThrottleIP, Subject: ip})
+	}
+	return keys
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+func newTestThrottle(t *testing.T, limits LoginLimits) *LoginThrottleService {
+	t.Helper()
+	store := repositories.NewMemoryStore()
+	t.Cleanup(store.Close)
+	return NewLoginThrottleService(store.LoginThrottles, limits)
+}
+
+// retryAfter returns how long err asks to wait, or zero if it is not a
+// lockout.
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var locked *LoginLockedError
+	if errors.As(err, &locked) {
+		if !errors.Is(err, ErrLoginLocked) {
+			t.Errorf("%v does not wrap ErrLoginLocked", err)
+		}
+		return locked.RetryAfter
+	}
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return 0
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	s := &LoginThrottleService{limits: LoginLimits{Lockout: time.Minute, MaxLockout: 10 * time.Minute}}
+	tests := []struct {
+		extra int
+		want  time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{3, 8 * time.Minute},
+		{4, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := s.lockout(tt.extra); got != tt.want {
+			t.Errorf("lockout(%d) = %v, want %v", tt.extra, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleUsername(t *testing.T) {
+	ctx := context.Background()
+	s := newTestThrottle(t, LoginLimits{MaxFailures: 3, MaxIPFailures: 100, Lockout: time.Minute, MaxLockout: time.Hour})
+
+	for i := 1; i < 3; i++ {
+		if wait := retryAfter(t, s.Fail(ctx, "alice", "192.0.2.1")); wait != 0 {
+			t.Fatalf("failure %d locked out for %v, want no lockout below the limit", i, wait)
+		}
+	}
+	if wait := retryAfter(t, s.Check(ctx, "alice", "192.0.2.1")); wait != 0 {
+		t.Fatalf("Check() below the limit locked out for %v", wait)
+	}
+	if wait := retryAfter(t, s.Fail(ctx, "alice", "192.0.2.2")); wait != time.Minute {
+		t.Fatalf("failure at the limit locked out for %v, want %v", wait, time.Minute)
+	}
+
+	for _, ip := range []string{"192.0.2.1", "198.51.100.7", ""} {
+		if wait := retryAfter(t, s.Check(ctx, "alice", ip)); wait <= 0 || wait > time.Minute {
+			t.Errorf("Check(alice, %q) locked out for %v, want up to %v", ip, wait, time.Minute)
+		}
+	}
+	if wait := retryAfter(t, s.Check(ctx, "bob", "192.0.2.1")); wait != 0 {
+		t.Errorf("another username from the same IP is locked out for %v", wait)
+	}
+
+	if wait := retryAfter(t, s.Fail(ctx, "alice", "192.0.2.1")); wait != 2*time.Minute {
+		t.Errorf("failure beyond the limit locked out for %v, want it doubled to %v", wait, 2*time.Minute)
+	}
+
+	events, err := s.ListLockouts(ctx, models.LockoutFilter{Kind: models.ThrottleUsername, Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Failures != 4 || events[1].Failures != 3 || events[1].IP != "192.0.2.2" {
+		t.Errorf("ListLockouts() = %+v, want the two lockouts newest first", events)
+	}
+
+	if err := s.Unlock(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := retryAfter(t, s.Check(ctx, "alice", "192.0.2.1")); wait != 0 {
+		t.Errorf("Check() after Unlock locked out for %v", wait)
+	}
+}
+
+func TestLoginThrottleIP(t *testing.T) {
+	ctx := context.Background()
+	s := newTestThrottle(t, LoginLimits{MaxFailures: 100, MaxIPFailures: 3, Lockout: time.Minute, MaxLockout: time.Hour})
+
+	for _, username := range []string{"alice", "bob"} {
+		if wait := retryAfter(t, s.Fail(ctx, username, "192.0.2.1")); wait != 0 {
+			t.Fatalf("failure of %s locked out for %v", username, wait)
+		}
+	}
+	if err := s.Succeed(ctx, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := retryAfter(t, s.Fail(ctx, "carol", "192.0.2.1")); wait != time.Minute {
+		t.Fatalf("third failure from the IP locked out for %v, want %v", wait, time.Minute)
+	}
+
+	if wait := retryAfter(t, s.Check(ctx, "dave", "192.0.2.1")); wait == 0 {
+		t.Error("a new username from the locked out IP may log in")
+	}
+	if wait := retryAfter(t, s.Check(ctx, "dave", "198.51.100.7")); wait != 0 {
+		t.Errorf("another IP is locked out for %v", wait)
+	}
+	// Logging into one's own account does not make up for guessing others.
+	if err := s.Succeed(ctx, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := retryAfter(t, s.Check(ctx, "carol", "192.0.2.1")); wait == 0 {
+		t.Error("a successful login lifted the lockout of the IP")
+	}
+}
+
+func TestLoginThrottleWithoutIP(t *testing.T) {
+	ctx := context.Background()
+	s := newTestThrottle(t, LoginLimits{MaxFailures: 100, MaxIPFailures: 1, Lockout: time.Minute, MaxLockout: time.Hour})
+	for i := 0; i < 3; i++ {
+		if wait := retryAfter(t, s.Fail(ctx, "alice", "")); wait != 0 {
+			t.Fatalf("failure without an IP locked out for %v, want only the username counted", wait)
+		}
+	}
+}
+
+func TestLoginThrottleExpires(t *testing.T) {
+	ctx := context.Background()
+	s := newTestThrottle(t, LoginLimits{
+		MaxFailures: 1, MaxIPFailures: 100, Lockout: 20 * time.Millisecond, MaxLockout: time.Hour,
+	})
+	if wait := retryAfter(t, s.Fail(ctx, "alice", "192.0.2.1")); wait == 0 {
+		t.Fatal("failure at the limit did not lock out")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if wait := retryAfter(t, s.Check(ctx, "alice", "192.0.2.1")); wait != 0 {
+		t.Errorf("Check() after the lockout passed locked out for %v", wait)
+	}
+	// Failures are remembered, so the next one locks out for longer.
+	if wait := retryAfter(t, s.Fail(ctx, "alice", "192.0.2.1")); wait != 40*time.Millisecond {
+		t.Errorf("next failure locked out for %v, want %v", wait, 40*time.Millisecond)
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/totp"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes a user is given at a time.
+	RecoveryCodeCount = 10
+	// totpSkew is how many time steps of clock drift codes may have either
+	// way.
+	totpSkew = 1
+	// mfaSweepInterval is how often the attempts of expired MFA tokens are
+	// deleted.
+	mfaSweepInterval = 10 * time.Minute
+)
+
+var (
+	// ErrMFAAlreadyEnabled is returned when enrolling an authenticator while
+	// one is already enabled.
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrMFANotEnabled is returned for actions that need an enabled
+	// authenticator.
+	ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrNoTOTPEnrollment is returned when confirming without having started
+	// an enrollment.
+	ErrNoTOTPEnrollment = errors.New("no authenticator enrollment in progress")
+	// ErrInvalidMFACode is returned for wrong, expired or reused codes.
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAService manages second factors: an authenticator app (TOTP) and the
+// one-time recovery codes that stand in for it when the device is lost.
+type MFAService struct {
+	repo     repositories.MFARepository
+	throttle *LoginThrottleService
+	issuer   string
+
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+}
+
+func NewMFAService(repo repositories.MFARepository, throttle *LoginThrottleService, issuer string) *MFAService {
+	return &MFAService{repo: repo, throttle: throttle, issuer: issuer}
+}
+
+// Status describes the user's second factors.
+func (s *MFAService) Status(ctx context.Context, userID int) (*models.MFAStatus, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.MFAStatus{TOTPEnabled: enabled}
+	if enabled {
+		if status.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Enabled reports whether logins of the user need a second factor.
+func (s *MFAService) Enabled(ctx context.Context, userID int) (bool, error) {
+	enrollment, err := s.repo.FindTOTPEnrollment(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enrollment.Enabled, nil
+}
+
+// EnrollTOTP starts enrolling an authenticator app with a new secret,
+// replacing any unconfirmed one. Logins are unaffected until ConfirmTOTP.
+// username labels the account in the app.
+func (s *MFAService) EnrollTOTP(ctx context.Context, userID int, username string) (*models.TOTPSetup, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.SaveTOTPEnrollment(ctx, &models.TOTPEnrollment{UserID: userID, Secret: secret})
+	if err != nil {
+		return nil, err
+	}
+	return &models.TOTPSetup{Secret: secret, URI: totp.URI(s.issuer, username, secret)}, nil
+}
+
+// ConfirmTOTP enables the pending enrollment once the user proves the app
+// works by entering a code from it, and returns a fresh set of recovery
+// codes. They are stored hashed and cannot be shown again.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	enrollment, err := s.repo.FindTOTPEnrollment(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrNoTOTPEnrollment
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Match(enrollment.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	now := time.Now()
+	enrollment.Enabled = true
+	enrollment.LastStep = step
+	enrollment.EnabledAt = &now
+	if err := s.repo.SaveTOTPEnrollment(ctx, enrollment); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+// DisableTOTP turns two-factor authentication off after checking a code
+// sent from ip and discards the recovery codes.
+func (s *MFAService) DisableTOTP(ctx context.Context, userID int, username, code, ip string) error {
+	if err := s.verifyThrottled(ctx, userID, username, code, ip); err != nil {
+		return err
+	}
+	return s.repo.DeleteMFA(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a code sent from ip.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID int, username, code, ip string) ([]string, error) {
+	if err := s.verifyThrottled(ctx, userID, username, code, ip); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+// verifyThrottled is Verify counting wrong codes as failed logins of
+// username from ip, so that a stolen access token cannot be used to guess
+// codes any faster than the login would allow.
+func (s *MFAService) verifyThrottled(ctx context.Context, userID int, username, code, ip string) error {
+	if err := s.throttle.Check(ctx, username, ip); err != nil {
+		return err
+	}
+	if err := s.Verify(ctx, userID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.throttle.Fail(ctx, username, ip); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return s.throttle.Succeed(ctx, username)
+}
+
+// Verify checks a second factor of the user: a code from the authenticator
+// app or an unused recovery code. Either is accepted only once.
+func (s *MFAService) Verify(ctx context.Context, userID int, code string) error {
+	enrollment, err := s.repo.FindTOTPEnrollment(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if !enrollment.Enabled {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Match(enrollment.Secret, code, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if ok, err = s.repo.UseTOTPStep(ctx, userID, step); err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	ok, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code), time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// countAttempt counts a code tried with the MFA token jti before the code
+// is checked, so that parallel requests cannot try more than maxMFAAttempts
+// between them. It fails with ErrInvalidMFAToken once the token has
+// completed a login or used up its attempts.
+func (s *MFAService) countAttempt(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.sweep(ctx, time.Now())
+	attempts, err := s.repo.RecordMFAAttempt(ctx, jti, expiresAt)
+	if err != nil {
+		return err
+	}
+	if attempts.UsedAt != nil || attempts.Attempts > maxMFAAttempts {
+		return ErrInvalidMFAToken
+	}
+	return nil
+}
+
+// useToken marks the MFA token jti as having completed a login, failing
+// with ErrInvalidMFAToken if another request completed one with it first.
+func (s *MFAService) useToken(ctx context.Context, jti string) error {
+	ok, err := s.repo.UseMFAToken(ctx, jti, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFAToken
+	}
+	return nil
+}
+
+// sweep deletes the attempts of expired MFA tokens once mfaSweepInterval
+// has passed. Failures are only logged and retried on the next sweep.
+func (s *MFAService) sweep(ctx context.Context, now time.Time) {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+
+	if now.Sub(s.lastSweep) < mfaSweepInterval {
+		return
+	}
+	if err := s.repo.DeleteExpiredMFATokenAttempts(ctx, now); err != nil {
+		log.Printf("Failed to sweep MFA token attempts: %v", err)
+		return
+	}
+	s.lastSweep = now
+}
+
+// Reset removes every second factor of the user, for users who lost both
+// their device and their recovery codes.
+func (s *MFAService) Reset(ctx context.Context, userID int) error {
+	return s.repo.DeleteMFA(ctx, userID)
+}
+
+// newRecoveryCodes generates and stores a new set of recovery codes for the
+// user, formatted as two groups of five characters.
+func (s *MFAService) newRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, dashes and spaces,
+// so that codes can be typed back however they were written down.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utils.HashToken(code)
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/globallstudent/todo-project-go/internal/keyset"
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/oidc"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+const (
+	// oidcFlowTTL is how long a user has to log in at the provider.
+	oidcFlowTTL = 10 * time.Minute
+	// maxUsernameAttempts is how many numbered variants of a username are
+	// tried when provisioning a user whose username is taken.
+	maxUsernameAttempts = 20
+)
+
+var (
+	// ErrInvalidOIDCLogin is returned for callbacks that do not belong to a
+	// login started here, have expired or carry an invalid ID token.
+	ErrInvalidOIDCLogin = errors.New("invalid or expired single sign-on login, please start again")
+	// ErrOIDCProvider is returned when the OpenID Connect provider cannot
+	// be reached or misbehaves.
+	ErrOIDCProvider = errors.New("single sign-on provider unavailable")
+	// ErrNoLinkedAccount is returned when nobody's account is linked to the
+	// identity and accounts are not provisioned.
+	ErrNoLinkedAccount = errors.New("no account is linked to this identity")
+)
+
+// RoleMapping gives the users in an identity provider group a role.
+type RoleMapping struct {
+	Group string
+	Role  string
+}
+
+// OIDCSettings configures how identities of the OpenID Connect provider map
+// to users.
+type OIDCSettings struct {
+	// GroupsClaim is the ID token claim listing the user's groups.
+	GroupsClaim string
+	// RoleMappings assign the role of the first mapping whose group the
+	// user is in at every login, and the user role if none matches. Without
+	// mappings, roles are managed here.
+	RoleMappings []RoleMapping
+	// AutoProvision creates users for identities not linked to any.
+	AutoProvision bool
+	// LinkByEmail links an identity to the user with the same email
+	// address, if both sides have verified it and the user is neither an
+	// admin nor uses two-factor authentication.
+	LinkByEmail bool
+}
+
+// OIDCLogin is a login started with the provider. The flow token must come
+// back with the provider's callback, in a cookie, to complete it.
+type OIDCLogin struct {
+	URL       string
+	FlowToken string
+	ExpiresIn time.Duration
+}
+
+// OIDCService logs users in with an OpenID Connect provider instead of a
+// password. Identities are linked to users by the provider's subject;
+// identities seen for the first time are linked by verified email address or
+// get a new user, as configured. Logins issue the same tokens as password
+// logins.
+type OIDCService struct {
+	rp           *oidc.RelyingParty
+	userRepo     repositories.UserRepository
+	identityRepo repositories.IdentityRepository
+	projectRepo  repositories.ProjectRepository
+	policy       *PolicyService
+	authService  *AuthService
+	keys         *keyset.Keyset
+	settings     OIDCSettings
+}
+
+func NewOIDCService(rp *oidc.RelyingParty, userRepo repositories.UserRepository,
+	identityRepo repositories.IdentityRepository, projectRepo repositories.ProjectRepository, policy *PolicyService,
+	authService *AuthService, keys *keyset.Keyset, settings OIDCSettings) *OIDCService {
+	return &OIDCService{
+		rp:           rp,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		projectRepo:  projectRepo,
+		policy:       policy,
+		authService:  authService,
+		keys:         keys,
+		settings:     settings,
+	}
+}
+
+// Begin starts a login, returning the provider URL to send the user to.
+// With reauth, the provider is asked to authenticate the user again, as
+// users without a password do to confirm changes to their account.
+func (s *OIDCService) Begin(ctx context.Context, reauth bool) (*OIDCLogin, error) {
+	state, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.rp.AuthCodeURL(ctx, state, nonce, verifier, reauth)
+	if err != nil {
+		log.Printf("Failed to start login with %s: %v", s.rp.Issuer(), err)
+		return nil, fmt.Errorf("%w: %w", ErrOIDCProvider, err)
+	}
+	flowToken, err := utils.GenerateOIDCFlowToken(state, nonce, verifier, s.keys, oidcFlowTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLogin{URL: url, FlowToken: flowToken, ExpiresIn: oidcFlowTTL}, nil
+}
+
+// Complete finishes the login flowToken started, once the provider has sent
+// the user back with state and code, and starts a session for the user the
+// identity belongs to. Like Login, users with two-factor authentication get
+// a challenge instead of tokens, to be completed with LoginMFA.
+func (s *OIDCService) Complete(ctx context.Context, flowToken, state, code string) (*models.TokenPair, *models.MFAChallenge, error) {
+	flow, err := utils.ValidateOIDCFlowToken(flowToken, s.keys)
+	if err != nil || subtle.ConstantTimeCompare([]byte(flow.State), []byte(state)) != 1 {
+		return nil, nil, ErrInvalidOIDCLogin
+	}
+
+	identity, err := s.rp.Exchange(ctx, code, flow.Verifier, flow.Nonce)
+	if errors.Is(err, oidc.ErrInvalidIDToken) {
+		log.Printf("Rejected ID token from %s: %v", s.rp.Issuer(), err)
+		return nil, nil, ErrInvalidOIDCLogin
+	}
+	if err != nil {
+		log.Printf("Failed to complete login with %s: %v", s.rp.Issuer(), err)
+		return nil, nil, fmt.Errorf("%w: %w", ErrOIDCProvider, err)
+	}
+
+	user, err := s.resolveUser(ctx, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+	if err := s.syncRole(ctx, user, identity); err != nil {
+		return nil, nil, err
+	}
+
+	challenge, err := s.authService.mfaChallenge(ctx, user, identity.AuthTime)
+	if err != nil || challenge != nil {
+		return nil, challenge, err
+	}
+	tokens, err := s.authService.startSession(ctx, user, identity.AuthTime)
+	return tokens, nil, err
+}
+
+// resolveUser returns the user identity is linked to, linking or creating
+// one the first time it is seen.
+func (s *OIDCService) resolveUser(ctx context.Context, identity *oidc.IDToken) (*models.User, error) {
+	linked, err := s.identityRepo.FindIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return s.userRepo.FindUserByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	user, err := s.userByEmail(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if !s.settings.AutoProvision {
+			return nil, ErrNoLinkedAccount
+		}
+		if user, err = s.provision(ctx, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	link := &models.UserIdentity{Issuer: identity.Issuer, Subject: identity.Subject, UserID: user.ID}
+	if err := s.identityRepo.CreateIdentity(ctx, link); err != nil {
+		return nil, err
+	}
+	log.Printf("Linked %s subject %q to user %d", identity.Issuer, identity.Subject, user.ID)
+	return user, nil
+}
+
+// userByEmail returns the user to link identity to by email address, or nil
+// if there is none. Both the provider and the user must have verified the
+// address; otherwise anyone could claim an account by its address. Admins
+// and users with two-factor authentication are never linked, as whoever
+// controls the address at the provider would get past their protection.
+func (s *OIDCService) userByEmail(ctx context.Context, identity *oidc.IDToken) (*models.User, error) {
+	if !s.settings.LinkByEmail || !identity.EmailVerified || identity.Email == "" {
+		return nil, nil
+	}
+	user, err := s.userRepo.FindUserByEmail(ctx, identity.Email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, nil
+	}
+	if user.Role == models.RoleAdmin {
+		log.Printf("Not linking %s subject %q to admin %d by email address", identity.Issuer, identity.Subject, user.ID)
+		return nil, nil
+	}
+	mfaEnabled, err := s.authService.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		log.Printf("Not linking %s subject %q to user %d by email address: they use two-factor authentication",
+			identity.Issuer, identity.Subject, user.ID)
+		return nil, nil
+	}
+	return user, nil
+}
+
+// provision creates a user for identity. It has no password, so it can
+// only log in through the provider until the owner sets one. The username
+// comes from the identity, numbered if it is taken, and its email address is
+// kept if the provider verified it and nobody else has it.
+func (s *OIDCService) provision(ctx context.Context, identity *oidc.IDToken) (*models.User, error) {
+	user := &models.User{
+		Role:        models.RoleUser,
+		DisplayName: truncate(strings.TrimSpace(identity.Name), maxDisplayNameLength),
+	}
+	if email, err := normalizeEmail(identity.Email); err == nil && email != "" && identity.EmailVerified {
+		_, err := s.userRepo.FindUserByEmail(ctx, email)
+		if errors.Is(err, repositories.ErrNotFound) {
+			user.Email = email
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	base := provisionedUsername(identity)
+	var err error
+	for i := 1; ; i++ {
+		user.Username = base
+		if i > 1 {
+			user.Username = base + "-" + strconv.Itoa(i)
+		}
+		err = s.userRepo.CreateUser(ctx, user)
+		if !errors.Is(err, repositories.ErrDuplicate) || i == maxUsernameAttempts {
+			break
+		}
+	}
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, ErrUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Email != "" {
+		now := time.Now()
+		if _, err := s.userRepo.VerifyEmail(ctx, user.ID, user.Email, now); err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+	if _, err := ensureInbox(ctx, s.projectRepo, user.ID); err != nil {
+		return nil, err
+	}
+	log.Printf("Provisioned user %d (%s) for %s subject %q", user.ID, user.Username, identity.Issuer, identity.Subject)
+	return user, nil
+}
+
+// syncRole gives user the role their groups map to. Roles that do not exist
+// and demoting the last admin are refused with a log message, keeping the
+// current role, so that a provider misconfiguration cannot lock everyone
+// out.
+func (s *OIDCService) syncRole(ctx context.Context, user *models.User, identity *oidc.IDToken) error {
+	if len(s.settings.RoleMappings) == 0 {
+		return nil
+	}
+	role := models.RoleUser
+	groups := identity.Strings(s.settings.GroupsClaim)
+	for _, m := range s.settings.RoleMappings {
+		if slices.Contains(groups, m.Group) {
+			role = m.Role
+			break
+		}
+	}
+	if role == user.Role {
+		return nil
+	}
+
+	if _, err := s.policy.GetRole(ctx, role); err != nil {
+		if errors.Is(err, ErrRoleNotFound) {
+			log.Printf("Not giving user %d the unknown role %q", user.ID, role)
+			return nil
+		}
+		return err
+	}
+	if role != models.RoleAdmin {
+		if err := keepAnAdmin(ctx, s.userRepo, user); err != nil {
+			if errors.Is(err, ErrLastAdmin) {
+				log.Printf("Not demoting user %d to %q: they are the last admin", user.ID, role)
+				return nil
+			}
+			return err
+		}
+	}
+	log.Printf("Changing role of user %d from %q to %q as mapped from their groups", user.ID, user.Role, role)
+	user.Role = role
+	return s.userRepo.UpdateUser(ctx, user)
+}
+
+// provisionedUsername picks the username of a user provisioned for
+// identity, leaving room for a number to make it unique.
+func provisionedUsername(identity *oidc.IDToken) string {
+	name := strings.TrimSpace(identity.PreferredUsername)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+		name = strings.TrimSpace(name)
+	}
+	if name == "" {
+		name = "user"
+	}
+	return truncate(name, MaxUsernameLength-len("-20"))
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/oidc"
+	"github.com/globallstudent/todo-project-go/internal/oidc/oidctest"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+type oidcTest struct {
+	t       *testing.T
+	srv     *oidctest.Server
+	store   *repositories.Store
+	auth    *AuthService
+	service *OIDCService
+}
+
+// newOIDCTest wires an OIDCService to a mock provider and an in-memory
+// store. The provider logs everyone in as alice until told otherwise.
+func newOIDCTest(t *testing.T, settings OIDCSettings) *oidcTest {
+	t.Helper()
+	srv, err := oidctest.NewServer("todo-api", map[string]any{
+		"sub":                "alice-1",
+		"preferred_username": "alice",
+		"name":               "Alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	rp := oidc.New(oidc.Config{
+		Issuer:       srv.URL,
+		ClientID:     "todo-api",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/oidc/callback",
+		Scopes:       []string{"openid"},
+		HTTPClient:   srv.Client(),
+	})
+
+	auth, store := newTestAuthService(t)
+	policy := NewPolicyService(store.Roles, store.Users)
+	service := NewOIDCService(rp, store.Users, store.Identities, store.Projects, policy, auth, auth.keys, settings)
+	return &oidcTest{t: t, srv: srv, store: store, auth: auth, service: service}
+}
+
+// login logs in through the provider as its current user.
+func (o *oidcTest) login() (*models.TokenPair, *models.MFAChallenge, error) {
+	o.t.Helper()
+	ctx := context.Background()
+	login, err := o.service.Begin(ctx, false)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	back, err := o.srv.Authorize(login.URL)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	return o.service.Complete(ctx, login.FlowToken, back.Query().Get("state"), back.Query().Get("code"))
+}
+
+// loginAs logs in through the provider as the user with claims and returns
+// the user the login started a session for.
+func (o *oidcTest) loginAs(claims map[string]any) *models.User {
+	o.t.Helper()
+	o.srv.SetUser(claims)
+	tokens, challenge, err := o.login()
+	if err != nil {
+		o.t.Fatalf("login as %v: %v", claims["sub"], err)
+	}
+	if challenge != nil || tokens == nil {
+		o.t.Fatalf("login as %v returned challenge %v, want tokens", claims["sub"], challenge)
+	}
+	user, _, err := o.auth.Authenticate(context.Background(), tokens.AccessToken)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	return user
+}
+
+// register creates a user with a password, and a verified email address
+// unless email is empty.
+func (o *oidcTest) register(username, email string) *models.User {
+	o.t.Helper()
+	ctx := context.Background()
+	user, err := o.auth.Register(ctx, username, testPassword)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	if email != "" {
+		user.Email = email
+		if err := o.store.Users.UpdateUser(ctx, user); err != nil {
+			o.t.Fatal(err)
+		}
+		if _, err := o.store.Users.VerifyEmail(ctx, user.ID, email, time.Now()); err != nil {
+			o.t.Fatal(err)
+		}
+	}
+	return user
+}
+
+func TestOIDCCompleteRejectsForgedLogins(t *testing.T) {
+	tests := []struct {
+		name string
+		// claims override the ones the provider sets.
+		claims map[string]any
+		state  string
+	}{
+		{name: "state mismatch", state: "another-state"},
+		{name: "nonce mismatch", claims: map[string]any{"nonce": "another-nonce"}},
+		{name: "wrong audience", claims: map[string]any{"aud": "another-client"}},
+		{name: "issued to another party", claims: map[string]any{"aud": []string{"todo-api", "another-client"},
+			"azp": "another-client"}},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://evil.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t, OIDCSettings{AutoProvision: true})
+			claims := map[string]any{"sub": "alice-1", "preferred_username": "alice"}
+			for k, v := range tt.claims {
+				claims[k] = v
+			}
+			o.srv.SetUser(claims)
+
+			ctx := context.Background()
+			login, err := o.service.Begin(ctx, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err := o.srv.Authorize(login.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := back.Query().Get("state")
+			if tt.state != "" {
+				state = tt.state
+			}
+			tokens, _, err := o.service.Complete(ctx, login.FlowToken, state, back.Query().Get("code"))
+			if !errors.Is(err, ErrInvalidOIDCLogin) || tokens != nil {
+				t.Errorf("Complete() = %v, %v, want ErrInvalidOIDCLogin", tokens, err)
+			}
+			if _, err := o.store.Users.FindUserByUsername(ctx, "alice"); !errors.Is(err, repositories.ErrNotFound) {
+				t.Errorf("a user was provisioned for the rejected login: %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCCompleteRejectsFlowTokenOfAnotherLogin(t *testing.T) {
+	o := newOIDCTest(t, OIDCSettings{AutoProvision: true})
+	ctx := context.Background()
+	first, err := o.service.Begin(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := o.service.Begin(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := o.srv.Authorize(second.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = o.service.Complete(ctx, first.FlowToken, back.Query().Get("state"), back.Query().Get("code"))
+	if !errors.Is(err, ErrInvalidOIDCLogin) {
+		t.Errorf("Complete() with the flow token of another login = %v, want ErrInvalidOIDCLogin", err)
+	}
+}
+
+func TestOIDCProvisioning(t *testing.T) {
+	o := newOIDCTest(t, OIDCSettings{AutoProvision: true})
+	o.register("alice", "")
+
+	first := o.loginAs(map[string]any{
+		"sub":                "alice-1",
+		"preferred_username": "alice",
+		"name":               "Alice from SSO",
+		"email":              "Alice@Example.com",
+		"email_verified":     true,
+	})
+	if first.Username != "alice-2" {
+		t.Errorf("provisioned username %q, want alice-2 as alice is taken", first.Username)
+	}
+	if first.Password != "" || first.Role != models.RoleUser || first.DisplayName != "Alice from SSO" {
+		t.Errorf("provisioned %+v", first)
+	}
+	if first.Email != "Alice@Example.com" || first.EmailVerifiedAt == nil {
+		t.Errorf("provisioned email %q verified at %v, want the verified address", first.Email, first.EmailVerifiedAt)
+	}
+
+	again := o.loginAs(map[string]any{"sub": "alice-1", "preferred_username": "someone-else"})
+	if again.ID != first.ID {
+		t.Errorf("second login got user %d, want the linked user %d", again.ID, first.ID)
+	}
+
+	third := o.loginAs(map[string]any{"sub": "alice-2", "preferred_username": "alice"})
+	if third.Username != "alice-3" {
+		t.Errorf("provisioned username %q, want alice-3", third.Username)
+	}
+
+	long := o.loginAs(map[string]any{"sub": "long-1", "preferred_username": strings.Repeat("x", 80)})
+	if len(long.Username) > MaxUsernameLength {
+		t.Errorf("provisioned username of %d characters, want at most %d", len(long.Username), MaxUsernameLength)
+	}
+
+	fromEmail := o.loginAs(map[string]any{"sub": "bob-1", "email": "bob@example.com", "email_verified": false})
+	if fromEmail.Username != "bob" || fromEmail.Email != "" {
+		t.Errorf("provisioned %q with email %q, want bob without the unverified address",
+			fromEmail.Username, fromEmail.Email)
+	}
+}
+
+func TestOIDCProvisioningDisabled(t *testing.T) {
+	o := newOIDCTest(t, OIDCSettings{})
+	if _, _, err := o.login(); !errors.Is(err, ErrNoLinkedAccount) {
+		t.Errorf("login of an unknown identity = %v, want ErrNoLinkedAccount", err)
+	}
+}
+
+func TestOIDCProvisioningUsernameExhausted(t *testing.T) {
+	o := newOIDCTest(t, OIDCSettings{AutoProvision: true})
+	o.register("carol", "")
+	for i := 2; i <= maxUsernameAttempts; i++ {
+		o.register("carol-"+strconv.Itoa(i), "")
+	}
+	o.srv.SetUser(map[string]any{"sub": "carol-1", "preferred_username": "carol"})
+	if _, _, err := o.login(); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("login = %v, want ErrUsernameTaken once every variant is taken", err)
+	}
+}
+
+func TestOIDCLinkByEmail(t *testing.T) {
+	ctx := context.Background()
+	o := newOIDCTest(t, OIDCSettings{AutoProvision: true, LinkByEmail: true})
+	carol := o.register("carol", "carol@example.com")
+	dave := o.register("dave", "dave@example.com")
+	admin, err := o.auth.CreateAdmin(ctx, "root", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.Email = "root@example.com"
+	if err := o.store.Users.UpdateUser(ctx, admin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.store.Users.VerifyEmail(ctx, admin.ID, admin.Email, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	erin := o.register("erin", "erin@example.com")
+	if err := o.store.MFA.SaveTOTPEnrollment(ctx, &models.TOTPEnrollment{
+		UserID: erin.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true, CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		wantUser int
+	}{
+		{
+			name:     "verified on both sides",
+			claims:   map[string]any{"sub": "carol-1", "email": "CAROL@example.com", "email_verified": true},
+			wantUser: carol.ID,
+		},
+		{
+			name:   "unverified at the provider",
+			claims: map[string]any{"sub": "dave-1", "email": "dave@example.com", "email_verified": false},
+		},
+		{
+			name:   "admin",
+			claims: map[string]any{"sub": "root-1", "email": "root@example.com", "email_verified": true},
+		},
+		{
+			name:   "two-factor authentication",
+			claims: map[string]any{"sub": "erin-1", "email": "erin@example.com", "email_verified": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := o.loginAs(tt.claims)
+			if tt.wantUser != 0 {
+				if user.ID != tt.wantUser {
+					t.Errorf("linked to user %d, want %d", user.ID, tt.wantUser)
+				}
+				return
+			}
+			if user.ID == carol.ID || user.ID == dave.ID || user.ID == admin.ID || user.ID == erin.ID {
+				t.Errorf("linked to existing user %d (%s), want a new user", user.ID, user.Username)
+			}
+			if user.Email != "" {
+				t.Errorf("provisioned user got the address %q of another user", user.Email)
+			}
+		})
+	}
+}
+
+func TestOIDCLinkedUserWithMFAGetsChallenge(t *testing.T) {
+	ctx := context.Background()
+	o := newOIDCTest(t, OIDCSettings{AutoProvision: true})
+	user := o.loginAs(map[string]any{"sub": "alice-1", "preferred_username": "alice"})
+	if err := o.store.MFA.SaveTOTPEnrollment(ctx, &models.TOTPEnrollment{
+		UserID: user.ID, Secret: "JBSWY3DPEHPK3PXP", Enabled: true, CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tokens, challenge, err := o.login()
+	if err != nil || tokens != nil || challenge == nil || !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Errorf("login with MFA = %v, %+v, %v, want a challenge", tokens, challenge, err)
+	}
+}
+
+func TestOIDCDisabledUser(t *testing.T) {
+	ctx := context.Background()
+	o := newOIDCTest(t, OIDCSettings{AutoProvision: true})
+	user := o.loginAs(map[string]any{"sub": "alice-1", "preferred_username": "alice"})
+	user.Disabled = true
+	if err := o.store.Users.UpdateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := o.login(); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("login of a disabled user = %v, want ErrUserDisabled", err)
+	}
+}
+
+func TestOIDCRoleMapping(t *testing.T) {
+	ctx := context.Background()
+	o := newOIDCTest(t, OIDCSettings{
+		AutoProvision: true,
+		GroupsClaim:   "groups",
+		RoleMappings: []RoleMapping{
+			{Group: "cn=admins,dc=example", Role: models.RoleAdmin},
+			{Group: "ghosts", Role: "ghost"},
+		},
+	})
+	alice := map[string]any{"sub": "alice-1", "preferred_username": "alice"}
+	bob := map[string]any{"sub": "bob-1", "preferred_username": "bob"}
+
+	withGroups := func(claims map[string]any, groups ...string) map[string]any {
+		c := map[string]any{"groups": groups}
+		for k, v := range claims {
+			c[k] = v
+		}
+		return c
+	}
+
+	if user := o.loginAs(withGroups(alice, "staff", "cn=admins,dc=example")); user.Role != models.RoleAdmin {
+		t.Errorf("role %q, want admin from the mapped group", user.Role)
+	}
+	if user := o.loginAs(withGroups(alice, "staff")); user.Role != models.RoleAdmin {
+		t.Errorf("role %q, want the last admin to stay admin", user.Role)
+	}
+
+	if user := o.loginAs(withGroups(bob, "cn=admins,dc=example")); user.Role != models.RoleAdmin {
+		t.Errorf("role %q, want admin", user.Role)
+	}
+	if user := o.loginAs(withGroups(alice)); user.Role != models.RoleUser {
+		t.Errorf("role %q, want user once another admin exists", user.Role)
+	}
+	if user := o.loginAs(withGroups(alice, "ghosts")); user.Role != models.RoleUser {
+		t.Errorf("role %q, want the unknown role ignored", user.Role)
+	}
+
+	// A single group may come as a string.
+	o.srv.SetUser(map[string]any{"sub": "alice-1", "groups": "cn=admins,dc=example"})
+	if _, _, err := o.login(); err != nil {
+		t.Fatal(err)
+	}
+	user, err := o.store.Users.FindUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleAdmin {
+		t.Errorf("role %q, want admin from a groups string", user.Role)
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+var (
+	// ErrForbidden is returned when the caller lacks the permission an
+	// action needs.
+	ErrForbidden = errors.New("access denied")
+	// ErrRoleNotFound is returned for roles that do not exist.
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrInvalidRoleName is returned for role names that are not 2 to 32
+	// lowercase letters, digits, dashes or underscores.
+	ErrInvalidRoleName = errors.New("role names are 2 to 32 lowercase letters, digits, dashes or underscores")
+	// ErrUnknownPermission is returned when granting a permission that does
+	// not exist.
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrAdminRole is returned when changing the admin role, which always
+	// holds every permission so that admins cannot lock themselves out.
+	ErrAdminRole = errors.New("the admin role holds every permission and cannot be changed")
+	// ErrBuiltinRole is returned when deleting the admin or user role.
+	ErrBuiltinRole = errors.New("built-in roles cannot be deleted")
+	// ErrRoleInUse is returned when deleting a role still assigned to users.
+	ErrRoleInUse = errors.New("role is assigned to users")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9_-]{2,32}$`)
+
+// PolicyService decides what a caller may do. Everyone may act on what they
+// own; acting on other users' data or administering the API takes a
+// permission granted to the caller's role.
+type PolicyService struct {
+	roleRepo repositories.RoleRepository
+	userRepo repositories.UserRepository
+}
+
+func NewPolicyService(roleRepo repositories.RoleRepository, userRepo repositories.UserRepository) *PolicyService {
+	return &PolicyService{roleRepo: roleRepo, userRepo: userRepo}
+}
+
+// Can reports whether actor's role holds permission.
+func (s *PolicyService) Can(ctx context.Context, actor *models.Principal, permission string) (bool, error) {
+	if actor.Role == models.RoleAdmin {
+		return true, nil
+	}
+	return s.roleRepo.HasPermission(ctx, actor.Role, permission)
+}
+
+// Require fails with ErrForbidden unless actor's role holds permission.
+func (s *PolicyService) Require(ctx context.Context, actor *models.Principal, permission string) error {
+	ok, err := s.Can(ctx, actor, permission)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s permission required", ErrForbidden, permission)
+	}
+	return nil
+}
+
+// Authorize checks that actor may act on something owned by ownerID: owners
+// always may, anyone else needs permission.
+func (s *PolicyService) Authorize(ctx context.Context, actor *models.Principal, ownerID int, permission string) error {
+	if actor.UserID == ownerID {
+		return nil
+	}
+	return s.Require(ctx, actor, permission)
+}
+
+// ListRoles returns every role. The admin role is listed with every
+// permission.
+func (s *PolicyService) ListRoles(ctx context.Context) ([]*models.Role, error) {
+	roles, err := s.roleRepo.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		fillAdminRole(role)
+	}
+	if roles == nil {
+		roles = []*models.Role{}
+	}
+	return roles, nil
+}
+
+func (s *PolicyService) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	role, err := s.roleRepo.FindRole(ctx, name)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	fillAdminRole(role)
+	return role, nil
+}
+
+// SaveRole creates role or replaces the description and permissions of an
+// existing role. Changes apply to the next request of every user holding
+// the role.
+func (s *PolicyService) SaveRole(ctx context.Context, role *models.Role) error {
+	if !roleNamePattern.MatchString(role.Name) {
+		return ErrInvalidRoleName
+	}
+	if role.Name == models.RoleAdmin {
+		return ErrAdminRole
+	}
+	for _, permission := range role.Permissions {
+		if !slices.Contains(models.Permissions, permission) {
+			return fmt.Errorf("%w %q", ErrUnknownPermission, permission)
+		}
+	}
+	role.Permissions = slices.Compact(slices.Sorted(slices.Values(role.Permissions)))
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	return s.roleRepo.SaveRole(ctx, role)
+}
+
+// DeleteRole deletes a role nobody holds. The built-in roles cannot be
+// deleted.
+func (s *PolicyService) DeleteRole(ctx context.Context, name string) error {
+	if name == models.RoleAdmin || name == models.RoleUser {
+		return ErrBuiltinRole
+	}
+	if _, err := s.GetRole(ctx, name); err != nil {
+		return err
+	}
+	holders, err := s.userRepo.ListUsers(ctx, models.UserFilter{Role: name, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(holders) > 0 {
+		return ErrRoleInUse
+	}
+	return s.roleRepo.DeleteRole(ctx, name)
+}
+
+// fillAdminRole lists every permission on the admin role, which holds them
+// without storing them.
+func fillAdminRole(role *models.Role) {
+	if role.Name == models.RoleAdmin {
+		role.Permissions = slices.Clone(models.Permissions)
+	}
+}
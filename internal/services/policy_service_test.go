@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+func newTestPolicy(t *testing.T) (*PolicyService, *repositories.Store) {
+	t.Helper()
+	store := repositories.NewMemoryStore()
+	t.Cleanup(store.Close)
+	return NewPolicyService(store.Roles, store.Users), store
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	ctx := context.Background()
+	policy, _ := newTestPolicy(t)
+	if err := policy.SaveRole(ctx, &models.Role{Name: "support", Permissions: []string{models.PermTodosReadAny}}); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &models.Principal{UserID: 1, Role: models.RoleAdmin}
+	user := &models.Principal{UserID: 2, Role: models.RoleUser}
+	support := &models.Principal{UserID: 3, Role: "support"}
+	tests := []struct {
+		name       string
+		actor      *models.Principal
+		ownerID    int
+		permission string
+		want       error
+	}{
+		{"owner", user, 2, models.PermTodosWriteAny, nil},
+		{"other user", user, 1, models.PermTodosReadAny, ErrForbidden},
+		{"admin", admin, 2, models.PermTodosWriteAny, nil},
+		{"admin holds unlisted permissions", admin, 2, "anything", nil},
+		{"granted permission", support, 2, models.PermTodosReadAny, nil},
+		{"permission not granted", support, 2, models.PermTodosWriteAny, ErrForbidden},
+		{"unknown role", &models.Principal{UserID: 4, Role: "gone"}, 2, models.PermTodosReadAny, ErrForbidden},
+	}
+	for _, tt := range tests {
+		if err := policy.Authorize(ctx, tt.actor, tt.ownerID, tt.permission); !errors.Is(err, tt.want) {
+			t.Errorf("Authorize(%s) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPolicySaveRole(t *testing.T) {
+	ctx := context.Background()
+	policy, _ := newTestPolicy(t)
+	tests := []struct {
+		name string
+		role *models.Role
+		want error
+	}{
+		{"valid", &models.Role{Name: "support_2", Permissions: []string{models.PermUsersRead}}, nil},
+		{"no permissions", &models.Role{Name: "viewer"}, nil},
+		{"name too short", &models.Role{Name: "a"}, ErrInvalidRoleName},
+		{"uppercase name", &models.Role{Name: "Support"}, ErrInvalidRoleName},
+		{"admin", &models.Role{Name: models.RoleAdmin}, ErrAdminRole},
+		{"unknown permission", &models.Role{Name: "support", Permissions: []string{"todos:delete:all"}}, ErrUnknownPermission},
+	}
+	for _, tt := range tests {
+		if err := policy.SaveRole(ctx, tt.role); !errors.Is(err, tt.want) {
+			t.Errorf("SaveRole(%s) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	role := &models.Role{Name: "support", Permissions: []string{
+		models.PermUsersRead, models.PermTodosReadAny, models.PermUsersRead,
+	}}
+	if err := policy.SaveRole(ctx, role); err != nil {
+		t.Fatal(err)
+	}
+	got, err := policy.GetRole(ctx, "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{models.PermTodosReadAny, models.PermUsersRead}; !slices.Equal(got.Permissions, want) {
+		t.Errorf("Permissions = %v, want %v sorted without duplicates", got.Permissions, want)
+	}
+
+	// Saving an existing role replaces its permissions.
+	if err := policy.SaveRole(ctx, &models.Role{Name: "support"}); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := policy.Can(ctx, &models.Principal{UserID: 1, Role: "support"}, models.PermUsersRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("permission kept after the role was saved without it")
+	}
+
+	admin, err := policy.GetRole(ctx, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(admin.Permissions, models.Permissions) {
+		t.Errorf("admin Permissions = %v, want every permission", admin.Permissions)
+	}
+	if _, err := policy.GetRole(ctx, "gone"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("GetRole(gone) = %v, want ErrRoleNotFound", err)
+	}
+}
+
+func TestPolicyDeleteRole(t *testing.T) {
+	ctx := context.Background()
+	policy, store := newTestPolicy(t)
+	for _, name := range []string{"support", "viewer"} {
+		if err := policy.SaveRole(ctx, &models.Role{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Users.CreateUser(ctx, &models.User{Username: "alice", Role: "support"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{models.RoleAdmin, ErrBuiltinRole},
+		{models.RoleUser, ErrBuiltinRole},
+		{"gone", ErrRoleNotFound},
+		{"support", ErrRoleInUse},
+		{"viewer", nil},
+	}
+	for _, tt := range tests {
+		if err := policy.DeleteRole(ctx, tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("DeleteRole(%s) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := policy.GetRole(ctx, "viewer"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("GetRole(viewer) after DeleteRole = %v, want ErrRoleNotFound", err)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+// ErrProjectNotFound is returned when a project does not exist or belongs
+// to someone other than the todo's owner.
+var ErrProjectNotFound = errors.New("project not found")
+
+type ProjectService struct {
+	projectRepo repositories.ProjectRepository
+}
+
+func NewProjectService(projectRepo repositories.ProjectRepository) *ProjectService {
+	return &ProjectService{projectRepo: projectRepo}
+}
+
+func (s *ProjectService) CreateProject(ctx context.Context, project *models.Project) error {
+	if err := validateProject(project); err != nil {
+		return err
+	}
+	project.Inbox = false
+	return s.projectRepo.CreateProject(ctx, project)
+}
+
+func (s *ProjectService) GetProjectByID(ctx context.Context, id int) (*models.Project, error) {
+	return s.projectRepo.FindProjectByID(ctx, id)
+}
+
+// GetProjectsByUserID lists the user's projects, creating the Inbox first
+// if the user has none yet.
+func (s *ProjectService) GetProjectsByUserID(ctx context.Context, userID int, includeArchived bool) ([]*models.Project, error) {
+	if _, err := ensureInbox(ctx, s.projectRepo, userID); err != nil {
+		return nil, err
+	}
+	projects, err := s.projectRepo.ListProjectsByUserID(ctx, userID, includeArchived)
+	if projects == nil {
+		projects = []*models.Project{}
+	}
+	return projects, err
+}
+
+// UpdateProject replaces the editable fields of the stored project with
+// those of project. The Inbox can be renamed but not archived.
+func (s *ProjectService) UpdateProject(ctx context.Context, project *models.Project) error {
+	if err := validateProject(project); err != nil {
+		return err
+	}
+	existing, err := s.projectRepo.FindProjectByID(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+	if existing.Inbox && project.Archived {
+		return errors.New("the inbox cannot be archived")
+	}
+	project.UserID = existing.UserID
+	project.Inbox = existing.Inbox
+	project.CreatedAt = existing.CreatedAt
+	return s.projectRepo.UpdateProject(ctx, project)
+}
+
+// DeleteProject deletes a project and moves its todos to the owner's Inbox.
+func (s *ProjectService) DeleteProject(ctx context.Context, project *models.Project) error {
+	if project.Inbox {
+		return errors.New("the inbox cannot be deleted")
+	}
+	inbox, err := ensureInbox(ctx, s.projectRepo, project.UserID)
+	if err != nil {
+		return err
+	}
+	return s.projectRepo.DeleteProject(ctx, project.ID, inbox.ID)
+}
+
+// ensureInbox returns the user's Inbox, creating it for accounts that
+// predate projects or whose Inbox creation failed at registration.
+func ensureInbox(ctx context.Context, repo repositories.ProjectRepository, userID int) (*models.Project, error) {
+	inbox, err := repo.FindInbox(ctx, userID)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return inbox, err
+	}
+	inbox = &models.Project{UserID: userID, Name: models.InboxProjectName, Inbox: true}
+	err = repo.CreateProject(ctx, inbox)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		// Created concurrently by another request.
+		return repo.FindInbox(ctx, userID)
+	}
+	return inbox, err
+}
+
+func validateProject(project *models.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	if project.Name == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(project.Name)) > 100 {
+		return errors.New("name must be at most 100 characters")
+	}
+	if project.Color != "" && !colorRe.MatchString(project.Color) {
+		return errors.New("color must be a hex color like #1e90ff")
+	}
+	return nil
+}
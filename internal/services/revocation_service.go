@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+// revocationSyncInterval is how often the revocation cache is reloaded from
+// storage, which also picks up revocations made by other instances.
+const revocationSyncInterval = time.Minute
+
+// RevocationService decides whether an access token has been revoked.
+// Revocations are written through to storage and checked against an
+// in-process cache, so the check on every request needs no query. Expired
+// revocations are swept from storage when the cache is reloaded.
+type RevocationService struct {
+	repo      repositories.TokenRevocationRepository
+	accessTTL time.Duration
+
+	syncMu   sync.Mutex
+	lastSync time.Time
+
+	mu sync.RWMutex
+	// tokens maps revoked JTIs to when the tokens expire.
+	tokens map[string]time.Time
+	// sessions maps the IDs of revoked logins to when their tokens expire.
+	sessions map[string]time.Time
+	// cutoffs maps user IDs to the revocation of all their tokens issued
+	// up to a point in time.
+	cutoffs map[int]*models.TokenRevocation
+}
+
+func NewRevocationService(repo repositories.TokenRevocationRepository, accessTTL time.Duration) *RevocationService {
+	return &RevocationService{
+		repo:      repo,
+		accessTTL: accessTTL,
+		tokens:    make(map[string]time.Time),
+		sessions:  make(map[string]time.Time),
+		cutoffs:   make(map[int]*models.TokenRevocation),
+	}
+}
+
+// RevokeToken revokes the single token described by claims.
+func (s *RevocationService) RevokeToken(ctx context.Context, claims *utils.Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	rev := &models.TokenRevocation{UserID: claims.UserID, JTI: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	if err := s.repo.SaveTokenRevocation(ctx, rev); err != nil {
+		return err
+	}
+	s.cache(rev)
+	return nil
+}
+
+// RevokeUserTokens revokes every token of the user issued before now. Token
+// timestamps only have second precision, so the cutoff is the start of the
+// current second: tokens issued later in the same second stay valid, both
+// those issued just after the revocation, such as a fresh login or the
+// session kept by a password change, and those issued up to a second
+// before it.
+func (s *RevocationService) RevokeUserTokens(ctx context.Context, userID int) error {
+	now := time.Now().Truncate(time.Second)
+	rev := &models.TokenRevocation{UserID: userID, IssuedBefore: &now, ExpiresAt: now.Add(s.accessTTL + time.Second)}
+	if err := s.repo.SaveTokenRevocation(ctx, rev); err != nil {
+		return err
+	}
+	s.cache(rev)
+	return nil
+}
+
+// RevokeSessions revokes every token of the user issued from the logins
+// sessionIDs, as named by their refresh token families.
+func (s *RevocationService) RevokeSessions(ctx context.Context, userID int, sessionIDs []string) error {
+	expiresAt := time.Now().Add(s.accessTTL + time.Second)
+	for _, id := range sessionIDs {
+		rev := &models.TokenRevocation{UserID: userID, SessionID: id, ExpiresAt: expiresAt}
+		if err := s.repo.SaveTokenRevocation(ctx, rev); err != nil {
+			return err
+		}
+		s.cache(rev)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token described by claims has been revoked.
+func (s *RevocationService) IsRevoked(ctx context.Context, claims *utils.Claims) bool {
+	s.sync(ctx)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tokens[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+	if _, ok := s.sessions[claims.SessionID]; ok && claims.SessionID != "" {
+		return true
+	}
+	cutoff, ok := s.cutoffs[claims.UserID]
+	return ok && (claims.IssuedAt == nil || claims.IssuedAt.Before(*cutoff.IssuedBefore))
+}
+
+func (s *RevocationService) cache(rev *models.TokenRevocation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rev.JTI != "" {
+		s.tokens[rev.JTI] = rev.ExpiresAt
+		return
+	}
+	if rev.SessionID != "" {
+		s.sessions[rev.SessionID] = rev.ExpiresAt
+		return
+	}
+	if cutoff, ok := s.cutoffs[rev.UserID]; !ok || rev.IssuedBefore.After(*cutoff.IssuedBefore) {
+		s.cutoffs[rev.UserID] = rev
+	}
+}
+
+// sync merges the revocations in storage into the cache once
+// revocationSyncInterval has passed, and drops expired revocations from
+// both. On failure the reload is retried on the next check.
+func (s *RevocationService) sync(ctx context.Context) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSync) < revocationSyncInterval {
+		return
+	}
+	if err := s.repo.DeleteExpiredTokenRevocations(ctx, now); err != nil {
+		log.Printf("Failed to sweep token revocations: %v", err)
+	}
+	revs, err := s.repo.ListTokenRevocations(ctx, now)
+	if err != nil {
+		log.Printf("Failed to load token revocations: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	for jti, expiresAt := range s.tokens {
+		if !expiresAt.After(now) {
+			delete(s.tokens, jti)
+		}
+	}
+	for id, expiresAt := range s.sessions {
+		if !expiresAt.After(now) {
+			delete(s.sessions, id)
+		}
+	}
+	for userID, cutoff := range s.cutoffs {
+		if !cutoff.ExpiresAt.After(now) {
+			delete(s.cutoffs, userID)
+		}
+	}
+	s.mu.Unlock()
+	for _, rev := range revs {
+		s.cache(rev)
+	}
+	s.lastSync = now
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// countingRevocations counts how often the revocations are loaded.
+type countingRevocations struct {
+	repositories.TokenRevocationRepository
+	loads int
+}
+
+func (r *countingRevocations) ListTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error) {
+	r.loads++
+	return r.TokenRevocationRepository.ListTokenRevocations(ctx, now)
+}
+
+func newTestRevocations(t *testing.T) (*RevocationService, *countingRevocations) {
+	t.Helper()
+	store := repositories.NewMemoryStore()
+	t.Cleanup(store.Close)
+	repo := &countingRevocations{TokenRevocationRepository: store.TokenRevocations}
+	return NewRevocationService(repo, time.Minute), repo
+}
+
+func testClaims(userID int, jti, sessionID string, issuedAt time.Time) *utils.Claims {
+	return &utils.Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Minute)),
+		},
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestRevocations(t)
+	now := time.Now()
+	revoked := testClaims(1, "jti-1", "session-1", now)
+	if err := s.RevokeToken(ctx, revoked); err != nil {
+		t.Fatal(err)
+	}
+	// Tokens without an ID cannot be told apart, so none is revoked.
+	if err := s.RevokeToken(ctx, testClaims(1, "", "session-1", now)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		want   bool
+	}{
+		{"revoked token", revoked, true},
+		{"same session", testClaims(1, "jti-2", "session-1", now), false},
+		{"token without an ID", testClaims(1, "", "session-1", now), false},
+	}
+	for _, tt := range tests {
+		if got := s.IsRevoked(ctx, tt.claims); got != tt.want {
+			t.Errorf("IsRevoked(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestRevocations(t)
+	now := time.Now()
+	if err := s.RevokeSessions(ctx, 1, []string{"session-1", "session-2"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		want   bool
+	}{
+		{"first session", testClaims(1, "jti-1", "session-1", now), true},
+		{"second session", testClaims(1, "jti-2", "session-2", now.Add(time.Hour)), true},
+		{"another session", testClaims(1, "jti-3", "session-3", now), false},
+		// Personal access tokens have no session.
+		{"no session", testClaims(1, "jti-4", "", now), false},
+	}
+	for _, tt := range tests {
+		if got := s.IsRevoked(ctx, tt.claims); got != tt.want {
+			t.Errorf("IsRevoked(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestRevocations(t)
+	if err := s.RevokeUserTokens(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.RLock()
+	second := *s.cutoffs[1].IssuedBefore
+	s.mu.RUnlock()
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		want   bool
+	}{
+		{"issued a second before", testClaims(1, "jti-1", "session-1", second.Add(-time.Second)), true},
+		{"issued in the same second", testClaims(1, "jti-2", "session-1", second), false},
+		{"issued later", testClaims(1, "jti-3", "session-1", second.Add(time.Second)), false},
+		{"another user", testClaims(2, "jti-4", "session-2", second.Add(-time.Second)), false},
+	}
+	for _, tt := range tests {
+		if got := s.IsRevoked(ctx, tt.claims); got != tt.want {
+			t.Errorf("IsRevoked(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRevocationCache(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestRevocations(t)
+	// Another instance of the API sharing the storage.
+	other := NewRevocationService(repo.TokenRevocationRepository, time.Minute)
+	claims := testClaims(1, "jti-1", "session-1", time.Now())
+
+	for range 3 {
+		if s.IsRevoked(ctx, claims) {
+			t.Fatal("IsRevoked() before any revocation")
+		}
+	}
+	if repo.loads != 1 {
+		t.Errorf("revocations loaded %d times, want once until revocationSyncInterval passes", repo.loads)
+	}
+
+	if err := other.RevokeToken(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	if !other.IsRevoked(ctx, claims) {
+		t.Error("IsRevoked() on the revoking instance = false")
+	}
+	if s.IsRevoked(ctx, claims) {
+		t.Error("revocation of another instance seen before the cache is reloaded")
+	}
+	s.lastSync = time.Now().Add(-revocationSyncInterval)
+	if !s.IsRevoked(ctx, claims) {
+		t.Error("revocation of another instance not seen after the cache is reloaded")
+	}
+}
+
+func TestRevocationCacheDropsExpired(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestRevocations(t)
+	claims := testClaims(1, "jti-1", "session-1", time.Now().Add(-2*time.Minute))
+	if err := s.RevokeToken(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.RLock()
+	_, cached := s.tokens["jti-1"]
+	s.mu.RUnlock()
+	if !cached {
+		t.Fatal("revocation not cached")
+	}
+
+	// The token has expired, so its revocation is no longer needed.
+	s.IsRevoked(ctx, claims)
+	s.mu.RLock()
+	_, cached = s.tokens["jti-1"]
+	s.mu.RUnlock()
+	if cached {
+		t.Error("expired revocation kept in the cache")
+	}
+	revs, err := repo.ListTokenRevocations(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rev := range revs {
+		if rev.JTI == "jti-1" {
+			t.Error("expired revocation kept in storage")
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+)
+
+var colorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type TagService struct {
+	tagRepo repositories.TagRepository
+}
+
+func NewTagService(tagRepo repositories.TagRepository) *TagService {
+	return &TagService{tagRepo: tagRepo}
+}
+
+func (s *TagService) CreateTag(ctx context.Context, tag *models.Tag) error {
+	if err := validateTag(tag); err != nil {
+		return err
+	}
+	err := s.tagRepo.CreateTag(ctx, tag)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return errors.New("tag name already exists")
+	}
+	return err
+}
+
+func (s *TagService) GetTagByID(ctx context.Context, id int) (*models.Tag, error) {
+	return s.tagRepo.FindTagByID(ctx, id)
+}
+
+func (s *TagService) GetTagsByUserID(ctx context.Context, userID int) ([]*models.Tag, error) {
+	tags, err := s.tagRepo.ListTagsByUserID(ctx, userID)
+	if tags == nil {
+		tags = []*models.Tag{}
+	}
+	return tags, err
+}
+
+func (s *TagService) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	if err := validateTag(tag); err != nil {
+		return err
+	}
+	err := s.tagRepo.UpdateTag(ctx, tag)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return errors.New("tag name already exists")
+	}
+	return err
+}
+
+func (s *TagService) DeleteTag(ctx context.Context, id int) error {
+	return s.tagRepo.DeleteTag(ctx, id)
+}
+
+func validateTag(tag *models.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(tag.Name)) > 50 {
+		return errors.New("name must be at most 50 characters")
+	}
+	if tag.Color != "" && !colorRe.MatchString(tag.Color) {
+		return errors.New("color must be a hex color like #1e90ff")
+	}
+	return nil
+}
@@ -2,42 +2,879 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/recurrence"
 	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/search"
 )
 
+const (
+	// DefaultPageSize is the page size used when a listing sets no limit.
+	DefaultPageSize = 50
+	// MaxPageSize caps the page size a client may request.
+	MaxPageSize = 200
+)
+
+var (
+	ErrInvalidCursor = repositories.ErrInvalidCursor
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidView   = errors.New("invalid view")
+	ErrInvalidTZ     = errors.New("invalid timezone")
+)
+
+var sortableFields = map[string]bool{
+	models.TodoSortCreatedAt: true,
+	models.TodoSortTitle:     true,
+	models.TodoSortCompleted: true,
+	models.TodoSortDueAt:     true,
+	models.TodoSortPriority:  true,
+}
+
+// ErrTagNotFound is returned when a tag does not exist or belongs to someone
+// other than the todo's owner.
+var ErrTagNotFound = errors.New("tag not found")
+
+var (
+	// ErrParentNotFound is returned when a parent todo does not exist or
+	// belongs to someone other than the subtask's owner.
+	ErrParentNotFound = errors.New("parent todo not found")
+	// ErrTodoCycle is returned when re-parenting would make a todo its own
+	// ancestor.
+	ErrTodoCycle = errors.New("a todo cannot be moved under itself or one of its subtasks")
+)
+
+var (
+	// ErrBlockerNotFound is returned when a blocking todo does not exist or
+	// belongs to someone other than the blocked todo's owner.
+	ErrBlockerNotFound = errors.New("blocking todo not found")
+	// ErrDependencyCycle is returned when a dependency would make a todo
+	// block itself, directly or through other todos.
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrTodoBlocked is returned when completing a todo that still has open
+	// blockers.
+	ErrTodoBlocked = errors.New("todo has open blockers")
+)
+
+var (
+	// ErrNotRecurring is returned when a series operation is applied to a
+	// todo without a recurrence rule.
+	ErrNotRecurring = errors.New("todo does not recur")
+	// ErrSeriesEnded is returned when a recurring todo has no occurrence
+	// left to move to.
+	ErrSeriesEnded = errors.New("recurrence has no further occurrences")
+)
+
+// MaxOccurrencePreview caps how many occurrences PreviewOccurrences returns.
+const MaxOccurrencePreview = 100
+
 type TodoService struct {
-	todoRepo *repositories.TodoRepository
+	todoRepo    repositories.TodoRepository
+	userRepo    repositories.UserRepository
+	tagRepo     repositories.TagRepository
+	projectRepo repositories.ProjectRepository
+	depRepo     repositories.DependencyRepository
+	policy      *PolicyService
 }
 
-func NewTodoService(todoRepo *repositories.TodoRepository) *TodoService {
-	return &TodoService{todoRepo: todoRepo}
+func NewTodoService(todoRepo repositories.TodoRepository, userRepo repositories.UserRepository,
+	tagRepo repositories.TagRepository, projectRepo repositories.ProjectRepository,
+	depRepo repositories.DependencyRepository, policy *PolicyService) *TodoService {
+	return &TodoService{todoRepo: todoRepo, userRepo: userRepo, tagRepo: tagRepo, projectRepo: projectRepo, depRepo: depRepo, policy: policy}
 }
 
 func (s *TodoService) CreateTodo(ctx context.Context, todo *models.Todo) error {
-	if todo.Title == "" {
-		return errors.New("title is required")
+	if err := validateTodo(todo); err != nil {
+		return err
+	}
+	if todo.ParentID != nil && *todo.ParentID == 0 {
+		todo.ParentID = nil
+	}
+	if todo.ParentID != nil {
+		parent, err := s.todoRepo.FindTodoByID(ctx, *todo.ParentID)
+		if err != nil || parent.UserID != todo.UserID {
+			return ErrParentNotFound
+		}
+		// Subtasks are filed with their parent unless told otherwise.
+		if todo.ProjectID == 0 {
+			todo.ProjectID = parent.ProjectID
+		}
+	}
+	if err := s.resolveProject(ctx, todo); err != nil {
+		return err
+	}
+	todo.Tags = nil
+	todo.CompletedAt = nil
+	if todo.Completed {
+		now := time.Now()
+		todo.CompletedAt = &now
 	}
-	return s.todoRepo.CreateTodo(ctx, todo)
+	todo.SeriesID = nil
+	todo.Occurrence = 0
+	if todo.Recurrence != "" {
+		todo.Occurrence = 1
+	}
+	if err := s.todoRepo.CreateTodo(ctx, todo); err != nil {
+		return err
+	}
+	if todo.Recurrence != "" {
+		// A series is identified by its first todo.
+		todo.SeriesID = &todo.ID
+		if err := s.todoRepo.UpdateTodo(ctx, todo); err != nil {
+			return err
+		}
+	}
+	return s.rollUp(ctx, todo.ParentID)
 }
 
-func (s *TodoService) GetTodoByID(ctx context.Context, id int) (*models.Todo, error) {
-	return s.todoRepo.FindTodoByID(ctx, id)
+// GetTodo returns the todo with the given ID if actor may act on it. Todos
+// of other users need permission, todos:read:any to look at them or
+// todos:write:any to change them.
+func (s *TodoService) GetTodo(ctx context.Context, actor *models.Principal, id int, permission string) (*models.Todo, error) {
+	todo, err := s.todoRepo.FindTodoByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, actor, todo.UserID, permission); err != nil {
+		return nil, err
+	}
+	return todo, s.loadTags(ctx, todo)
 }
 
 func (s *TodoService) GetTodosByUserID(ctx context.Context, userID int) ([]*models.Todo, error) {
-	return s.todoRepo.FindTodosByUserID(ctx, userID)
+	todos, err := s.todoRepo.FindTodosByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return todos, s.loadTags(ctx, todos...)
 }
 
-func (s *TodoService) UpdateTodo(ctx context.Context, todo *models.Todo) error {
-	if todo.Title == "" {
-		return errors.New("title is required")
+// PlanningView resolves a planning view for the given user. Day boundaries
+// are computed in tz, or in the user's own timezone when tz is empty.
+func (s *TodoService) PlanningView(ctx context.Context, userID int, name, tz string) (*models.TodoView, error) {
+	switch name {
+	case models.TodoViewToday, models.TodoViewUpcoming, models.TodoViewOverdue, models.TodoViewSomeday:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidView, name)
+	}
+
+	if tz == "" {
+		user, err := s.userRepo.FindUserByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		tz = user.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTZ, tz)
+	}
+
+	now := time.Now().In(loc)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	return &models.TodoView{
+		Name:     name,
+		Now:      now,
+		DayStart: dayStart,
+		DayEnd:   dayStart.AddDate(0, 0, 1),
+	}, nil
+}
+
+// ListTodos returns one page of the todos selected by opts. cursor is the
+// next_cursor of the previous page, or empty for the first page.
+func (s *TodoService) ListTodos(ctx context.Context, opts models.TodoListOptions, cursor string) (*models.TodoPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = models.TodoSortCreatedAt
+	}
+	if !sortableFields[opts.Sort] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSort, opts.Sort)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+	if opts.Limit > MaxPageSize {
+		opts.Limit = MaxPageSize
+	}
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Sort != opts.Sort || after.Desc != opts.Desc {
+			return nil, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+		}
+		opts.After = after
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	limit := opts.Limit
+	opts.Limit++
+	todos, err := s.todoRepo.ListTodos(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TodoPage{Todos: todos}
+	if len(todos) > limit {
+		page.Todos = todos[:limit]
+		last := page.Todos[limit-1]
+		page.NextCursor = encodeCursor(&models.TodoCursor{
+			Sort:  opts.Sort,
+			Desc:  opts.Desc,
+			Value: last.SortValue(opts.Sort),
+			ID:    last.ID,
+		})
+	}
+	if page.Todos == nil {
+		page.Todos = []*models.Todo{}
+	}
+	return page, s.loadTags(ctx, page.Todos...)
+}
+
+// SearchTodos runs a full text search over the todos selected by filter and
+// returns at most limit results, best match first. Callers without
+// todos:read:any only search their own todos.
+func (s *TodoService) SearchTodos(ctx context.Context, actor *models.Principal, query string, filter models.TodoFilter, limit int) ([]*models.TodoSearchResult, error) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if filter.UserID == nil || *filter.UserID != actor.UserID {
+		all, err := s.policy.Can(ctx, actor, models.PermTodosReadAny)
+		if err != nil {
+			return nil, err
+		}
+		if !all {
+			filter.UserID = &actor.UserID
+		}
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	results, err := s.todoRepo.SearchTodos(ctx, q, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []*models.TodoSearchResult{}
+	}
+	todos := make([]*models.Todo, len(results))
+	for i, r := range results {
+		todos[i] = r.Todo
+	}
+	return results, s.loadTags(ctx, todos...)
+}
+
+// TodoStatsByUser returns per-user todo counts.
+func (s *TodoService) TodoStatsByUser(ctx context.Context) ([]*models.UserTodoStats, error) {
+	return s.todoRepo.TodoStatsByUser(ctx)
+}
+
+// UpdateTodo replaces the editable fields of the stored todo with those of
+// todo. Ownership and creation time are kept, and completed_at is tracked
+// from the completion state rather than taken from the caller. A non-zero
+// ProjectID moves the todo to that project. A nil ParentID keeps the current
+// parent and a zero one makes the todo top level. Roll-up rules of the todo
+// and of its ancestors are applied afterwards. A todo with open blockers
+// cannot be completed unless force is set, which takes
+// todos:complete:force. Completing a recurring todo hands its rule over to a
+// newly created next occurrence. Todos of other users take todos:write:any.
+func (s *TodoService) UpdateTodo(ctx context.Context, actor *models.Principal, todo *models.Todo, force bool) error {
+	existing, err := s.todoRepo.FindTodoByID(ctx, todo.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.policy.Authorize(ctx, actor, existing.UserID, models.PermTodosWriteAny); err != nil {
+		return err
+	}
+	if force {
+		if err := s.policy.Require(ctx, actor, models.PermTodosForceComplete); err != nil {
+			return err
+		}
+	}
+	if err := validateTodo(todo); err != nil {
+		return err
+	}
+	todo.UserID = existing.UserID
+	todo.CreatedAt = existing.CreatedAt
+	todo.SeriesID = existing.SeriesID
+	todo.Occurrence = existing.Occurrence
+	if todo.Recurrence != "" && todo.SeriesID == nil {
+		todo.SeriesID = &todo.ID
+		todo.Occurrence = 1
+	}
+	if todo.ProjectID == 0 {
+		todo.ProjectID = existing.ProjectID
+	}
+	if err := s.resolveProject(ctx, todo); err != nil {
+		return err
+	}
+	if err := s.resolveParent(ctx, todo, existing); err != nil {
+		return err
+	}
+	if todo.Completed && !existing.Completed && !force {
+		blocked, err := s.hasOpenBlockers(ctx, todo.ID, nil)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrTodoBlocked
+		}
+	}
+	var subtasks []*models.Todo
+	if todo.Completed && !existing.Completed && todo.RollUp == models.RollUpCascade {
+		if subtasks, err = s.openSubtasks(ctx, todo, force); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case !todo.Completed:
+		todo.CompletedAt = nil
+	case existing.Completed:
+		todo.CompletedAt = existing.CompletedAt
+	default:
+		now := time.Now()
+		todo.CompletedAt = &now
+	}
+
+	var next *models.Todo
+	if todo.Completed && !existing.Completed && todo.Recurrence != "" {
+		if next, err = s.nextOccurrence(ctx, todo); err != nil {
+			return err
+		}
+		todo.Recurrence = ""
+	}
+
+	if err := s.todoRepo.UpdateTodo(ctx, todo); err != nil {
+		return err
+	}
+	if next != nil {
+		if err := s.createOccurrence(ctx, todo, next); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range subtasks {
+		if err := s.complete(ctx, t, *todo.CompletedAt); err != nil {
+			return err
+		}
+	}
+	if err := s.rollUp(ctx, todo.ParentID); err != nil {
+		return err
+	}
+	if existing.ParentID != nil && (todo.ParentID == nil || *todo.ParentID != *existing.ParentID) {
+		if err := s.rollUp(ctx, existing.ParentID); err != nil {
+			return err
+		}
+	}
+	return s.loadTags(ctx, todo)
+}
+
+// resolveParent settles the parent of an updated todo, refusing parents
+// owned by someone else and moves that would create a cycle.
+func (s *TodoService) resolveParent(ctx context.Context, todo, existing *models.Todo) error {
+	switch {
+	case todo.ParentID == nil:
+		todo.ParentID = existing.ParentID
+		return nil
+	case *todo.ParentID == 0:
+		todo.ParentID = nil
+		return nil
+	case existing.ParentID != nil && *todo.ParentID == *existing.ParentID:
+		return nil
+	}
+
+	parent, err := s.todoRepo.FindTodoByID(ctx, *todo.ParentID)
+	if err != nil || parent.UserID != todo.UserID {
+		return ErrParentNotFound
+	}
+	subtree, err := s.todoRepo.FindTodoTree(ctx, todo.ID)
+	if err != nil {
+		return err
+	}
+	for _, t := range subtree {
+		if t.ID == parent.ID {
+			return ErrTodoCycle
+		}
+	}
+	return nil
+}
+
+// openSubtasks returns the open subtasks of todo, at any depth, that
+// completing it cascades to. Unless force is set, a subtask with open
+// blockers outside the subtree fails with ErrTodoBlocked before anything is
+// completed.
+func (s *TodoService) openSubtasks(ctx context.Context, todo *models.Todo, force bool) ([]*models.Todo, error) {
+	subtree, err := s.todoRepo.FindTodoTree(ctx, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+	var open []*models.Todo
+	completing := map[int]bool{todo.ID: true}
+	for _, t := range subtree {
+		if t.ID != todo.ID && !t.Completed {
+			open = append(open, t)
+			completing[t.ID] = true
+		}
+	}
+	if force {
+		return open, nil
+	}
+	for _, t := range open {
+		blocked, err := s.hasOpenBlockers(ctx, t.ID, completing)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, fmt.Errorf("%w: subtask %d is blocked", ErrTodoBlocked, t.ID)
+		}
+	}
+	return open, nil
+}
+
+// complete marks the stored, open todo completed at the given time. A
+// recurring todo hands its rule over to its next occurrence, as when it is
+// completed through UpdateTodo.
+func (s *TodoService) complete(ctx context.Context, todo *models.Todo, at time.Time) error {
+	todo.Completed = true
+	todo.CompletedAt = &at
+
+	var next *models.Todo
+	if todo.Recurrence != "" {
+		var err error
+		if next, err = s.nextOccurrence(ctx, todo); err != nil {
+			return err
+		}
+		todo.Recurrence = ""
+	}
+	if err := s.todoRepo.UpdateTodo(ctx, todo); err != nil {
+		return err
+	}
+	if next != nil {
+		return s.createOccurrence(ctx, todo, next)
+	}
+	return nil
+}
+
+// hasOpenBlockers reports whether the todo with the given ID is blocked by
+// an open todo, not counting those in completing, which are being completed
+// along with it.
+func (s *TodoService) hasOpenBlockers(ctx context.Context, id int, completing map[int]bool) (bool, error) {
+	blockers, err := s.depRepo.FindBlockers(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range blockers {
+		if !b.Completed && !completing[b.ID] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rollUp walks up from the todo with the given ID, completing or reopening
+// each auto-completing ancestor to match the state of its subtasks. It stops
+// at the first ancestor that is unaffected, and at one that has open
+// blockers, which is left open for its owner to complete.
+func (s *TodoService) rollUp(ctx context.Context, parentID *int) error {
+	for parentID != nil {
+		parent, err := s.todoRepo.FindTodoByID(ctx, *parentID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if parent.RollUp != models.RollUpAutoComplete {
+			return nil
+		}
+
+		children, err := s.todoRepo.ListTodos(ctx, models.TodoListOptions{
+			Filter: models.TodoFilter{ParentID: &parent.ID, IncludeArchived: true},
+		})
+		if err != nil {
+			return err
+		}
+		done := len(children) > 0
+		for _, c := range children {
+			if !c.Completed {
+				done = false
+				break
+			}
+		}
+		if done == parent.Completed {
+			return nil
+		}
+		if done {
+			blocked, err := s.hasOpenBlockers(ctx, parent.ID, nil)
+			if err != nil || blocked {
+				return err
+			}
+		}
+
+		if done {
+			err = s.complete(ctx, parent, time.Now())
+		} else {
+			parent.Completed = false
+			parent.CompletedAt = nil
+			err = s.todoRepo.UpdateTodo(ctx, parent)
+		}
+		if err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// GetTodoTree returns the todo with the given ID and its subtasks, nested,
+// with the progress of every todo that has subtasks.
+func (s *TodoService) GetTodoTree(ctx context.Context, id int) (*models.TodoNode, error) {
+	todos, err := s.todoRepo.FindTodoTree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(todos) == 0 {
+		return nil, repositories.ErrNotFound
+	}
+	if err := s.loadTags(ctx, todos...); err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[int]*models.TodoNode, len(todos))
+	for _, t := range todos {
+		nodes[t.ID] = &models.TodoNode{Todo: t}
+	}
+	// todos is ordered by ID, so children are attached in creation order.
+	for _, t := range todos {
+		if t.ID == id || t.ParentID == nil {
+			continue
+		}
+		if parent, ok := nodes[*t.ParentID]; ok {
+			parent.Children = append(parent.Children, nodes[t.ID])
+		}
+	}
+	root := nodes[id]
+	setProgress(root)
+	return root, nil
+}
+
+// setProgress fills in the progress of node and its descendants and returns
+// the number of completed and total leaf subtasks below node.
+func setProgress(node *models.TodoNode) (done, total int) {
+	if len(node.Children) == 0 {
+		if node.Completed {
+			return 1, 1
+		}
+		return 0, 1
+	}
+	for _, child := range node.Children {
+		d, t := setProgress(child)
+		done += d
+		total += t
+	}
+	progress := done * 100 / total
+	node.Progress = &progress
+	return done, total
+}
+
+// resolveProject files todo in its owner's Inbox when it has no project and
+// otherwise checks that the project belongs to the owner.
+func (s *TodoService) resolveProject(ctx context.Context, todo *models.Todo) error {
+	if todo.ProjectID == 0 {
+		inbox, err := ensureInbox(ctx, s.projectRepo, todo.UserID)
+		if err != nil {
+			return err
+		}
+		todo.ProjectID = inbox.ID
+		return nil
+	}
+	project, err := s.projectRepo.FindProjectByID(ctx, todo.ProjectID)
+	if err != nil || project.UserID != todo.UserID {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// PreviewOccurrences returns up to n occurrences of todo's series that
+// follow todo itself, computed in the owner's timezone.
+func (s *TodoService) PreviewOccurrences(ctx context.Context, todo *models.Todo, n int) ([]*models.TodoOccurrence, error) {
+	if todo.Recurrence == "" {
+		return nil, ErrNotRecurring
 	}
+	if n > MaxOccurrencePreview {
+		n = MaxOccurrencePreview
+	}
+	return s.occurrences(ctx, todo, n)
+}
+
+// SkipOccurrence moves todo on to the next occurrence of its series without
+// completing it.
+func (s *TodoService) SkipOccurrence(ctx context.Context, todo *models.Todo) error {
+	if todo.Recurrence == "" {
+		return ErrNotRecurring
+	}
+	occurrences, err := s.occurrences(ctx, todo, 1)
+	if err != nil {
+		return err
+	}
+	if len(occurrences) == 0 {
+		return ErrSeriesEnded
+	}
+	todo.Occurrence = occurrences[0].Occurrence
+	todo.StartAt = occurrences[0].StartAt
+	todo.DueAt = &occurrences[0].DueAt
 	return s.todoRepo.UpdateTodo(ctx, todo)
 }
 
+// EndSeries removes todo's recurrence rule, so completing it no longer
+// creates another occurrence. The todo stays part of its series.
+func (s *TodoService) EndSeries(ctx context.Context, todo *models.Todo) error {
+	todo.Recurrence = ""
+	return s.todoRepo.UpdateTodo(ctx, todo)
+}
+
+// nextOccurrence returns the todo that follows todo in its series, not yet
+// stored, or nil when the series has ended.
+func (s *TodoService) nextOccurrence(ctx context.Context, todo *models.Todo) (*models.Todo, error) {
+	occurrences, err := s.occurrences(ctx, todo, 1)
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+	return &models.Todo{
+		Title:       todo.Title,
+		Description: todo.Description,
+		Priority:    todo.Priority,
+		StartAt:     occurrences[0].StartAt,
+		DueAt:       &occurrences[0].DueAt,
+		ProjectID:   todo.ProjectID,
+		ParentID:    todo.ParentID,
+		RollUp:      todo.RollUp,
+		Recurrence:  todo.Recurrence,
+		SeriesID:    todo.SeriesID,
+		Occurrence:  occurrences[0].Occurrence,
+		UserID:      todo.UserID,
+	}, nil
+}
+
+// createOccurrence stores next and gives it the tags of prev.
+func (s *TodoService) createOccurrence(ctx context.Context, prev, next *models.Todo) error {
+	if err := s.todoRepo.CreateTodo(ctx, next); err != nil {
+		return err
+	}
+	tags, err := s.tagRepo.FindTagsByTodoIDs(ctx, []int{prev.ID})
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags[prev.ID] {
+		if err := s.tagRepo.AttachTag(ctx, next.ID, tag.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// occurrences expands todo's rule from its due date in the owner's
+// timezone, so that wall clock times survive daylight saving changes. The
+// start date keeps its distance to the due date, and COUNT is measured
+// from the first todo of the series.
+func (s *TodoService) occurrences(ctx context.Context, todo *models.Todo, n int) ([]*models.TodoOccurrence, error) {
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	if todo.DueAt == nil {
+		return nil, errors.New("a recurring todo needs a due date")
+	}
+	if rule.Count > 0 {
+		n = min(n, rule.Count-todo.Occurrence)
+	}
+	if n <= 0 {
+		return []*models.TodoOccurrence{}, nil
+	}
+
+	user, err := s.userRepo.FindUserByID(ctx, todo.UserID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	instances := rule.After(todo.DueAt.In(loc), n)
+	occurrences := make([]*models.TodoOccurrence, len(instances))
+	for i, due := range instances {
+		o := &models.TodoOccurrence{Occurrence: todo.Occurrence + i + 1, DueAt: due}
+		if todo.StartAt != nil {
+			start := due.Add(todo.StartAt.Sub(*todo.DueAt))
+			o.StartAt = &start
+		}
+		occurrences[i] = o
+	}
+	return occurrences, nil
+}
+
+// AddBlocker records that todo is blocked by the todo with the given ID,
+// which must belong to the same user and must not itself depend on todo.
+func (s *TodoService) AddBlocker(ctx context.Context, todo *models.Todo, blockerID int) error {
+	blocker, err := s.todoRepo.FindTodoByID(ctx, blockerID)
+	if err != nil || blocker.UserID != todo.UserID {
+		return ErrBlockerNotFound
+	}
+	if blocker.ID == todo.ID {
+		return ErrDependencyCycle
+	}
+	cycle, err := s.depRepo.DependsOn(ctx, blocker.ID, todo.ID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrDependencyCycle
+	}
+	if err := s.depRepo.AddDependency(ctx, todo.ID, blocker.ID); err != nil {
+		return err
+	}
+	return s.LoadDependencies(ctx, todo)
+}
+
+// RemoveBlocker removes the todo with the given ID from todo's blockers.
+func (s *TodoService) RemoveBlocker(ctx context.Context, todo *models.Todo, blockerID int) error {
+	if err := s.depRepo.RemoveDependency(ctx, todo.ID, blockerID); err != nil {
+		return err
+	}
+	return s.LoadDependencies(ctx, todo)
+}
+
+// LoadDependencies fills in the todos blocking todo and those it blocks.
+func (s *TodoService) LoadDependencies(ctx context.Context, todo *models.Todo) error {
+	blockers, err := s.depRepo.FindBlockers(ctx, todo.ID)
+	if err != nil {
+		return err
+	}
+	blocking, err := s.depRepo.FindBlocking(ctx, todo.ID)
+	if err != nil {
+		return err
+	}
+	todo.BlockedBy = todoRefs(blockers)
+	todo.Blocking = todoRefs(blocking)
+	return nil
+}
+
+func todoRefs(todos []*models.Todo) []*models.TodoRef {
+	refs := make([]*models.TodoRef, len(todos))
+	for i, t := range todos {
+		refs[i] = &models.TodoRef{ID: t.ID, Title: t.Title, Completed: t.Completed}
+	}
+	return refs
+}
+
+// AttachTag labels todo with the tag. The tag must belong to the todo's
+// owner.
+func (s *TodoService) AttachTag(ctx context.Context, todo *models.Todo, tagID int) error {
+	tag, err := s.tagRepo.FindTagByID(ctx, tagID)
+	if err != nil || tag.UserID != todo.UserID {
+		return ErrTagNotFound
+	}
+	if err := s.tagRepo.AttachTag(ctx, todo.ID, tagID); err != nil {
+		return err
+	}
+	return s.loadTags(ctx, todo)
+}
+
+// DetachTag removes the tag from todo.
+func (s *TodoService) DetachTag(ctx context.Context, todo *models.Todo, tagID int) error {
+	if err := s.tagRepo.DetachTag(ctx, todo.ID, tagID); err != nil {
+		return err
+	}
+	return s.loadTags(ctx, todo)
+}
+
+// loadTags fills in the tags of todos with a single query.
+func (s *TodoService) loadTags(ctx context.Context, todos ...*models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	ids := make([]int, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+	}
+	tags, err := s.tagRepo.FindTagsByTodoIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, t := range todos {
+		t.Tags = tags[t.ID]
+	}
+	return nil
+}
+
+// DeleteTodo deletes a todo together with its subtasks and re-applies the
+// roll-up rule of its parent.
 func (s *TodoService) DeleteTodo(ctx context.Context, id int) error {
-	return s.todoRepo.DeleteTodo(ctx, id)
+	todo, err := s.todoRepo.FindTodoByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.todoRepo.DeleteTodo(ctx, id); err != nil {
+		return err
+	}
+	return s.rollUp(ctx, todo.ParentID)
+}
+
+func validateTodo(todo *models.Todo) error {
+	if todo.Title == "" {
+		return errors.New("title is required")
+	}
+	if todo.Priority < models.PriorityNone || todo.Priority > models.PriorityHigh {
+		return fmt.Errorf("priority must be between %d and %d", models.PriorityNone, models.PriorityHigh)
+	}
+	if todo.StartAt != nil && todo.DueAt != nil && todo.StartAt.After(*todo.DueAt) {
+		return errors.New("start_at must not be after due_at")
+	}
+	switch todo.RollUp {
+	case models.RollUpNone, models.RollUpAutoComplete, models.RollUpCascade:
+	default:
+		return fmt.Errorf("rollup must be one of %q, %q or %q", models.RollUpNone, models.RollUpAutoComplete, models.RollUpCascade)
+	}
+	if todo.Recurrence != "" {
+		rule, err := recurrence.Parse(todo.Recurrence)
+		if err != nil {
+			return err
+		}
+		if todo.DueAt == nil {
+			return errors.New("a recurring todo needs a due date")
+		}
+		todo.Recurrence = rule.String()
+	}
+	return nil
+}
+
+// encodeCursor turns c into the opaque token handed to clients.
+func encodeCursor(c *models.TodoCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*models.TodoCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &models.TodoCursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
 }
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/globallstudent/todo-project-go/internal/models"
+	"github.com/globallstudent/todo-project-go/internal/passhash"
+	"github.com/globallstudent/todo-project-go/internal/passpolicy"
+	"github.com/globallstudent/todo-project-go/internal/repositories"
+	"github.com/globallstudent/todo-project-go/internal/utils"
+)
+
+// MaxUsernameLength is the longest username allowed, in characters.
+const MaxUsernameLength = 50
+
+const (
+	maxDisplayNameLength = 100
+	maxEmailLength       = 254
+	// ssoReauthWindow is how recently users without a password must have
+	// logged in at the single sign-on provider to confirm a change.
+	ssoReauthWindow = 5 * time.Minute
+)
+
+var (
+	// ErrWrongPassword is returned when the current password confirming a
+	// change to the account is not the user's.
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrPasswordRequired is returned when a change to the account needs
+	// the current password and none was given.
+	ErrPasswordRequired = errors.New("current password is required")
+	// ErrReauthRequired is returned when a user without a password confirms
+	// a change to the account with a session that did not just log in at
+	// the single sign-on provider.
+	ErrReauthRequired = errors.New("log in again through single sign-on, with reauth=true, to confirm this change")
+	// ErrUsernameTaken is returned when another account has the username.
+	ErrUsernameTaken = errors.New("username already exists")
+	// ErrEmailTaken is returned when another account has the email address.
+	ErrEmailTaken = errors.New("email address is already in use")
+	// ErrInvalidProfile is returned for profile fields that cannot be
+	// stored.
+	ErrInvalidProfile = errors.New("invalid profile")
+)
+
+// localePattern matches BCP 47 language tags such as "en", "pt-BR" or
+// "zh-Hant-TW", without checking the subtags against the registry.
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// UserService lets users manage their own account. Changes that could lock
+// the owner out, or hand the account to whoever holds a stolen access token,
+// need the current password, or for users without one a fresh login at the
+// single sign-on provider.
+type UserService struct {
+	userRepo    repositories.UserRepository
+	authService *AuthService
+	emails      *EmailService
+	passwords   *passhash.Hasher
+	policy      *passpolicy.Policy
+	throttle    *LoginThrottleService
+}
+
+func NewUserService(userRepo repositories.UserRepository, authService *AuthService, emails *EmailService,
+	passwords *passhash.Hasher, policy *passpolicy.Policy, throttle *LoginThrottleService) *UserService {
+	return &UserService{
+		userRepo:    userRepo,
+		authService: authService,
+		emails:      emails,
+		passwords:   passwords,
+		policy:      policy,
+		throttle:    throttle,
+	}
+}
+
+func (s *UserService) GetProfile(ctx context.Context, userID int) (*models.User, error) {
+	return s.userRepo.FindUserByID(ctx, userID)
+}
+
+// UpdateProfile changes the profile fields set in update for the user
+// claims belong to. As whoever has the email address can reset the
+// password, changing it is confirmed like in ChangePassword; the new address
+// starts out unverified and is mailed a verification link, and the old one,
+// if verified, is told about the change.
+func (s *UserService) UpdateProfile(ctx context.Context, claims *utils.Claims, update models.ProfileUpdate,
+	ip string) (*models.User, error) {
+	user, err := s.userRepo.FindUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	previous := *user
+
+	if update.DisplayName != nil {
+		name := strings.TrimSpace(*update.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return nil, invalidProfile("display_name must be at most %d characters", maxDisplayNameLength)
+		}
+		user.DisplayName = name
+	}
+	if update.Email != nil {
+		email, err := normalizeEmail(*update.Email)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(email, user.Email) {
+			if err := s.confirm(ctx, user, claims, update.CurrentPassword, ip); err != nil {
+				return nil, err
+			}
+			user.EmailVerifiedAt = nil
+		}
+		user.Email = email
+	}
+	if update.Timezone != nil {
+		if _, err := time.LoadLocation(*update.Timezone); err != nil || *update.Timezone == "" {
+			return nil, ErrInvalidTZ
+		}
+		user.Timezone = *update.Timezone
+	}
+	if update.Locale != nil {
+		if !localePattern.MatchString(*update.Locale) || len(*update.Locale) > 35 {
+			return nil, invalidProfile("locale must be a language tag such as en or pt-BR")
+		}
+		user.Locale = *update.Locale
+	}
+
+	err = s.userRepo.UpdateUser(ctx, user)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.emails.Enabled() {
+		return user, nil
+	}
+	if user.Email != "" && user.EmailVerifiedAt == nil && update.Email != nil {
+		if err := s.emails.SendVerification(ctx, user.ID); err != nil {
+			log.Printf("Failed to mail email verification link to user %d: %v", user.ID, err)
+		}
+	}
+	if !strings.EqualFold(user.Email, previous.Email) && previous.EmailVerifiedAt != nil {
+		if err := s.emails.NotifyEmailChanged(ctx, &previous, user.Email); err != nil {
+			log.Printf("Failed to tell user %d about their email change: %v", user.ID, err)
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password of the user claims belong to, once
+// confirmed with current, and ends their other sessions. The session claims
+// were issued from stays logged in. Wrong current passwords count as failed
+// logins of the user from ip. Users without a password set one by
+// confirming with a fresh single sign-on login instead.
+func (s *UserService) ChangePassword(ctx context.Context, claims *utils.Claims, current, password, ip string) error {
+	user, err := s.userRepo.FindUserByID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.confirm(ctx, user, claims, current, ip); err != nil {
+		return err
+	}
+	if err := s.policy.Check(password, user.Username); err != nil {
+		return err
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+	return s.authService.LogoutOthers(ctx, claims)
+}
+
+// ChangeUsername renames the user. Tokens already issued keep working, as
+// they are checked against the account by ID.
+func (s *UserService) ChangeUsername(ctx context.Context, userID int, username string) (*models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Username == username {
+		return user, nil
+	}
+
+	user.Username = username
+	err = s.userRepo.UpdateUser(ctx, user)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, ErrUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteAccount deletes the user claims belong to together with everything
+// they own, once confirmed with password like in ChangePassword. The last
+// enabled admin cannot delete themselves.
+func (s *UserService) DeleteAccount(ctx context.Context, claims *utils.Claims, password, ip string) error {
+	user, err := s.userRepo.FindUserByID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.confirm(ctx, user, claims, password, ip); err != nil {
+		return err
+	}
+	if err := keepAnAdmin(ctx, s.userRepo, user); err != nil {
+		return err
+	}
+	return s.userRepo.DeleteUser(ctx, user.ID)
+}
+
+// confirm checks that the user, not just someone holding the access token
+// of claims, is making a change: with their password, or for users without
+// one, with a login at the single sign-on provider within ssoReauthWindow.
+func (s *UserService) confirm(ctx context.Context, user *models.User, claims *utils.Claims, password, ip string) error {
+	if hasPassword(user) {
+		if password == "" {
+			return ErrPasswordRequired
+		}
+		return s.checkPassword(ctx, user, password, ip)
+	}
+	if claims.SSOAuthTime == nil || time.Since(claims.SSOAuthTime.Time) > ssoReauthWindow {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+// checkPassword fails with ErrWrongPassword unless password is the user's.
+// Like logins, wrong passwords are throttled, so that a stolen access token
+// does not allow guessing the password.
+func (s *UserService) checkPassword(ctx context.Context, user *models.User, password, ip string) error {
+	if err := s.throttle.Check(ctx, user.Username, ip); err != nil {
+		return err
+	}
+	ok, _, err := s.passwords.Verify(password, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := s.throttle.Fail(ctx, user.Username, ip); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// hasPassword reports whether user can log in with a password. Users
+// provisioned for single sign-on have none until they set one.
+func hasPassword(user *models.User) bool {
+	return user.Password != ""
+}
+
+// normalizeUsername checks the length of username and trims it.
+func normalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || utf8.RuneCountInString(username) > MaxUsernameLength {
+		return "", invalidProfile("username must be between 1 and %d characters", MaxUsernameLength)
+	}
+	return username, nil
+}
+
+// normalizeEmail checks that email is a plain address and trims it. An
+// empty email is valid and removes the address.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxEmailLength {
+		return "", invalidProfile("email must be a plain address such as name@example.com")
+	}
+	return email, nil
+}
+
+func invalidProfile(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidProfile}, args...)...)
+}
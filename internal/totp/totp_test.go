@@ -0,0 +1,113 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, the ASCII string
+// "12345678901234567890".
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// TestCodeRFC6238 checks the SHA-1 test vectors of RFC 6238 Appendix B. The
+// RFC lists 8 digit codes; 6 digit codes are their last 6 digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; got != want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestCodeSecretCase(t *testing.T) {
+	upper, err := Code(rfcSecret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := Code(strings.ToLower(rfcSecret), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upper != lower {
+		t.Errorf("lowercase secret gave %s, want %s", lower, upper)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), 0, step, true},
+		{"surrounding spaces", " " + code(step) + " ", 0, step, true},
+		{"previous step within skew", code(step - 1), 1, step - 1, true},
+		{"next step within skew", code(step + 1), 1, step + 1, true},
+		{"previous step without skew", code(step - 1), 0, 0, false},
+		{"beyond skew", code(step + 2), 1, 0, false},
+		{"too short", code(step)[1:], 1, 0, false},
+		{"eight digits", "14050471", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Match(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("Match(%q) = %d, %v, want %d, %v", tt.code, got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != secretSize {
+		t.Errorf("secret has %d bytes, want %d", len(key), secretSize)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Todo API", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/Todo%20API:alice@example.com?algorithm=SHA1&digits=6&issuer=Todo+API&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}
@@ -1,54 +1,248 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
 	"time"
 
+	"github.com/globallstudent/todo-project-go/internal/keyset"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// Claims are the claims of an access token. RegisteredClaims.ID is the
+// token's jti, which identifies it for revocation.
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// SessionID is the refresh token family the token was issued from.
+	SessionID string `json:"sid,omitempty"`
+	// SSOAuthTime is when the user authenticated at the single sign-on
+	// provider, set only on the first token of a login through it.
+	SSOAuthTime *jwt.NumericDate `json:"sso_auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID int, username, role, secret string) (string, error) {
+// GenerateJWT signs an access token for the user with the active key of
+// keys. ssoAuthTime is when the user authenticated at the single sign-on
+// provider to start the session, or zero.
+func GenerateJWT(userID int, username, role, sessionID string, ssoAuthTime time.Time, keys *keyset.Keyset,
+	ttl time.Duration) (string, error) {
+	jti, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+	if !ssoAuthTime.IsZero() {
+		claims.SSOAuthTime = jwt.NewNumericDate(ssoAuthTime)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.Sign(claims)
 }
 
-func ValidateJWT(tokenString, secret string) (*Claims, error) {
+// ValidateJWT verifies an access token against keys and returns its claims.
+func ValidateJWT(tokenString string, keys *keyset.Keyset) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-		return []byte(secret), nil
-	})
+	token, err := keys.Parse(tokenString, claims)
 	if err != nil {
 		return nil, err
 	}
 	if !token.Valid {
 		return nil, jwt.ErrSignatureInvalid
 	}
+	// Access tokens have no audience; MFA tokens must not pass for them.
+	if len(claims.Audience) > 0 {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
 	return claims, nil
 }
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+// mfaAudience is the audience of MFA tokens, which prove that a user passed
+// the password step of a login and still owes a second factor.
+const mfaAudience = "mfa"
+
+// MFAClaims are the claims of an MFA token. SSOAuthTime carries over to
+// the access token of a login through the single sign-on provider.
+type MFAClaims struct {
+	SSOAuthTime *jwt.NumericDate `json:"sso_auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAToken signs an MFA token for userID. Its jti identifies the
+// login attempt.
+func GenerateMFAToken(userID int, ssoAuthTime time.Time, keys *keyset.Keyset, ttl time.Duration) (string, error) {
+	jti, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := MFAClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if !ssoAuthTime.IsZero() {
+		claims.SSOAuthTime = jwt.NewNumericDate(ssoAuthTime)
+	}
+
+	return keys.Sign(claims)
+}
+
+// ValidateMFAToken returns the user ID and claims of a valid MFA token.
+func ValidateMFAToken(tokenString string, keys *keyset.Keyset) (int, *MFAClaims, error) {
+	claims := &MFAClaims{}
+	_, err := keys.Parse(tokenString, claims, jwt.WithAudience(mfaAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, nil, err
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, nil, jwt.ErrTokenInvalidSubject
+	}
+	return userID, claims, nil
+}
+
+// Audiences of the tokens mailed to users.
+const (
+	passwordResetAudience     = "password-reset"
+	emailVerificationAudience = "email-verification"
+)
+
+// MailTokenClaims are the claims of the tokens mailed to users. Binding
+// ties a token to the state of the account it was issued for, so that it
+// stops working once that changes.
+type MailTokenClaims struct {
+	Binding string `json:"bnd"`
+	jwt.RegisteredClaims
+}
+
+// GeneratePasswordResetToken signs a password reset token for userID, bound
+// to a fingerprint of their current password hash.
+func GeneratePasswordResetToken(userID int, fingerprint string, keys *keyset.Keyset, ttl time.Duration) (string, error) {
+	return generateMailToken(passwordResetAudience, userID, fingerprint, keys, ttl)
+}
+
+// ValidatePasswordResetToken returns the user ID and password fingerprint
+// of a valid password reset token.
+func ValidatePasswordResetToken(tokenString string, keys *keyset.Keyset) (int, string, error) {
+	return validateMailToken(passwordResetAudience, tokenString, keys)
+}
+
+// GenerateEmailVerificationToken signs a token verifying that userID owns
+// email.
+func GenerateEmailVerificationToken(userID int, email string, keys *keyset.Keyset, ttl time.Duration) (string, error) {
+	return generateMailToken(emailVerificationAudience, userID, email, keys, ttl)
+}
+
+// ValidateEmailVerificationToken returns the user ID and email address of a
+// valid email verification token.
+func ValidateEmailVerificationToken(tokenString string, keys *keyset.Keyset) (int, string, error) {
+	return validateMailToken(emailVerificationAudience, tokenString, keys)
+}
+
+func generateMailToken(audience string, userID int, binding string, keys *keyset.Keyset, ttl time.Duration) (string, error) {
+	jti, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := MailTokenClaims{
+		Binding: binding,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return keys.Sign(claims)
+}
+
+func validateMailToken(audience, tokenString string, keys *keyset.Keyset) (int, string, error) {
+	claims := &MailTokenClaims{}
+	_, err := keys.Parse(tokenString, claims, jwt.WithAudience(audience), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, "", err
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, "", jwt.ErrTokenInvalidSubject
+	}
+	return userID, claims.Binding, nil
+}
+
+// oidcFlowAudience is the audience of OpenID Connect flow tokens.
+const oidcFlowAudience = "oidc-login"
+
+// OIDCFlowClaims carry the secrets of an OpenID Connect login in progress
+// from its start to the provider's callback: the state expected back with
+// the code, the nonce expected in the ID token and the PKCE code verifier.
+type OIDCFlowClaims struct {
+	State    string `json:"st"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"cv"`
+	jwt.RegisteredClaims
+}
+
+// GenerateOIDCFlowToken signs a flow token for an OpenID Connect login.
+func GenerateOIDCFlowToken(state, nonce, verifier string, keys *keyset.Keyset, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := OIDCFlowClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcFlowAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return keys.Sign(claims)
+}
+
+// ValidateOIDCFlowToken returns the claims of a valid flow token.
+func ValidateOIDCFlowToken(tokenString string, keys *keyset.Keyset) (*OIDCFlowClaims, error) {
+	claims := &OIDCFlowClaims{}
+	_, err := keys.Parse(tokenString, claims, jwt.WithAudience(oidcFlowAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// NewOpaqueToken returns a random URL-safe token with 256 bits of entropy.
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// HashToken returns the hex encoded SHA-256 of an opaque token. Opaque
+// tokens are random enough that a fast unsalted hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
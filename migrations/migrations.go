@@ -0,0 +1,30 @@
+// Package migrations embeds the SQL schema migrations into the binary so
+// they can be applied without shipping the .sql files alongside it.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed postgres/*.sql
+var postgresFS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+var (
+	// Postgres holds the NNNN_name.up.sql / NNNN_name.down.sql pairs for
+	// the Postgres schema.
+	Postgres = mustSub(postgresFS, "postgres")
+	// SQLite holds the equivalent migrations for the SQLite schema.
+	SQLite = mustSub(sqliteFS, "sqlite")
+)
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_user_completed;
+DROP INDEX IF EXISTS idx_todos_user_title;
+DROP INDEX IF EXISTS idx_todos_user_created_at;
@@ -0,0 +1,3 @@
+CREATE INDEX idx_todos_user_created_at ON todos (user_id, created_at, id);
+CREATE INDEX idx_todos_user_title ON todos (user_id, title, id);
+CREATE INDEX idx_todos_user_completed ON todos (user_id, completed, id);
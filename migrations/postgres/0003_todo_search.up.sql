@@ -0,0 +1,7 @@
+ALTER TABLE todos ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_todos_search_vector ON todos USING GIN (search_vector);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_todos_user_due_at;
+
+ALTER TABLE todos
+    DROP COLUMN completed_at,
+    DROP COLUMN due_at,
+    DROP COLUMN start_at;
+
+ALTER TABLE users DROP COLUMN timezone;
@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE todos
+    ADD COLUMN start_at TIMESTAMPTZ,
+    ADD COLUMN due_at TIMESTAMPTZ,
+    ADD COLUMN completed_at TIMESTAMPTZ;
+
+CREATE INDEX idx_todos_user_due_at ON todos (user_id, due_at, id);
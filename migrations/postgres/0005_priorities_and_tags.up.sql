@@ -0,0 +1,21 @@
+ALTER TABLE todos ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_todos_user_priority ON todos (user_id, priority, id);
+
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_tags_user_name ON tags (user_id, LOWER(name));
+
+CREATE TABLE todo_tags (
+    todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX idx_todo_tags_tag ON todo_tags (tag_id, todo_id);
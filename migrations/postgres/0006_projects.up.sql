@@ -0,0 +1,27 @@
+CREATE TABLE projects (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    inbox BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_projects_user ON projects (user_id, id);
+CREATE UNIQUE INDEX idx_projects_user_inbox ON projects (user_id) WHERE inbox;
+
+INSERT INTO projects (user_id, name, inbox)
+SELECT id, 'Inbox', TRUE FROM users;
+
+ALTER TABLE todos ADD COLUMN project_id INTEGER REFERENCES projects(id);
+
+UPDATE todos t
+SET project_id = p.id
+FROM projects p
+WHERE p.user_id = t.user_id AND p.inbox;
+
+ALTER TABLE todos ALTER COLUMN project_id SET NOT NULL;
+
+CREATE INDEX idx_todos_project ON todos (project_id, id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todos_parent;
+
+ALTER TABLE todos DROP COLUMN rollup;
+ALTER TABLE todos DROP COLUMN parent_id;
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN parent_id INTEGER REFERENCES todos(id) ON DELETE CASCADE;
+ALTER TABLE todos ADD COLUMN rollup VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_todos_parent ON todos (parent_id, id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_todos_series;
+
+ALTER TABLE todos DROP COLUMN occurrence;
+ALTER TABLE todos DROP COLUMN series_id;
+ALTER TABLE todos DROP COLUMN recurrence;
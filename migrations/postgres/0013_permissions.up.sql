@@ -0,0 +1,15 @@
+CREATE TABLE roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE role_permissions (
+    role VARCHAR(32) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Holds every permission'),
+    ('user', 'Works with their own todos, tags and projects');
@@ -0,0 +1,22 @@
+CREATE TABLE login_throttles (
+    kind VARCHAR(16) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (kind, subject)
+);
+
+CREATE INDEX idx_login_throttles_last_failure ON login_throttles (last_failure_at);
+
+CREATE TABLE lockout_events (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_lockout_events_subject ON lockout_events (kind, subject);
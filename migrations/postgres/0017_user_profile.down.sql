@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_email;
+
+ALTER TABLE users
+    DROP COLUMN locale,
+    DROP COLUMN email,
+    DROP COLUMN display_name;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN email VARCHAR(254),
+    ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'en';
+
+CREATE UNIQUE INDEX idx_users_email ON users (LOWER(email));
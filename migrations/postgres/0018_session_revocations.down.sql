@@ -0,0 +1,4 @@
+DELETE FROM token_revocations WHERE session_id <> '';
+ALTER TABLE token_revocations DROP CONSTRAINT token_revocations_pkey;
+ALTER TABLE token_revocations ADD PRIMARY KEY (user_id, jti);
+ALTER TABLE token_revocations DROP COLUMN session_id;
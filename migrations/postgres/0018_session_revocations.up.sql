@@ -0,0 +1,4 @@
+-- A session_id revokes every token of the user issued from that login.
+ALTER TABLE token_revocations ADD COLUMN session_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE token_revocations DROP CONSTRAINT token_revocations_pkey;
+ALTER TABLE token_revocations ADD PRIMARY KEY (user_id, jti, session_id);
@@ -0,0 +1 @@
+DROP TABLE mfa_token_attempts;
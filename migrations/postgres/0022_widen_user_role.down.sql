@@ -0,0 +1,3 @@
+-- Fails while a user has a role name longer than 20 characters; give them
+-- another role first.
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(20);
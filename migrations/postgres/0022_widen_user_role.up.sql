@@ -0,0 +1,2 @@
+-- Role names may be as long as in roles.
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(32);
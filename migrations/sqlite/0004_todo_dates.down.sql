@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_todos_user_due_at;
+
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN start_at;
+
+ALTER TABLE users DROP COLUMN timezone;
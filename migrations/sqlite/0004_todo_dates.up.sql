@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE todos ADD COLUMN start_at DATETIME;
+ALTER TABLE todos ADD COLUMN due_at DATETIME;
+ALTER TABLE todos ADD COLUMN completed_at DATETIME;
+
+CREATE INDEX idx_todos_user_due_at ON todos (user_id, due_at, id);
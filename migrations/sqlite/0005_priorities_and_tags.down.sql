@@ -0,0 +1,5 @@
+DROP TABLE todo_tags;
+DROP TABLE tags;
+
+DROP INDEX IF EXISTS idx_todos_user_priority;
+ALTER TABLE todos DROP COLUMN priority;
@@ -0,0 +1,21 @@
+ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_todos_user_priority ON todos (user_id, priority, id);
+
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    color TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_tags_user_name ON tags (user_id, name COLLATE NOCASE);
+
+CREATE TABLE todo_tags (
+    todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX idx_todo_tags_tag ON todo_tags (tag_id, todo_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todos_project;
+ALTER TABLE todos DROP COLUMN project_id;
+
+DROP TABLE projects;
@@ -0,0 +1,26 @@
+CREATE TABLE projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    color TEXT NOT NULL DEFAULT '',
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    inbox BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_projects_user ON projects (user_id, id);
+CREATE UNIQUE INDEX idx_projects_user_inbox ON projects (user_id) WHERE inbox;
+
+-- created_at uses the fixed width layout the repositories store timestamps in.
+INSERT INTO projects (user_id, name, inbox, created_at)
+SELECT id, 'Inbox', TRUE, strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000' FROM users;
+
+-- SQLite cannot add a NOT NULL column without a default, so the
+-- repositories always set project_id instead.
+ALTER TABLE todos ADD COLUMN project_id INTEGER REFERENCES projects(id);
+
+UPDATE todos
+SET project_id = (SELECT p.id FROM projects p WHERE p.user_id = todos.user_id AND p.inbox);
+
+CREATE INDEX idx_todos_project ON todos (project_id, id);
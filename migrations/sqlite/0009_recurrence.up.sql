@@ -0,0 +1,5 @@
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN series_id INTEGER;
+ALTER TABLE todos ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_todos_series ON todos (series_id, occurrence);
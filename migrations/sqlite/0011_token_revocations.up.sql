@@ -0,0 +1,11 @@
+-- An empty jti revokes every token of the user issued at or before
+-- issued_before.
+CREATE TABLE token_revocations (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    jti TEXT NOT NULL DEFAULT '',
+    issued_before DATETIME,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, jti)
+);
+
+CREATE INDEX idx_token_revocations_expires ON token_revocations (expires_at);
@@ -0,0 +1,16 @@
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+-- Timestamps use the fixed width layout the repositories store.
+INSERT INTO roles (name, description, created_at) VALUES
+    ('admin', 'Holds every permission', strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000'),
+    ('user', 'Works with their own todos, tags and projects', strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000');
@@ -0,0 +1,13 @@
+CREATE TABLE personal_access_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    -- Space separated, as in OAuth.
+    scopes TEXT NOT NULL,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens (user_id);
@@ -0,0 +1,17 @@
+CREATE TABLE totp_enrollments (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    enabled_at DATETIME
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at DATETIME
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user ON mfa_recovery_codes (user_id);
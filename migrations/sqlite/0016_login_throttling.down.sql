@@ -0,0 +1,2 @@
+DROP TABLE lockout_events;
+DROP TABLE login_throttles;
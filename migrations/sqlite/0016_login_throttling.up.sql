@@ -0,0 +1,22 @@
+CREATE TABLE login_throttles (
+    kind TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME,
+    PRIMARY KEY (kind, subject)
+);
+
+CREATE INDEX idx_login_throttles_last_failure ON login_throttles (last_failure_at);
+
+CREATE TABLE lockout_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_lockout_events_subject ON lockout_events (kind, subject);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_email;
+
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN email;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email TEXT;
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT 'en';
+
+CREATE UNIQUE INDEX idx_users_email ON users (LOWER(email));
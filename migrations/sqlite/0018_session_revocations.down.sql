@@ -0,0 +1,15 @@
+CREATE TABLE token_revocations_old (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    jti TEXT NOT NULL DEFAULT '',
+    issued_before DATETIME,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, jti)
+);
+
+INSERT INTO token_revocations_old (user_id, jti, issued_before, expires_at)
+SELECT user_id, jti, issued_before, expires_at FROM token_revocations WHERE session_id = '';
+
+DROP TABLE token_revocations;
+ALTER TABLE token_revocations_old RENAME TO token_revocations;
+
+CREATE INDEX idx_token_revocations_expires ON token_revocations (expires_at);
@@ -0,0 +1,18 @@
+-- SQLite cannot change a primary key, so the table is rebuilt. A session_id
+-- revokes every token of the user issued from that login.
+CREATE TABLE token_revocations_new (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    jti TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    issued_before DATETIME,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, jti, session_id)
+);
+
+INSERT INTO token_revocations_new (user_id, jti, issued_before, expires_at)
+SELECT user_id, jti, issued_before, expires_at FROM token_revocations;
+
+DROP TABLE token_revocations;
+ALTER TABLE token_revocations_new RENAME TO token_revocations;
+
+CREATE INDEX idx_token_revocations_expires ON token_revocations (expires_at);
@@ -0,0 +1,9 @@
+-- Codes tried with each MFA token, by its jti, and when it completed a login.
+CREATE TABLE mfa_token_attempts (
+    jti VARCHAR(64) PRIMARY KEY,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    used_at DATETIME,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_mfa_token_attempts_expires ON mfa_token_attempts (expires_at);
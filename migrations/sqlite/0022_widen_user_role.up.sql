@@ -0,0 +1,3 @@
+-- SQLite does not limit the length of users.role; this keeps the versions
+-- in step with Postgres.
+SELECT 1;